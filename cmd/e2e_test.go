@@ -1,11 +1,15 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/PhantomInTheWire/wikifind/search"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -16,21 +20,24 @@ func TestEndToEnd(t *testing.T) {
 	xmlPath := "testdata/india.xml"
 
 	// Build the binary first
-	buildCmd := exec.Command("go", "build", "-o", "../wikifind", ".")
+	binary := filepath.Join(tempDir, "wikifind")
+	buildCmd := exec.Command("go", "build", "-o", binary, ".")
 	buildOutput, err := buildCmd.CombinedOutput()
 	require.NoError(t, err, "Build failed: %s", buildOutput)
 
 	// Index the test data
-	cmd := exec.Command("../wikifind", "index", xmlPath, indexPath)
+	cmd := exec.Command(binary, "index", xmlPath, indexPath)
 	output, err := cmd.CombinedOutput()
 	require.NoError(t, err, "Index command failed: %s", output)
 
 	// Check if index files exist
-	indexFile := filepath.Join(indexPath, "indexa.idx")
-	assert.FileExists(t, indexFile, "Index file should be created")
+	// The ranks computed from the link graph are published in a second commit.
+	assert.FileExists(t, filepath.Join(indexPath, "segments_2"), "Index should be committed")
+	assert.FileExists(t, filepath.Join(indexPath, "ranks_1.dat"), "Ranks should be computed")
+	assert.FileExists(t, filepath.Join(indexPath, "_0", "terms.dict"), "Index file should be created")
 
 	// Search for a term
-	cmd = exec.Command("../wikifind", "search", indexPath)
+	cmd = exec.Command(binary, "search", indexPath)
 	stdin, err := cmd.StdinPipe()
 	require.NoError(t, err)
 	go func() {
@@ -45,7 +52,7 @@ func TestEndToEnd(t *testing.T) {
 	assert.True(t, strings.Contains(string(output), "results"), "Output should contain 'results'")
 
 	// Search for another term
-	cmd = exec.Command("../wikifind", "search", indexPath)
+	cmd = exec.Command(binary, "search", indexPath)
 	stdin, err = cmd.StdinPipe()
 	require.NoError(t, err)
 	go func() {
@@ -55,4 +62,115 @@ func TestEndToEnd(t *testing.T) {
 	output2, err := cmd.CombinedOutput()
 	require.NoError(t, err, "Search command failed: %s", output2)
 	assert.NotEmpty(t, output2, "Search should produce output")
+
+	// Add the same dump again as a new segment
+	cmd = exec.Command(binary, "update", xmlPath, indexPath)
+	output, err = cmd.CombinedOutput()
+	require.NoError(t, err, "Update command failed: %s", output)
+	assert.FileExists(t, filepath.Join(indexPath, "segments_4"), "Update should be committed")
+	assert.DirExists(t, filepath.Join(indexPath, "_1"), "Update should add a segment")
+
+	// Deleting an unknown page reports it and fails
+	cmd = exec.Command(binary, "delete", indexPath, "No such page")
+	output, err = cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "Not found: No such page")
+
+	// Compacting merges the segments
+	cmd = exec.Command(binary, "compact", indexPath)
+	output, err = cmd.CombinedOutput()
+	require.NoError(t, err, "Compact command failed: %s", output)
+	assert.FileExists(t, filepath.Join(indexPath, "segments_5"), "Compaction should be committed")
+	assert.NoFileExists(t, filepath.Join(indexPath, "segments_3"), "Compaction should drop commits before the previous one")
+	assert.DirExists(t, filepath.Join(indexPath, "_0"), "The previous commit should keep its segments until the next commit")
+}
+
+func TestEndToEnd_SearchCommand(t *testing.T) {
+	tempDir := t.TempDir()
+	binary := filepath.Join(tempDir, "wikifind")
+	buildOutput, err := exec.Command("go", "build", "-o", binary, ".").CombinedOutput()
+	require.NoError(t, err, "Build failed: %s", buildOutput)
+
+	xmlPath := filepath.Join(tempDir, "dump.xml")
+	require.NoError(t, os.WriteFile(xmlPath, []byte(`<mediawiki>
+<page><title>Albert Einstein</title><id>1</id><revision><timestamp>2024-03-01T10:00:00Z</timestamp><text>Physicist who developed relativity. [[Category:Physicists]]</text></revision></page>
+<page><title>Einsteinium</title><id>2</id><revision><text>A synthetic element named after Einstein.</text></revision></page>
+</mediawiki>`), 0644))
+	indexPath := filepath.Join(tempDir, "index")
+	output, err := exec.Command(binary, "index", xmlPath, indexPath).CombinedOutput()
+	require.NoError(t, err, "Index command failed: %s", output)
+
+	exitCode := func(err error) int {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		require.NoError(t, err)
+		return 0
+	}
+
+	output, err = exec.Command(binary, "search", indexPath, "-q", "einstein", "--format", "tsv").Output()
+	assert.Equal(t, exitResults, exitCode(err))
+	assert.Equal(t, "1\t1\t", string(output[:4]))
+	assert.Contains(t, string(output), "\tAlbert Einstein\n")
+
+	output, err = exec.Command(binary, "search", indexPath, "-q", "relativity", "--limit", "1", "--format", "json").Output()
+	assert.Equal(t, exitResults, exitCode(err))
+	var resp search.JSONResponse
+	require.NoError(t, json.Unmarshal(output, &resp))
+	assert.Equal(t, 1, resp.TotalHits)
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "1", resp.Results[0].DocID)
+	assert.Equal(t, "Albert Einstein", resp.Results[0].Title)
+	assert.Contains(t, resp.Results[0].Snippet, "relativity")
+	assert.Len(t, resp.Results[0].Highlights.Snippet, 1)
+
+	output, err = exec.Command(binary, "search", indexPath, "-q", "relativity", "--explain").Output()
+	assert.Equal(t, exitResults, exitCode(err))
+	assert.Contains(t, string(output), "= score of document 1 with tfidf, sum of\n")
+
+	output, err = exec.Command(binary, "search", indexPath, "-q", "einstein", "--facets", "3").Output()
+	assert.Equal(t, exitResults, exitCode(err))
+	assert.Contains(t, string(output), "category: Physicists (1)\n")
+	assert.Contains(t, string(output), "namespace: Main (2)\n")
+	assert.Contains(t, string(output), "year: 2024 (1)\n")
+
+	output, err = exec.Command(binary, "search", indexPath, "-q", "einstein year:2024", "--format", "json", "--facets", "3").Output()
+	assert.Equal(t, exitResults, exitCode(err))
+	resp = search.JSONResponse{}
+	require.NoError(t, json.Unmarshal(output, &resp))
+	assert.Equal(t, 1, resp.TotalHits)
+	require.Len(t, resp.Facets, 4)
+	assert.Equal(t, []search.FacetCount{{Value: "Physicists", Count: 1}}, resp.Facets[0].Values)
+
+	_, err = exec.Command(binary, "search", indexPath, "-q", "nothing").Output()
+	assert.Equal(t, exitNoResults, exitCode(err))
+
+	_, err = exec.Command(binary, "search", indexPath, "-q", "the").Output()
+	assert.Equal(t, exitError, exitCode(err))
+
+	usage := exec.Command(binary, "stats", indexPath, "--format", "xml")
+	var stderr strings.Builder
+	usage.Stderr = &stderr
+	output, err = usage.Output()
+	assert.Equal(t, exitError, exitCode(err))
+	assert.Empty(t, output)
+	assert.Contains(t, stderr.String(), "Usage: wikifind stats")
+
+	usage = exec.Command(binary, "compact", indexPath, "extra")
+	stderr.Reset()
+	usage.Stderr = &stderr
+	output, err = usage.Output()
+	assert.Equal(t, exitError, exitCode(err))
+	assert.Empty(t, output)
+	assert.Contains(t, stderr.String(), "Usage: wikifind compact")
+
+	cmd := exec.Command(binary, "search", indexPath, "--queries", "-")
+	cmd.Stdin = strings.NewReader("einstein\nnothing\n")
+	output, err = cmd.Output()
+	assert.Equal(t, exitResults, exitCode(err))
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"query":"einstein"`)
+	assert.Contains(t, lines[1], `"total_hits":0`)
 }
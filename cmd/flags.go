@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// parseArgs parses args with fs, allowing flags after the positional
+// arguments as in "serve index/ --addr :9000", and returns the positional
+// arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// setUsage makes fs print lines, then its flags, to standard error as the
+// usage of its command.
+func setUsage(fs *flag.FlagSet, lines ...string) {
+	fs.Usage = func() {
+		for _, line := range lines {
+			fmt.Fprintln(os.Stderr, line)
+		}
+		fs.PrintDefaults()
+	}
+}
+
+// usage prints the usage of the command of fs and exits with exitError, as
+// every command does on bad arguments.
+func usage(fs *flag.FlagSet) {
+	fs.Usage()
+	os.Exit(exitError)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+func inspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	limit := fs.Int("limit", 20, "number of postings of a term to list per segment, 0 for all")
+	setUsage(fs,
+		"Usage: wikifind inspect term <index_path> <word> [--limit 20]",
+		"       wikifind inspect doc <index_path> <docID>")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 3 || *limit < 0 || (positional[0] != "term" && positional[0] != "doc") {
+		usage(fs)
+	}
+
+	r, err := indexer.OpenIndexReader(positional[1])
+	if err != nil {
+		log.Fatalf("Error opening index: %v", err)
+	}
+	defer func() { _ = r.Close() }()
+
+	out := bufio.NewWriter(os.Stdout)
+	var found bool
+	if positional[0] == "term" {
+		found, err = inspectTerm(out, r, positional[2], *limit)
+	} else {
+		found, err = inspectDoc(out, r, positional[2])
+	}
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if !found {
+		// Deferred calls do not run on exit.
+		_ = r.Close()
+		os.Exit(1)
+	}
+}
+
+// inspectTerm writes the terms word is analyzed into and their postings in
+// every segment, at most limit per segment if limit is positive, and
+// reports whether any term is in the index.
+func inspectTerm(w io.Writer, r *indexer.IndexReader, word string, limit int) (bool, error) {
+	tokens := analyzeText("", word)
+	if len(tokens) == 0 {
+		_, _ = fmt.Fprintf(w, "%q has no words to index.\n", word)
+		return false, nil
+	}
+
+	found := false
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		if seen[token.Word] {
+			continue
+		}
+		if len(seen) > 0 {
+			_, _ = fmt.Fprintln(w)
+		}
+		seen[token.Word] = true
+		if token.Term == "" {
+			_, _ = fmt.Fprintf(w, "%q is a stop word and is not indexed.\n", token.Word)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "Term: %s (analyzed from %q)\n", token.Term, token.Word)
+
+		total := 0
+		for j, segment := range r.Segments {
+			info, ok, err := segment.Lookup(token.Term)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				continue
+			}
+			found = true
+			total += info.DocFreq
+			_, _ = fmt.Fprintf(w, "%s: %d documents, most often indexed from %q, max frequency %d, fields %s\n",
+				r.Commit.Segments[j].Name, info.DocFreq, info.Form, info.MaxFrequency, strings.Join(info.Fields.Names(), ", "))
+
+			it, err := segment.Postings(info)
+			if err != nil {
+				return false, err
+			}
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			for n := 0; it.Next(); n++ {
+				if limit > 0 && n == limit {
+					_, _ = fmt.Fprintf(tw, "  ... %d more\n", info.DocFreq-limit)
+					break
+				}
+				ord := it.Doc()
+				line := fmt.Sprintf("  %s\t%s\t%s", segment.DocID(ord), segment.Doc(ord).Title, formatFields(it.Posting()))
+				if r.IsDead(j, ord) {
+					line += "\t(deleted)"
+				}
+				_, _ = fmt.Fprintln(tw, line)
+			}
+			if err := it.Err(); err != nil {
+				return false, err
+			}
+			if err := tw.Flush(); err != nil {
+				return false, err
+			}
+		}
+		if total == 0 {
+			_, _ = fmt.Fprintln(w, "Not in the index.")
+		}
+	}
+	return found, nil
+}
+
+// inspectDoc writes what is stored about a document and the terms indexed
+// for it, and reports whether the document is in the index. Finding the
+// terms reads the posting list of every term of the document's segment.
+func inspectDoc(w io.Writer, r *indexer.IndexReader, docID string) (bool, error) {
+	i, ord, ok := r.Locate(docID)
+	if !ok {
+		_, _ = fmt.Fprintf(w, "Document %s not found.\n", docID)
+		return false, nil
+	}
+	segment := r.Segments[i]
+	doc := segment.Doc(ord)
+
+	_, _ = fmt.Fprintf(w, "DocID: %s\nTitle: %s\n", docID, doc.Title)
+	if doc.Redirect != "" {
+		_, _ = fmt.Fprintf(w, "Redirects to: %s\n", doc.Redirect)
+	}
+	_, _ = fmt.Fprintf(w, "Length: %d tokens\nSegment: %s, ordinal %d\n", doc.Length, r.Commit.Segments[i].Name, ord)
+	if r.IsDead(i, ord) {
+		_, _ = fmt.Fprintln(w, "Deleted: yes")
+	}
+	if rank, ok := r.Ranks[docID]; ok {
+		_, _ = fmt.Fprintf(w, "Inlinks: %d, PageRank: %.3f\n", rank.Inlinks, rank.PageRank)
+	}
+	categories, err := segment.Categories(ord)
+	if err != nil {
+		return false, err
+	}
+	if len(categories) > 0 {
+		_, _ = fmt.Fprintf(w, "Categories: %s\n", strings.Join(categories, "; "))
+	}
+	values, err := segment.Facets(ord)
+	if err != nil {
+		return false, err
+	}
+	var facets []string
+	for _, v := range values {
+		if v.Facet != indexer.FacetCategory {
+			facets = append(facets, v.Facet+": "+v.Value)
+		}
+	}
+	if len(facets) > 0 {
+		_, _ = fmt.Fprintf(w, "Facets: %s\n", strings.Join(facets, "; "))
+	}
+	abstract, err := segment.Abstract(ord)
+	if err != nil {
+		return false, err
+	}
+	if abstract != "" {
+		_, _ = fmt.Fprintf(w, "Abstract: %s\n", abstract)
+	}
+
+	var lines []string
+	for t := range segment.NumTerms() {
+		info, err := segment.TermAt(t)
+		if err != nil {
+			return false, err
+		}
+		it, err := segment.Postings(info)
+		if err != nil {
+			return false, err
+		}
+		if it.Advance(ord) && it.Doc() == ord {
+			lines = append(lines, fmt.Sprintf("  %s\t%s\t%s", info.Term, info.Form, formatFields(it.Posting())))
+		}
+		if err := it.Err(); err != nil {
+			return false, err
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "Terms: %d\n", len(lines))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(tw, line)
+	}
+	return true, tw.Flush()
+}
+
+// formatFields lists the frequency of a posting in each of its fields, as
+// in "body:3 title:1".
+func formatFields(posting indexer.Posting) string {
+	var parts []string
+	for i := range indexer.NumFields {
+		field := indexer.FieldMask(1 << i)
+		if posting.Fields&field == 0 {
+			continue
+		}
+		name := fmt.Sprint(i)
+		if names := field.Names(); len(names) > 0 {
+			name = names[0]
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d", name, posting.FieldFreqs[i]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// analyzeText runs the analyzer over a page with title and wiki text.
+func analyzeText(title, text string) []indexer.Token {
+	doc := &indexer.Document{Title: title, Content: text, Metadata: make(map[string]string)}
+	return indexer.NewWikiTextParser(doc).Analyze()
+}
+
+func analyze(args []string) {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	title := fs.String("title", "", "title of the page the text is from")
+	setUsage(fs, "Usage: wikifind analyze [--title title] <text|->")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) == 0 {
+		usage(fs)
+	}
+
+	text := strings.Join(positional, " ")
+	if text == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("Error reading text: %v", err)
+		}
+		text = string(data)
+	}
+	if err := printTokens(os.Stdout, analyzeText(*title, text)); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// printTokens writes one token per line with its field and term.
+func printTokens(w io.Writer, tokens []indexer.Token) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "FIELD\tWORD\tTERM")
+	for _, token := range tokens {
+		term := token.Term
+		if term == "" {
+			term = "(stop word)"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.Join(token.Field.Names(), ","), token.Word, term)
+	}
+	return tw.Flush()
+}
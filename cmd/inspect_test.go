@@ -0,0 +1,110 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openInspectIndex(t *testing.T) *indexer.IndexReader {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	first := indexer.NewInvertedIndex()
+	first.AddDocument("1", "Paris")
+	first.SetAbstract("1", "Paris is the capital of France.")
+	first.SetFacets("1", []indexer.FacetValue{
+		{Facet: indexer.FacetNamespace, Value: indexer.MainNamespace},
+		{Facet: indexer.FacetInfobox, Value: "infobox settlement"},
+	})
+	first.Add("pari", "1", indexer.NewPosting(indexer.TITLE, 1))
+	first.Add("pari", "1", indexer.NewPosting(indexer.BODY, 3))
+	first.Add("capit", "1", indexer.NewPosting(indexer.BODY, 1))
+	first.AddDocument("2", "Paris Hilton")
+	first.Add("pari", "2", indexer.NewPosting(indexer.TITLE, 1))
+	require.NoError(t, indexer.NewIndexWriter(indexPath).WriteIndex(first))
+
+	second := indexer.NewInvertedIndex()
+	second.AddDocument("2", "Paris Hilton")
+	second.Add("hilton", "2", indexer.NewPosting(indexer.TITLE, 1))
+	writer := indexer.NewIndexWriter(indexPath)
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+
+	r, err := indexer.OpenIndexReader(indexPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.Close() })
+	return r
+}
+
+func TestInspectTerm(t *testing.T) {
+	r := openInspectIndex(t)
+
+	var out strings.Builder
+	found, err := inspectTerm(&out, r, "Paris of", 0)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, `Term: pari (analyzed from "paris")
+_0: 2 documents, most often indexed from "pari", max frequency 4, fields body, title
+  1  Paris         body:3 title:1
+  2  Paris Hilton  title:1  (deleted)
+
+"of" is a stop word and is not indexed.
+`, out.String())
+
+	out.Reset()
+	found, err = inspectTerm(&out, r, "paris", 1)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Contains(t, out.String(), "  ... 1 more\n")
+
+	out.Reset()
+	found, err = inspectTerm(&out, r, "rome", 0)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Contains(t, out.String(), "Not in the index.")
+}
+
+func TestInspectDoc(t *testing.T) {
+	r := openInspectIndex(t)
+
+	var out strings.Builder
+	found, err := inspectDoc(&out, r, "1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, `DocID: 1
+Title: Paris
+Length: 5 tokens
+Segment: _0, ordinal 0
+Facets: infobox: infobox settlement; namespace: Main
+Abstract: Paris is the capital of France.
+Terms: 2
+  capit  capit  body:1
+  pari   pari   body:3 title:1
+`, out.String())
+
+	out.Reset()
+	found, err = inspectDoc(&out, r, "2")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Contains(t, out.String(), "Segment: _1, ordinal 0\n")
+	assert.Contains(t, out.String(), "  hilton  hilton  title:1\n")
+
+	out.Reset()
+	found, err = inspectDoc(&out, r, "3")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestPrintTokens(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, printTokens(&out, analyzeText("Paris", "The [[Seine]] flows")))
+	assert.Equal(t, `FIELD  WORD   TERM
+title  paris  pari
+links  seine  sein
+body   the    (stop word)
+body   flows  flow
+`, out.String())
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+)
+
+// errInterrupted is returned when Ctrl+C is pressed at the prompt.
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads the lines typed at the REPL prompt.
+type lineReader interface {
+	// readLine shows prompt and returns the next line. history holds the
+	// earlier lines, oldest first, for readers that can recall them.
+	readLine(prompt string, history []string) (string, error)
+}
+
+// plainReader reads lines from a pipe or a file.
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (p *plainReader) readLine(prompt string, _ []string) (string, error) {
+	_, _ = fmt.Fprint(p.out, prompt)
+	if !p.scanner.Scan() {
+		if err := p.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return p.scanner.Text(), nil
+}
+
+// lineEditor reads lines from a terminal in raw mode, so that the line can
+// be edited in place and earlier lines recalled with the arrow keys:
+//
+//	Left, Right, Ctrl+B, Ctrl+F  move by one character
+//	Home, End, Ctrl+A, Ctrl+E    move to the start or end of the line
+//	Up, Down, Ctrl+P, Ctrl+N     recall earlier or later lines
+//	Backspace, Delete, Ctrl+D    delete the character before or under the cursor
+//	Ctrl+W                       delete the word before the cursor
+//	Ctrl+U, Ctrl+K               delete up to the start or end of the line
+//	Ctrl+L                       clear the screen
+//	Ctrl+C, Ctrl+D on an empty line  quit
+type lineEditor struct {
+	fd  int
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newLineEditor(in *os.File, out io.Writer) *lineEditor {
+	return &lineEditor{fd: int(in.Fd()), in: bufio.NewReader(in), out: out}
+}
+
+func ctrl(key rune) rune {
+	return key & 0x1f
+}
+
+func (e *lineEditor) readLine(prompt string, history []string) (string, error) {
+	state, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = restore(e.fd, state) }()
+
+	var line, draft []rune
+	pos := 0
+	// recalled is the position in history of the line shown, or
+	// len(history) for the draft being typed.
+	recalled := len(history)
+
+	recall := func(i int) {
+		if recalled == len(history) {
+			draft = line
+		}
+		recalled = i
+		if i == len(history) {
+			line = draft
+		} else {
+			line = []rune(history[i])
+		}
+		pos = len(line)
+	}
+	redraw := func() {
+		_, _ = fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			_, _ = fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+
+	redraw()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			_, _ = fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case ctrl('C'):
+			_, _ = fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case ctrl('D'):
+			if len(line) == 0 {
+				_, _ = fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case ctrl('A'):
+			pos = 0
+		case ctrl('E'):
+			pos = len(line)
+		case ctrl('B'):
+			pos = max(pos-1, 0)
+		case ctrl('F'):
+			pos = min(pos+1, len(line))
+		case ctrl('P'):
+			if recalled > 0 {
+				recall(recalled - 1)
+			}
+		case ctrl('N'):
+			if recalled < len(history) {
+				recall(recalled + 1)
+			}
+		case ctrl('K'):
+			line = line[:pos]
+		case ctrl('U'):
+			line = append([]rune(nil), line[pos:]...)
+			pos = 0
+		case ctrl('W'):
+			start := pos
+			for start > 0 && unicode.IsSpace(line[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(line[start-1]) {
+				start--
+			}
+			line = append(line[:start], line[pos:]...)
+			pos = start
+		case ctrl('L'):
+			_, _ = fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case ctrl('H'), 0x7f:
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 0x1b:
+			switch e.escape() {
+			case 'A':
+				if recalled > 0 {
+					recall(recalled - 1)
+				}
+			case 'B':
+				if recalled < len(history) {
+					recall(recalled + 1)
+				}
+			case 'C':
+				pos = min(pos+1, len(line))
+			case 'D':
+				pos = max(pos-1, 0)
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(line)
+			case 'X':
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+			}
+		}
+		redraw()
+	}
+}
+
+// escape reads the rest of an escape sequence and returns the key it
+// stands for: 'A' to 'D' for the arrows, 'H' for Home, 'F' for End, 'X'
+// for Delete, or 0 for anything else.
+func (e *lineEditor) escape() rune {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+	param := 0
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return 0
+		}
+		switch {
+		case '0' <= r && r <= '9':
+			param = param*10 + int(r-'0')
+		case r == ';':
+			param = 0
+		case r == '~':
+			switch param {
+			case 1, 7:
+				return 'H'
+			case 4, 8:
+				return 'F'
+			case 3:
+				return 'X'
+			}
+			return 0
+		case '@' <= r && r <= '~':
+			return r
+		default:
+			return 0
+		}
+	}
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -10,29 +10,39 @@ import (
 	"syscall"
 
 	"github.com/PhantomInTheWire/wikifind/indexer"
-	"github.com/PhantomInTheWire/wikifind/search"
 )
 
 func main() {
+	setUsage(flag.CommandLine,
+		"Usage: wikifind <command> <args>",
+		"Commands:",
+		"  index <xml_file> <index_path>",
+		"  update <xml_file> <index_path>",
+		"  search <index_path> [-q query | --queries file] [--limit 10] [--format text|json|tsv]",
+		"  serve <index_path> [--addr :8080] [--timeout 10s] [--prior-weight 1]",
+		"  stats <index_path> [--top 20] [--format text|json]",
+		"  inspect term <index_path> <word> | inspect doc <index_path> <docID>",
+		"  analyze [--title title] <text|->",
+		"  verify <index_path>",
+		"  delete <index_path> <docID|title>...",
+		"  compact <index_path>")
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: wikifind <command> <args>")
-		fmt.Println("Commands:")
-		fmt.Println("  index <xml_file> <index_path>")
-		fmt.Println("  search <index_path>")
-		os.Exit(1)
+		usage(flag.CommandLine)
 	}
 
 	command := os.Args[1]
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
 
 	switch command {
-	case "index":
-		if len(os.Args) != 4 {
-			fmt.Println("Usage: wikifind index <xml_file> <index_path>")
-			os.Exit(1)
+	case "index", "update":
+		setUsage(fs, fmt.Sprintf("Usage: wikifind %s <xml_file> <index_path>", command))
+		positional, err := parseArgs(fs, os.Args[2:])
+		if err != nil || len(positional) != 2 {
+			usage(fs)
 		}
 
-		xmlFile := os.Args[2]
-		indexPath := os.Args[3]
+		xmlFile := positional[0]
+		indexPath := positional[1]
 
 		fmt.Printf("Parsing Wikipedia XML dump: %s\n", xmlFile)
 		ctx, cancel := context.WithCancel(context.Background())
@@ -48,61 +58,73 @@ func main() {
 
 		parser := indexer.NewWikiXMLParser(indexPath)
 
-		if err := parser.Parse(ctx, xmlFile); err != nil {
+		parse := parser.Parse
+		if command == "update" {
+			parse = parser.Update
+		}
+		if err := parse(ctx, xmlFile); err != nil {
 			log.Fatalf("Error parsing XML: %v", err)
 		}
 
 		fmt.Println("Indexing completed successfully!")
 
 	case "search":
-		if len(os.Args) != 3 {
-			fmt.Println("Usage: wikifind search <index_path>")
-			os.Exit(1)
+		if code := searchCommand(os.Args[2:]); code != exitResults {
+			os.Exit(code)
+		}
+
+	case "serve":
+		serve(os.Args[2:])
+
+	case "stats":
+		stats(os.Args[2:])
+
+	case "inspect":
+		inspect(os.Args[2:])
+
+	case "analyze":
+		analyze(os.Args[2:])
+
+	case "verify":
+		verify(os.Args[2:])
+
+	case "delete":
+		setUsage(fs, "Usage: wikifind delete <index_path> <docID|title>...")
+		positional, err := parseArgs(fs, os.Args[2:])
+		if err != nil || len(positional) < 2 {
+			usage(fs)
 		}
 
-		indexPath := os.Args[2]
+		writer := indexer.NewIndexWriter(positional[0])
+		deleted, missing, err := writer.Delete(positional[1:]...)
+		if err != nil {
+			log.Fatalf("Error deleting documents: %v", err)
+		}
 
-		fmt.Println("Initializing search engine...")
-		engine := search.NewSearchEngine(indexPath)
+		fmt.Printf("Deleted %d documents\n", deleted)
+		for _, key := range missing {
+			fmt.Printf("Not found: %s\n", key)
+		}
+		if len(missing) > 0 {
+			os.Exit(1)
+		}
 
-		if err := engine.Initialize(); err != nil {
-			log.Fatalf("Error initializing search engine: %v", err)
+	case "compact":
+		setUsage(fs, "Usage: wikifind compact <index_path>")
+		positional, err := parseArgs(fs, os.Args[2:])
+		if err != nil || len(positional) != 1 {
+			usage(fs)
 		}
-		defer engine.Close()
-
-		fmt.Println("Search engine ready. Enter queries (Ctrl+C to exit):")
-
-		scanner := bufio.NewScanner(os.Stdin)
-		for {
-			fmt.Print("> ")
-			if !scanner.Scan() {
-				break
-			}
-
-			query := scanner.Text()
-			if query == "" {
-				continue
-			}
-
-			results, err := engine.Search(query, 10)
-			if err != nil {
-				fmt.Printf("Search error: %v\n", err)
-				continue
-			}
-
-			if len(results) == 0 {
-				fmt.Println("No results found.")
-				continue
-			}
-
-			fmt.Printf("Found %d results:\n", len(results))
-			for i, result := range results {
-				fmt.Printf("%d. DocID: %s (Score: %.4f)\n", i+1, result.DocID, result.Score)
-			}
+
+		writer := indexer.NewIndexWriter(positional[0])
+		if err := writer.Compact(); err != nil {
+			log.Fatalf("Error compacting index: %v", err)
 		}
 
+		fmt.Println("Compaction completed successfully!")
+
 	default:
-		fmt.Printf("Unknown command: %s\n", command)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
+		usage(flag.CommandLine)
 	}
 }
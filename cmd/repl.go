@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/PhantomInTheWire/wikifind/search"
+)
+
+const (
+	// pageSize is the number of results the REPL shows at a time, unless
+	// changed with :limit.
+	pageSize = 10
+
+	// suggestBelow is the number of results under which the REPL suggests
+	// a spelling correction.
+	suggestBelow = 5
+
+	// historyFile is where the REPL keeps the lines typed at a terminal,
+	// in the home directory.
+	historyFile = ".wikifind_history"
+
+	// maxHistory is the number of lines of history kept.
+	maxHistory = 1000
+)
+
+const replHelp = `Enter a query to search, or a command:
+  :next, :prev            page through the results of the last query
+  :limit [n]              show n results per page
+  :scorer [tfidf|bm25f]   rank results with another scorer
+  :fields [names|all]     match only in some fields, e.g. title,category
+  :suggest <prefix>       complete a title
+  :explain [on|off]       show how each score is computed
+  :explain <id>           show how the last query scores a document
+  :doc <id>               show a stored document
+  :stats                  show statistics of the index
+  :history                list the lines entered earlier
+  :help                   show this help`
+
+// repl is the interactive search prompt. It remembers the last query so
+// that :next and :prev can page through its results, and the settings
+// changed by commands for the queries that follow.
+type repl struct {
+	engine *search.SearchEngine
+	out    io.Writer
+
+	limit   int
+	scorer  search.Scorer
+	fields  indexer.FieldMask
+	explain bool
+
+	query  string
+	offset int
+	// last is the response for the page shown last.
+	last *search.SearchResponse
+
+	history []string
+	// historyPath is the file history is saved to, if any.
+	historyPath string
+}
+
+func newREPL(engine *search.SearchEngine, out io.Writer) *repl {
+	return &repl{engine: engine, out: out, limit: pageSize}
+}
+
+// run reads queries and commands from in until it is exhausted. When in is
+// a terminal, lines can be edited and the history is kept across sessions.
+func (r *repl) run(in io.Reader) {
+	var reader lineReader = &plainReader{scanner: bufio.NewScanner(in), out: r.out}
+	if file, ok := in.(*os.File); ok && isTerminal(int(file.Fd())) {
+		reader = newLineEditor(file, r.out)
+		if home, err := os.UserHomeDir(); err == nil {
+			r.loadHistory(filepath.Join(home, historyFile))
+		}
+	}
+
+	for {
+		line, err := reader.readLine("> ", r.history)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, errInterrupted) {
+				r.printf("Input error: %v\n", err)
+			}
+			return
+		}
+
+		// Trailing spaces are kept for :suggest, where they end a word.
+		line = strings.TrimLeft(line, " \t")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		r.remember(line)
+		r.execute(line)
+	}
+}
+
+func (r *repl) printf(format string, args ...any) {
+	_, _ = fmt.Fprintf(r.out, format, args...)
+}
+
+// execute runs a command or, for any line not starting with ":", a query.
+func (r *repl) execute(line string) {
+	if !strings.HasPrefix(line, ":") {
+		r.query, r.offset = line, 0
+		r.search()
+		return
+	}
+
+	command, rawArg, _ := strings.Cut(line, " ")
+	arg := strings.TrimSpace(rawArg)
+	switch command {
+	case ":next":
+		r.next()
+	case ":prev":
+		r.prev()
+	case ":limit":
+		r.setLimit(arg)
+	case ":scorer":
+		r.setScorer(arg)
+	case ":fields":
+		r.setFields(arg)
+	case ":suggest":
+		r.printSuggestions(rawArg)
+	case ":explain":
+		r.explainCommand(arg)
+	case ":doc":
+		r.printDoc(arg)
+	case ":stats":
+		r.printStats()
+	case ":history":
+		for i, entry := range r.history {
+			r.printf("%5d  %s\n", i+1, entry)
+		}
+	case ":help":
+		r.printf("%s\n", replHelp)
+	default:
+		r.printf("Unknown command %s; type :help for a list.\n", command)
+	}
+}
+
+func (r *repl) next() {
+	switch {
+	case r.last == nil:
+		r.printf("No query to page through.\n")
+	case r.offset+r.limit >= r.last.TotalHits && !r.last.TotalIsLowerBound:
+		r.printf("No more results.\n")
+	default:
+		r.offset += r.limit
+		r.search()
+	}
+}
+
+func (r *repl) prev() {
+	switch {
+	case r.last == nil:
+		r.printf("No query to page through.\n")
+	case r.offset == 0:
+		r.printf("Already at the first page.\n")
+	default:
+		r.offset = max(r.offset-r.limit, 0)
+		r.search()
+	}
+}
+
+// search shows the current page of the current query.
+func (r *repl) search() {
+	resp, err := r.engine.Execute(context.Background(), search.SearchRequest{
+		Query:   r.query,
+		Offset:  r.offset,
+		Limit:   r.limit,
+		Scorer:  r.scorer,
+		Fields:  r.fields,
+		Explain: r.explain,
+	})
+	if err != nil {
+		r.last = nil
+		r.printf("Search error: %v\n", err)
+		return
+	}
+	if len(resp.Results) == 0 && r.offset > 0 {
+		// The lower bound promised more results than there are.
+		r.offset = max(r.offset-r.limit, 0)
+		r.printf("No more results.\n")
+		return
+	}
+	r.last = resp
+	printResults(r.out, resp, r.offset)
+
+	if r.offset == 0 && resp.TotalHits < suggestBelow {
+		if suggestion, ok, err := r.engine.DidYouMean(r.query); err == nil && ok {
+			r.printf("Did you mean: %s?\n", suggestion)
+		}
+	}
+}
+
+// printResults writes a page of results starting at offset to w, each
+// followed by the explanation of its score if there is one.
+func printResults(w io.Writer, resp *search.SearchResponse, offset int) {
+	if resp.TotalHits == 0 {
+		_, _ = fmt.Fprintln(w, "No results found.")
+		return
+	}
+	total := fmt.Sprintf("%d", resp.TotalHits)
+	if resp.TotalIsLowerBound {
+		total = "at least " + total
+	}
+	_, _ = fmt.Fprintf(w, "Found %s results in %v, showing %d-%d:\n", total, resp.Took.Round(time.Microsecond),
+		offset+1, offset+len(resp.Results))
+	for i, result := range resp.Results {
+		_, _ = fmt.Fprintf(w, "%d. DocID: %s (Score: %.4f)\n", offset+i+1, result.DocID, result.Score)
+		if result.Explanation != nil {
+			printExplanation(w, result.Explanation)
+		}
+	}
+	printFacets(w, resp.Facets)
+}
+
+// printFacets writes the facets having values, one line each.
+func printFacets(w io.Writer, facets []search.Facet) {
+	for _, facet := range facets {
+		if len(facet.Values) == 0 {
+			continue
+		}
+		values := make([]string, len(facet.Values))
+		for i, v := range facet.Values {
+			values[i] = fmt.Sprintf("%s (%d)", v.Value, v.Count)
+		}
+		_, _ = fmt.Fprintf(w, "%s: %s\n", facet.Name, strings.Join(values, ", "))
+	}
+}
+
+func (r *repl) setLimit(arg string) {
+	if arg == "" {
+		r.printf("Showing %d results per page.\n", r.limit)
+		return
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n <= 0 {
+		r.printf("Invalid limit %q.\n", arg)
+		return
+	}
+	r.limit = n
+	r.printf("Showing %d results per page.\n", n)
+}
+
+func (r *repl) setScorer(arg string) {
+	if arg != "" {
+		scorer, err := search.NewScorer(arg)
+		if err != nil {
+			r.printf("%v.\n", err)
+			return
+		}
+		r.scorer = scorer
+	}
+	scorer := r.scorer
+	if scorer == nil {
+		scorer = r.engine.Scorer()
+	}
+	r.printf("Ranking with %s.\n", scorer.Name())
+}
+
+func (r *repl) setFields(arg string) {
+	switch arg {
+	case "":
+	case "all":
+		r.fields = 0
+	default:
+		fields, err := indexer.ParseFields(arg)
+		if err != nil {
+			r.printf("%v.\n", err)
+			return
+		}
+		r.fields = fields
+	}
+	if r.fields == 0 {
+		r.printf("Matching in all fields.\n")
+	} else {
+		r.printf("Matching in %s.\n", strings.Join(r.fields.Names(), ", "))
+	}
+}
+
+// explainCommand turns explanations on or off, or explains the score of a
+// document for the last query.
+func (r *repl) explainCommand(arg string) {
+	switch arg {
+	case "":
+	case "on":
+		r.explain = true
+	case "off":
+		r.explain = false
+	default:
+		if r.query == "" {
+			r.printf("No query to explain.\n")
+			return
+		}
+		explanation, err := r.engine.ExplainRequest(search.SearchRequest{
+			Query:  r.query,
+			Scorer: r.scorer,
+			Fields: r.fields,
+		}, arg)
+		if err != nil {
+			r.printf("Explain error: %v\n", err)
+			return
+		}
+		printExplanation(r.out, explanation)
+		return
+	}
+	if r.explain {
+		r.printf("Explaining scores.\n")
+	} else {
+		r.printf("Not explaining scores.\n")
+	}
+}
+
+// printExplanation writes an explanation to w, indented under a result.
+func printExplanation(w io.Writer, explanation *search.Explanation) {
+	for _, line := range strings.Split(strings.TrimSuffix(explanation.String(), "\n"), "\n") {
+		_, _ = fmt.Fprintf(w, "     %s\n", line)
+	}
+}
+
+// printSuggestions lists the titles completing prefix.
+func (r *repl) printSuggestions(prefix string) {
+	suggestions, err := r.engine.Suggest(prefix, 10)
+	if err != nil {
+		r.printf("Suggest error: %v\n", err)
+		return
+	}
+	if len(suggestions) == 0 {
+		r.printf("No suggestions.\n")
+		return
+	}
+	for i, s := range suggestions {
+		if s.Article != s.Title {
+			r.printf("%d. %s (redirects to %s)\n", i+1, s.Title, s.Article)
+		} else {
+			r.printf("%d. %s\n", i+1, s.Title)
+		}
+	}
+}
+
+func (r *repl) printDoc(docID string) {
+	if docID == "" {
+		r.printf("Usage: :doc <id>\n")
+		return
+	}
+	doc, found := r.engine.Doc(docID)
+	if !found {
+		r.printf("Document %s not found.\n", docID)
+		return
+	}
+	r.printf("DocID: %s\nTitle: %s\n", doc.DocID, doc.Title)
+	if doc.Redirect != "" {
+		r.printf("Redirects to: %s\n", doc.Redirect)
+	}
+	r.printf("Length: %d tokens\nSegment: %s\n", doc.Length, doc.Segment)
+	if abstract, _, err := r.engine.Abstract(docID); err != nil {
+		r.printf("Abstract error: %v\n", err)
+	} else if abstract != "" {
+		r.printf("\n%s\n", abstract)
+	}
+}
+
+func (r *repl) printStats() {
+	stats := r.engine.Stats()
+	r.printf("Documents: %d (%.1f tokens on average)\n", stats.DocCount, stats.AvgDocLength)
+	r.printf("Scorer: %s\n", stats.Scorer)
+	r.printf("Segments:\n")
+	for _, seg := range stats.Segments {
+		r.printf("  %s: %d documents, %d deleted, %d terms", seg.Name, seg.Documents, seg.Deleted, seg.Terms)
+		if seg.Manifest.Source.Name != "" {
+			r.printf(", from %s", seg.Manifest.Source.Name)
+		}
+		r.printf(", built %s\n", seg.Manifest.BuildTime.Format(time.DateTime))
+	}
+}
+
+// loadHistory reads the history saved in path and saves new lines to it.
+// The file only ever grows between sessions, so it is cut back to the
+// lines kept once it holds twice as many.
+func (r *repl) loadHistory(path string) {
+	r.historyPath = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for _, line := range lines[max(len(lines)-maxHistory, 0):] {
+		if line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+	if len(lines) > 2*maxHistory {
+		_ = os.WriteFile(path, []byte(strings.Join(r.history, "\n")+"\n"), 0600)
+	}
+}
+
+// remember adds line to the history, unless it repeats the last line.
+func (r *repl) remember(line string) {
+	if n := len(r.history); n > 0 && r.history[n-1] == line {
+		return
+	}
+	r.history = append(r.history, line)
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+
+	if r.historyPath == "" {
+		return
+	}
+	file, err := os.OpenFile(r.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = fmt.Fprintln(file, line)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		r.printf("History not saved: %v\n", err)
+		r.historyPath = ""
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/PhantomInTheWire/wikifind/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEngine(t *testing.T) *search.SearchEngine {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	for i, title := range []string{"Paris", "Paris Hilton", "Rome"} {
+		docID := string(rune('1' + i))
+		idx.AddDocument(docID, title)
+		idx.Add("pari", docID, indexer.NewPosting(indexer.BODY, i+1))
+	}
+	idx.Add("pari", "1", indexer.NewPosting(indexer.TITLE, 1))
+	idx.Add("pari", "2", indexer.NewPosting(indexer.TITLE, 1))
+	idx.SetAbstract("1", "Paris is the capital of France.")
+	require.NoError(t, indexer.NewIndexWriter(indexPath).WriteIndex(idx))
+
+	engine := search.NewSearchEngine(indexPath)
+	require.NoError(t, engine.Initialize())
+	t.Cleanup(engine.Close)
+	return engine
+}
+
+func runREPL(t *testing.T, r *repl, input string) string {
+	t.Helper()
+	var out strings.Builder
+	r.out = &out
+	r.run(strings.NewReader(input))
+	return out.String()
+}
+
+func TestREPL_Commands(t *testing.T) {
+	r := newREPL(newTestEngine(t), nil)
+
+	out := runREPL(t, r, ":limit 2\nparis\n:next\n:limit 0\n")
+	assert.Contains(t, out, "Showing 2 results per page.")
+	assert.Contains(t, out, "Found 3 results")
+	assert.Contains(t, out, "showing 1-2:")
+	assert.Contains(t, out, "3. DocID: 3")
+	assert.Contains(t, out, `Invalid limit "0".`)
+	assert.Equal(t, 2, r.limit)
+
+	out = runREPL(t, r, ":fields title\nparis\n:fields\n:fields all\n:fields abstract\n")
+	assert.Contains(t, out, "Matching in title.")
+	assert.Contains(t, out, "Found 2 results")
+	assert.Contains(t, out, "Matching in all fields.")
+	assert.Contains(t, out, `unknown field "abstract"`)
+	assert.Equal(t, indexer.FieldMask(0), r.fields)
+
+	out = runREPL(t, r, ":scorer\n:scorer bm25\n:scorer pagerank\n")
+	assert.Contains(t, out, "Ranking with tfidf.")
+	assert.Contains(t, out, "Ranking with bm25f.")
+	assert.Contains(t, out, `unknown scorer "pagerank"`)
+	assert.Equal(t, "bm25f", r.scorer.Name())
+
+	out = runREPL(t, r, ":doc 1\n:doc 9\n:stats\n")
+	assert.Contains(t, out, "Title: Paris\n")
+	assert.Contains(t, out, "Paris is the capital of France.")
+	assert.Contains(t, out, "Document 9 not found.")
+	assert.Contains(t, out, "Documents: 3")
+	assert.Contains(t, out, "Scorer: tfidf")
+	assert.Contains(t, out, "_0: 3 documents, 0 deleted, 1 terms")
+
+	r = newREPL(r.engine, nil)
+	out = runREPL(t, r, ":explain 1\nparis\n:explain 1\n:explain 9\n:explain on\nparis\n:explain off\n")
+	assert.Contains(t, out, "No query to explain.")
+	assert.Contains(t, out, "     0.4823 = score of document 1 with tfidf, sum of\n")
+	assert.Contains(t, out, `Explain error: document not found: 9`)
+	assert.Contains(t, out, "Explaining scores.\n")
+	assert.Contains(t, out, "2. DocID: 1 (Score: 0.4823)\n     0.4823 = score of document 1")
+	assert.Contains(t, out, "Not explaining scores.")
+	assert.False(t, r.explain)
+
+	out = runREPL(t, r, ":suggest par\n:help\n:oops\n")
+	assert.Contains(t, out, "1. Paris")
+	assert.Contains(t, out, ":history")
+	assert.Contains(t, out, "Unknown command :oops")
+}
+
+func TestREPL_History(t *testing.T) {
+	r := newREPL(newTestEngine(t), nil)
+	r.historyPath = filepath.Join(t.TempDir(), historyFile)
+
+	out := runREPL(t, r, "paris\nparis\n  \n:limit 5\n:history\n")
+	assert.Contains(t, out, "    1  paris\n    2  :limit 5\n    3  :history\n")
+
+	resumed := newREPL(r.engine, nil)
+	resumed.loadHistory(r.historyPath)
+	assert.Equal(t, []string{"paris", ":limit 5", ":history"}, resumed.history)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/PhantomInTheWire/wikifind/search"
+)
+
+// Exit codes of non-interactive searches, so that scripts can tell a
+// query without results from one that failed.
+const (
+	exitResults   = 0
+	exitNoResults = 1
+	exitError     = 2
+)
+
+// searchCommand runs "wikifind search" and returns the exit code. Without
+// -q or --queries it starts the interactive prompt.
+func searchCommand(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	query := fs.String("q", "", "run `query` and exit")
+	queries := fs.String("queries", "", "run the queries in `file`, one per line (- for stdin), writing a JSON line each")
+	limit := fs.Int("limit", pageSize, "number of results")
+	offset := fs.Int("offset", 0, "number of results to skip")
+	format := fs.String("format", "text", "output of -q: text, json or tsv")
+	fields := fs.String("fields", "", "match only in these comma-separated `fields`")
+	explain := fs.Bool("explain", false, "show how each score is computed, in text or JSON output")
+	facets := fs.Int("facets", 0, "count the `n` most frequent values of each facet of the matches, in text or JSON output")
+	priorWeight := fs.Float64("prior-weight", search.DefaultPriorWeight, "most the PageRank prior adds to a score, 0 to rank by the query alone")
+	setUsage(fs, "Usage: wikifind search <index_path> [-q query | --queries file] [--limit 10] [--offset 0] [--format text|json|tsv] [--fields title,body] [--explain] [--facets 10] [--prior-weight 1]")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		usage(fs)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	req := search.SearchRequest{Offset: *offset, Limit: *limit, Explain: *explain, FacetLimit: *facets}
+	if *fields != "" {
+		if req.Fields, err = indexer.ParseFields(*fields); err != nil {
+			fail(err)
+		}
+	}
+	switch {
+	case set["q"] && set["queries"]:
+		fail(fmt.Errorf("-q and --queries cannot be combined"))
+	case set["queries"] && set["format"] && *format != "json":
+		fail(fmt.Errorf("--queries always writes JSON lines"))
+	case *format != "text" && *format != "json" && *format != "tsv":
+		fail(fmt.Errorf("unknown format %q", *format))
+	case *explain && *format == "tsv":
+		fail(fmt.Errorf("--explain cannot be written as TSV"))
+	case *facets != 0 && *format == "tsv":
+		fail(fmt.Errorf("--facets cannot be written as TSV"))
+	}
+
+	if !set["q"] && !set["queries"] {
+		fmt.Println("Initializing search engine...")
+	}
+	engine := search.NewSearchEngine(positional[0])
+	engine.SetPriorWeight(*priorWeight)
+	if err := engine.Initialize(); err != nil {
+		fail(fmt.Errorf("initializing search engine: %w", err))
+	}
+	defer engine.Close()
+
+	switch {
+	case set["q"]:
+		req.Query = *query
+		return searchOnce(engine, req, *format, os.Stdout)
+	case set["queries"]:
+		in := io.Reader(os.Stdin)
+		if *queries != "-" {
+			file, err := os.Open(*queries)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitError
+			}
+			defer func() { _ = file.Close() }()
+			in = file
+		}
+		return searchBatch(engine, req, in, os.Stdout)
+	default:
+		fmt.Println("Search engine ready. Enter queries (Ctrl+C to exit):")
+		newREPL(engine, os.Stdout).run(os.Stdin)
+		return exitResults
+	}
+}
+
+// fail reports err and exits with exitError. It is for errors found before
+// anything needs cleaning up.
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(exitError)
+}
+
+// searchOnce runs req and writes the results to out in format, returning
+// the exit code.
+func searchOnce(engine *search.SearchEngine, req search.SearchRequest, format string, out io.Writer) int {
+	resp, err := engine.Execute(context.Background(), req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Search error: %v\n", err)
+		return exitError
+	}
+
+	switch format {
+	case "json":
+		body, err := engine.JSON(req, resp)
+		if err == nil {
+			err = json.NewEncoder(out).Encode(body)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+	case "tsv":
+		for i, result := range resp.Results {
+			doc, _ := engine.Doc(result.DocID)
+			_, _ = fmt.Fprintf(out, "%d\t%s\t%.4f\t%s\n", req.Offset+i+1, result.DocID, result.Score, doc.Title)
+		}
+	default:
+		printResults(out, resp, req.Offset)
+	}
+
+	if len(resp.Results) == 0 {
+		return exitNoResults
+	}
+	return exitResults
+}
+
+// searchBatch runs every non-blank line of in as the query of req and
+// writes one JSON line per query to out. A failed query gets a line with
+// its error, and the exit code reports the worst outcome.
+func searchBatch(engine *search.SearchEngine, req search.SearchRequest, in io.Reader, out io.Writer) int {
+	encoder := json.NewEncoder(out)
+	code := exitNoResults
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		req.Query = strings.TrimSpace(scanner.Text())
+		if req.Query == "" {
+			continue
+		}
+
+		var line any
+		resp, err := engine.Execute(context.Background(), req)
+		var body *search.JSONResponse
+		if err == nil {
+			body, err = engine.JSON(req, resp)
+		}
+		if err != nil {
+			line = jsonError{Query: req.Query, Error: err.Error()}
+			code = exitError
+		} else {
+			line = body
+			if len(resp.Results) > 0 && code == exitNoResults {
+				code = exitResults
+			}
+		}
+		if err := encoder.Encode(line); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading queries: %v\n", err)
+		return exitError
+	}
+	return code
+}
+
+type jsonError struct {
+	Query string `json:"query"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/PhantomInTheWire/wikifind/search"
+	"github.com/PhantomInTheWire/wikifind/server"
+)
+
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	timeout := fs.Duration("timeout", server.DefaultTimeout, "time limit of a search")
+	priorWeight := fs.Float64("prior-weight", search.DefaultPriorWeight, "most the PageRank prior adds to a score, 0 to rank by the query alone")
+	setUsage(fs, "Usage: wikifind serve <index_path> [--addr :8080] [--timeout 10s] [--prior-weight 1]")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		usage(fs)
+	}
+
+	engine := search.NewSearchEngine(positional[0])
+	engine.SetPriorWeight(*priorWeight)
+	if err := engine.Initialize(); err != nil {
+		log.Fatalf("Error initializing search engine: %v", err)
+	}
+	defer engine.Close()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("Error listening on %s: %v", *addr, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.New(engine)
+	srv.SetTimeout(*timeout)
+
+	fmt.Printf("Serving %s on http://%s\n", positional[0], listener.Addr())
+	if err := srv.Serve(ctx, listener); err != nil {
+		log.Fatalf("Error serving: %v", err)
+	}
+	fmt.Println("Server stopped.")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+func stats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	top := fs.Int("top", 20, "number of most common terms to list")
+	format := fs.String("format", "text", "output: text or json")
+	setUsage(fs, "Usage: wikifind stats <index_path> [--top 20] [--format text|json]")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 || *top < 0 || (*format != "text" && *format != "json") {
+		usage(fs)
+	}
+
+	summary, err := indexer.Summarize(positional[0], *top)
+	if err != nil {
+		fail(fmt.Errorf("reading index: %w", err))
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(summary)
+	} else {
+		err = printSummary(os.Stdout, positional[0], summary)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// printSummary writes summary of the index at indexPath to w as text.
+func printSummary(w io.Writer, indexPath string, summary *indexer.IndexSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	p := func(format string, args ...any) {
+		_, _ = fmt.Fprintf(tw, format, args...)
+	}
+
+	p("Index: %s (commit %d)\n", indexPath, summary.Generation)
+	p("Documents: %d live, %d deleted or superseded, %.1f tokens on average\n",
+		summary.Documents, summary.Deleted, summary.AvgDocLength)
+	p("Terms: %d unique, %d postings\n", summary.UniqueTerms, summary.TotalPostings)
+	p("Size: %s\n", formatSize(summary.TotalSize))
+	if built := summary.BuildTime(); !built.IsZero() {
+		p("Built: %s\n", built.Format(time.DateTime))
+	}
+
+	p("\nSegments:\n")
+	for _, seg := range summary.Segments {
+		p("  %s\t%d documents\t%d deleted\t%d terms\t%s", seg.Name, seg.Documents, seg.Deleted, seg.Terms, formatSize(seg.Size))
+		m := seg.Manifest
+		p("\tformat %d, %s stemmer, built %s", m.FormatVersion, m.Analyzer.Stemmer, m.BuildTime.Format(time.DateTime))
+		if m.Source.Name != "" {
+			p(" from %s", m.Source.Name)
+		}
+		p("\n")
+	}
+
+	if len(summary.TopTerms) > 0 {
+		p("\nMost common terms:\n")
+		for i, term := range summary.TopTerms {
+			p("  %d.\t%s\t%d documents\n", i+1, term.Term, term.DocFreq)
+		}
+	}
+
+	p("\nPosting list lengths:\n")
+	for _, bucket := range summary.PostingLengths {
+		postings := fmt.Sprint(bucket.Min)
+		if bucket.Max > bucket.Min {
+			postings += fmt.Sprintf("-%d", bucket.Max)
+		}
+		p("  %s\t%d terms\t%s\n", postings, bucket.Terms, bar(bucket.Terms, summary.UniqueTerms))
+	}
+
+	p("\nFields:\n")
+	for _, field := range summary.Fields {
+		p("  %s\t%d terms\t%d postings\t%d occurrences\n", field.Name, field.Terms, field.Postings, field.Occurrences)
+	}
+
+	p("\nFiles:\n")
+	for _, file := range summary.Files {
+		p("  %s\t%s\n", file.Path, formatSize(file.Size))
+	}
+	return tw.Flush()
+}
+
+// bar draws n out of total as a row of up to 40 marks.
+func bar(n, total int) string {
+	if total == 0 {
+		return ""
+	}
+	return strings.Repeat("#", (n*40+total-1)/total)
+}
+
+// formatSize formats a number of bytes with a binary unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "0 B", formatSize(0))
+	assert.Equal(t, "1023 B", formatSize(1023))
+	assert.Equal(t, "1.0 KiB", formatSize(1024))
+	assert.Equal(t, "1.5 MiB", formatSize(3<<19))
+	assert.Equal(t, "2.0 GiB", formatSize(2<<30))
+}
+
+func TestPrintSummary(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	idx.AddDocument("1", "Paris")
+	idx.Add("pari", "1", indexer.NewPosting(indexer.TITLE, 1))
+	idx.Add("franc", "1", indexer.NewPosting(indexer.BODY, 2))
+	idx.AddDocument("2", "Lyon")
+	idx.Add("franc", "2", indexer.NewPosting(indexer.BODY, 1))
+	require.NoError(t, indexer.NewIndexWriter(indexPath).WriteIndex(idx))
+
+	summary, err := indexer.Summarize(indexPath, 1)
+	require.NoError(t, err)
+	var out strings.Builder
+	require.NoError(t, printSummary(&out, indexPath, summary))
+
+	assert.Contains(t, out.String(), "Documents: 2 live, 0 deleted or superseded, 2.0 tokens on average\n")
+	assert.Contains(t, out.String(), "Terms: 2 unique, 3 postings\n")
+	assert.Contains(t, out.String(), "  1.  franc  2 documents\n")
+	assert.NotContains(t, out.String(), "pari  1 documents")
+	assert.Contains(t, out.String(), "  2-3  1 terms  ####################\n")
+	assert.Contains(t, out.String(), "  body      1 terms  2 postings  3 occurrences\n")
+	assert.Contains(t, out.String(), "_0/postings.dat")
+}
@@ -0,0 +1,24 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd
+
+package main
+
+import "errors"
+
+// termState is the terminal mode to restore after reading a line.
+type termState struct{}
+
+// isTerminal reports whether fd is a terminal. Line editing needs the
+// termios ioctls of Linux, macOS, FreeBSD, NetBSD and DragonFly, made
+// through the syscall package; elsewhere, Windows and OpenBSD included,
+// the REPL reads plain lines.
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (*termState, error) {
+	return nil, errors.New("raw terminal mode not supported")
+}
+
+func restore(fd int, state *termState) error {
+	return nil
+}
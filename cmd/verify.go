@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+func verify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	setUsage(fs, "Usage: wikifind verify <index_path>")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != 1 {
+		usage(fs)
+	}
+
+	report, err := indexer.Verify(positional[0])
+	if err != nil {
+		log.Fatalf("Error reading index: %v", err)
+	}
+	printReport(os.Stdout, positional[0], report)
+	if len(report.Problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// printReport writes what verifying the index at indexPath found.
+func printReport(w io.Writer, indexPath string, report *indexer.VerifyReport) {
+	_, _ = fmt.Fprintf(w, "Index: %s (commit %d)\n", indexPath, report.Generation)
+	_, _ = fmt.Fprintf(w, "Checked %d segments, %d documents, %d terms, %d postings\n",
+		report.Segments, report.Documents, report.Terms, report.Postings)
+	if len(report.Problems) == 0 {
+		_, _ = fmt.Fprintln(w, "OK")
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%d problems:\n", len(report.Problems))
+	for _, problem := range report.Problems {
+		_, _ = fmt.Fprintf(w, "  %v\n", problem)
+	}
+}
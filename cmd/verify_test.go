@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintReport(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	idx.AddDocument("1", "Paris")
+	idx.Add("pari", "1", indexer.NewPosting(indexer.TITLE, 1))
+	require.NoError(t, indexer.NewIndexWriter(indexPath).WriteIndex(idx))
+
+	report, err := indexer.Verify(indexPath)
+	require.NoError(t, err)
+	var out strings.Builder
+	printReport(&out, indexPath, report)
+	assert.Equal(t, "Index: "+indexPath+" (commit 1)\nChecked 1 segments, 1 documents, 1 terms, 1 postings\nOK\n", out.String())
+
+	path := filepath.Join(indexer.SegmentPath(indexPath, "_0"), indexer.DocsFile)
+	require.NoError(t, os.WriteFile(path, []byte("1\t1\tLyons\n"), 0644))
+	report, err = indexer.Verify(indexPath)
+	require.NoError(t, err)
+	out.Reset()
+	printReport(&out, indexPath, report)
+	assert.Contains(t, out.String(), "1 problems:\n  corrupt index file "+path+": checksum ")
+}
@@ -0,0 +1,175 @@
+package indexer
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// AbstractsFile holds the opening text of every document of a segment, as
+// plain text without wiki markup, for result snippets. It is a table file
+// (see writeTable) with one entry per document in ordinal order, the entry
+// being the text itself; redirects have an empty abstract.
+const AbstractsFile = "abstracts.dat"
+
+// AbstractLength is the number of characters kept of a page's text.
+const AbstractLength = 300
+
+// TextFile holds the plain text of every document of a segment, up to
+// TextLength characters, so that snippets can show the passages a query
+// matches. It is a table file with one entry per document in ordinal
+// order, the entry being the text compressed with DEFLATE; redirects have
+// an empty entry.
+const TextFile = "text.dat"
+
+// TextLength is the number of characters of a page's text kept for
+// snippets.
+const TextLength = 5000
+
+var (
+	abstractsMagic = []byte("WFAB")
+	textMagic      = []byte("WFTX")
+)
+
+// textWriters reuses compressors, which are costly to allocate.
+var textWriters = sync.Pool{New: func() any {
+	w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+	return w
+}}
+
+var (
+	abstractComments  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	abstractRefs      = regexp.MustCompile(`(?is)<ref[^>/]*/>|<ref[^>]*>.*?</ref>`)
+	abstractTemplates = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+	abstractTables    = regexp.MustCompile(`(?s)\{\|.*?\|\}`)
+	abstractTags      = regexp.MustCompile(`<[^>]*>`)
+	abstractMedia     = regexp.MustCompile(`(?i)\[\[(?:category|file|image):[^\[\]]*(?:\[\[[^\]]*\]\][^\[\]]*)*\]\]`)
+	abstractLinks     = regexp.MustCompile(`\[\[(?:[^\]|]*\|)?([^\]|]*)\]\]`)
+	abstractExternal  = regexp.MustCompile(`\[(?:https?:)?//[^\s\]]*\s*([^\]]*)\]`)
+	abstractHeadings  = regexp.MustCompile(`(?m)^=+[^=\n]*=+\s*$`)
+	abstractLists     = regexp.MustCompile(`(?m)^[*#:;]+`)
+	abstractQuotes    = regexp.MustCompile(`'{2,}`)
+)
+
+// Abstract returns the first AbstractLength characters of the plain text
+// of a wiki page, cut at a word boundary.
+func Abstract(wikiText string) string {
+	return TruncateText(PlainText(wikiText), AbstractLength)
+}
+
+// PlainText returns the text of a wiki page without its markup, with runs
+// of white space collapsed to single spaces.
+func PlainText(wikiText string) string {
+	text := abstractComments.ReplaceAllString(wikiText, "")
+	text = abstractRefs.ReplaceAllString(text, "")
+	for {
+		// Strip nested templates from the inside out.
+		stripped := abstractTemplates.ReplaceAllString(text, "")
+		if stripped == text {
+			break
+		}
+		text = stripped
+	}
+	text = abstractTables.ReplaceAllString(text, "")
+	text = abstractTags.ReplaceAllString(text, "")
+	text = abstractMedia.ReplaceAllString(text, "")
+	text = abstractLinks.ReplaceAllString(text, "$1")
+	text = abstractExternal.ReplaceAllString(text, "$1")
+	text = abstractHeadings.ReplaceAllString(text, "")
+	text = abstractLists.ReplaceAllString(text, "")
+	text = abstractQuotes.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// TruncateText returns the first n characters of text, cut at a word
+// boundary.
+func TruncateText(text string, n int) string {
+	chars := 0
+	for i := range text {
+		if chars == n {
+			// Drop the word cut in half, unless it is the only one.
+			if text[i] != ' ' {
+				if space := strings.LastIndexByte(text[:i], ' '); space > 0 {
+					i = space
+				}
+			}
+			return text[:i]
+		}
+		chars++
+	}
+	return text
+}
+
+// writeAbstractsFile writes the abstracts of the documents of index, in
+// ordinal order.
+func writeAbstractsFile(dir string, index *InvertedIndex, docIDs []string) error {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return writeTable(filepath.Join(dir, AbstractsFile), abstractsMagic, len(docIDs), func(i int, buf []byte) []byte {
+		return append(buf, index.Abstracts[docIDs[i]]...)
+	})
+}
+
+// compressText compresses the text of a document for TextFile.
+func compressText(text string) []byte {
+	if text == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	w := textWriters.Get().(*flate.Writer)
+	defer textWriters.Put(w)
+	w.Reset(&buf)
+	_, _ = io.WriteString(w, text)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+// writeTextFile writes the compressed texts of the documents of index, in
+// ordinal order.
+func writeTextFile(dir string, index *InvertedIndex, docIDs []string) error {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return writeTable(filepath.Join(dir, TextFile), textMagic, len(docIDs), func(i int, buf []byte) []byte {
+		return append(buf, index.Texts[docIDs[i]]...)
+	})
+}
+
+// compressedText returns the entry of TextFile of the document with
+// ordinal ord, which aliases the mapped file.
+func (r *SegmentReader) compressedText(ord uint32) ([]byte, error) {
+	if int(ord) >= r.text.numEntries {
+		return nil, NewCorruptIndexError(r.text.path, "missing entry")
+	}
+	return r.text.entry(int(ord))
+}
+
+// Text returns the plain text stored for the document with ordinal ord.
+func (r *SegmentReader) Text(ord uint32) (string, error) {
+	raw, err := r.compressedText(ord)
+	if err != nil || len(raw) == 0 {
+		return "", err
+	}
+	text, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return "", NewCorruptIndexError(r.text.path, "malformed entry")
+	}
+	return string(text), nil
+}
+
+// Abstract returns the abstract of the document with ordinal ord.
+func (r *SegmentReader) Abstract(ord uint32) (string, error) {
+	if int(ord) >= r.abstracts.numEntries {
+		return "", NewCorruptIndexError(r.abstracts.path, "missing entry")
+	}
+	raw, err := r.abstracts.entry(int(ord))
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,128 @@
+package indexer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAbstract(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"plain", "Paris is the capital of France.", "Paris is the capital of France."},
+		{
+			"markup",
+			"{{Infobox settlement\n| name = {{lang|fr|Paris}}\n}}\n'''Paris''' is the [[capital city|capital]] of [[France]].<ref name=\"a\">Source</ref><ref name=\"b\"/>",
+			"Paris is the capital of France.",
+		},
+		{
+			"media and headings",
+			"[[File:Paris.jpg|thumb|A view of [[Paris]]]]\nIntro.\n\n== History ==\n* First\n* Second\n[[Category:Capitals in Europe]]",
+			"Intro. First Second",
+		},
+		{"external links", "See [https://example.org the site] and <!-- hidden -->more.", "See the site and more."},
+		{"tables", "{| class=wikitable\n|-\n| cell\n|}\nAfter.", "After."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Abstract(tt.text))
+		})
+	}
+}
+
+func TestAbstract_Truncate(t *testing.T) {
+	text := strings.Repeat("é word ", 100)
+	abstract := Abstract(text)
+	assert.LessOrEqual(t, utf8.RuneCountInString(abstract), AbstractLength)
+	assert.True(t, strings.HasSuffix(abstract, " word") || strings.HasSuffix(abstract, " é"), abstract)
+	assert.True(t, strings.HasPrefix(text, abstract))
+
+	long := strings.Repeat("x", AbstractLength+10)
+	assert.Equal(t, long[:AbstractLength], Abstract(long))
+}
+
+func TestSegmentReader_Abstract(t *testing.T) {
+	idx := NewInvertedIndex()
+	idx.AddDocument("1", "Paris")
+	idx.SetAbstract("1", "Paris is the capital of France.")
+	idx.AddDocument("2", "Paname")
+	idx.SetRedirect("2", "Paris")
+
+	r, err := OpenSegmentReader(writeTestSegment(t, idx))
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	abstract, err := r.Abstract(0)
+	require.NoError(t, err)
+	assert.Equal(t, "Paris is the capital of France.", abstract)
+
+	abstract, err = r.Abstract(1)
+	require.NoError(t, err)
+	assert.Equal(t, "", abstract)
+
+	_, err = r.Abstract(2)
+	var wikiErr *WikiError
+	require.ErrorAs(t, err, &wikiErr)
+	assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+}
+
+func TestTruncateText(t *testing.T) {
+	assert.Equal(t, "Paris is", TruncateText("Paris is the capital", 10))
+	assert.Equal(t, "Paris", TruncateText("Paris", 10))
+	assert.Equal(t, "Paris", TruncateText("Parisienne", 5))
+}
+
+func TestSegmentReader_Text(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	text := strings.Repeat("Paris is the capital of France. ", 50) + "The Seine flows through it."
+
+	idx := NewInvertedIndex()
+	idx.AddDocument("1", "Paris")
+	idx.SetText("1", text)
+	idx.AddDocument("2", "Paname")
+	idx.SetRedirect("2", "Paris")
+	writer := NewIndexWriter(indexPath)
+	require.NoError(t, writer.WriteIndex(idx))
+
+	check := func() {
+		t.Helper()
+		r, err := OpenIndexReader(indexPath)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, r.Close()) }()
+		require.Len(t, r.Segments, 1)
+		segment := r.Segments[0]
+
+		ord, ok := segment.Ordinal("1")
+		require.True(t, ok)
+		stored, err := segment.Text(ord)
+		require.NoError(t, err)
+		assert.Equal(t, text, stored)
+
+		ord, ok = segment.Ordinal("2")
+		require.True(t, ok)
+		stored, err = segment.Text(ord)
+		require.NoError(t, err)
+		assert.Empty(t, stored)
+
+		_, err = segment.Text(uint32(segment.DocCount()))
+		var wikiErr *WikiError
+		require.ErrorAs(t, err, &wikiErr)
+		assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+	}
+	check()
+
+	// Texts survive merges.
+	extra := NewInvertedIndex()
+	extra.AddDocument("3", "Lyon")
+	require.NoError(t, writer.AppendIndex(extra))
+	require.NoError(t, writer.Compact())
+	check()
+}
@@ -0,0 +1,122 @@
+package indexer
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// AnchorsFile holds the links of every document of a segment with their
+// anchor texts, so that a merge can rebuild the anchor text of the
+// articles from the links of all the segments it joins. It is a table file
+// (see writeTable) with one entry per document in ordinal order, the entry
+// being the anchors as LinkAnchors returns them, each a target and its
+// text separated by a tab, separated by newlines.
+const AnchorsFile = "anchors.dat"
+
+var anchorsMagic = []byte("WFAN")
+
+// writeAnchorsFile writes the anchors of the documents of index, in
+// ordinal order.
+func writeAnchorsFile(dir string, index *InvertedIndex, docIDs []string) error {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return writeTable(filepath.Join(dir, AnchorsFile), anchorsMagic, len(docIDs), func(i int, buf []byte) []byte {
+		for j, anchor := range index.Anchors[docIDs[i]] {
+			if j > 0 {
+				buf = append(buf, '\n')
+			}
+			buf = append(buf, anchor.Target...)
+			buf = append(buf, '\t')
+			buf = append(buf, anchor.Text...)
+		}
+		return buf
+	})
+}
+
+// Anchors returns the links of the document with ordinal ord with their
+// anchor texts, as LinkAnchors gives them.
+func (r *SegmentReader) Anchors(ord uint32) ([]Anchor, error) {
+	if int(ord) >= r.anchors.numEntries {
+		return nil, NewCorruptIndexError(r.anchors.path, "missing entry")
+	}
+	raw, err := r.anchors.entry(int(ord))
+	if err != nil || len(raw) == 0 {
+		return nil, err
+	}
+	var anchors []Anchor
+	for line := range strings.SplitSeq(string(raw), "\n") {
+		target, text, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, NewCorruptIndexError(r.anchors.path, "malformed anchor")
+		}
+		anchors = append(anchors, Anchor{Target: target, Text: text})
+	}
+	return anchors, nil
+}
+
+// indexAnchors rebuilds the ANCHOR field of the articles of index from the
+// anchor texts of the links between its pages, through redirects. Links
+// from a page to itself are left out. Pages of other segments are not
+// read, so their links reach the articles of index only once a merge
+// rewrites both in one segment.
+func indexAnchors(index *InvertedIndex) {
+	clearField(index, ANCHOR)
+
+	index.mutex.RLock()
+	titles := newTitleResolver()
+	docIDs := sortedKeys(index.Docs)
+	for _, docID := range docIDs {
+		titles.add(docID, index.Docs[docID])
+	}
+	texts := make(map[string][]string)
+	for _, source := range docIDs {
+		for _, anchor := range index.Anchors[source] {
+			if docID, ok := titles.resolve(anchor.Target); ok && docID != source {
+				texts[docID] = append(texts[docID], anchor.Text)
+			}
+		}
+	}
+	index.mutex.RUnlock()
+
+	for _, docID := range sortedKeys(texts) {
+		terms, forms := ParseAnchors(texts[docID])
+		for term, posting := range terms {
+			index.Add(term, docID, posting)
+		}
+		for term, form := range forms {
+			// Anchor text only names the terms it alone brings in, so
+			// that rebuilding it does not tip the forms of the others.
+			if !index.hasForm(term) {
+				index.AddForm(term, form, 1)
+			}
+		}
+	}
+}
+
+// clearField removes field from the postings of index, dropping the
+// postings and terms left empty.
+func clearField(index *InvertedIndex, field FieldMask) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	i := fieldIndex(field)
+	for term, postings := range index.Index {
+		for docID, posting := range postings {
+			if posting.Fields&field == 0 {
+				continue
+			}
+			posting.Frequency -= int(posting.FieldFreqs[i])
+			posting.FieldFreqs[i] = 0
+			posting.Fields &^= field
+			if posting.Fields == 0 {
+				delete(postings, docID)
+			} else {
+				postings[docID] = posting
+			}
+		}
+		if len(postings) == 0 {
+			delete(index.Index, term)
+		}
+	}
+}
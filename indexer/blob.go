@@ -0,0 +1,93 @@
+package indexer
+
+import (
+	"io"
+	"os"
+)
+
+// blob gives random read access to an immutable segment file.
+type blob interface {
+	// Bytes returns n bytes starting at off. Memory-mapped blobs return a
+	// view into the mapping without copying; it stays valid until Close.
+	Bytes(off, n int64) ([]byte, error)
+	Size() int64
+	Close() error
+}
+
+// openBlob memory-maps path where the platform supports it and falls back
+// to positional reads otherwise.
+func openBlob(path string) (blob, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if b, err := mmapFile(file, info.Size()); err == nil {
+		_ = file.Close()
+		return b, nil
+	}
+	return &readerAtBlob{file: file, size: info.Size()}, nil
+}
+
+// bytesBlob serves a file that is held in memory, such as an empty or
+// memory-mapped one.
+type bytesBlob struct {
+	data    []byte
+	release func() error
+}
+
+func (b *bytesBlob) Bytes(off, n int64) ([]byte, error) {
+	if off < 0 || n < 0 || off+n > int64(len(b.data)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b.data[off : off+n : off+n], nil
+}
+
+func (b *bytesBlob) Size() int64 {
+	return int64(len(b.data))
+}
+
+func (b *bytesBlob) Close() error {
+	if b.release == nil {
+		return nil
+	}
+	release := b.release
+	b.release = nil
+	b.data = nil
+	return release()
+}
+
+// readerAtBlob copies the requested range out of the file with ReadAt,
+// which is safe for concurrent use.
+type readerAtBlob struct {
+	file io.ReaderAt
+	size int64
+}
+
+func (b *readerAtBlob) Bytes(off, n int64) ([]byte, error) {
+	if off < 0 || n < 0 || off+n > b.size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, n)
+	if _, err := b.file.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (b *readerAtBlob) Size() int64 {
+	return b.size
+}
+
+func (b *readerAtBlob) Close() error {
+	if closer, ok := b.file.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package indexer
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CategoriesFile holds the exact names of the categories every document
+// of a segment is in. It is a table file (see writeTable) with one entry
+// per document in ordinal order, the entry being the names, as
+// CategoryNames returns them, separated by newlines. The documents in each
+// category are listed in MembersFile, with the other facets.
+const CategoriesFile = "categories.dat"
+
+var categoriesMagic = []byte("WFCT")
+
+// CategoryNamespace is the prefix of the titles of category pages.
+const CategoryNamespace = "Category:"
+
+var categoryLinkRegex = regexp.MustCompile(`(?i)\[\[\s*category\s*:\s*([^\[\]|]+)`)
+
+// CategoryNames returns the names of the categories the wiki text puts its
+// page in, in the order they appear and without duplicates. Sort keys are
+// dropped and names are written as MediaWiki shows them, with spaces for
+// underscores and the first letter upper cased, so "[[Category:nobel
+// laureates_in Physics|Einstein]]" is in "Nobel laureates in Physics".
+// Links to a category page, such as "[[:Category:Physics]]", are left out.
+func CategoryNames(wikiText string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, loc := range categoryLinkRegex.FindAllStringSubmatchIndex(wikiText, -1) {
+		if strings.HasPrefix(strings.TrimSpace(wikiText[loc[0]+2:loc[2]]), ":") {
+			continue
+		}
+		name := categoryName(wikiText[loc[2]:loc[3]])
+		if key := TitleKey(name); key != "" && !seen[key] {
+			seen[key] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// categoryName normalizes the spacing and case of a category name.
+func categoryName(name string) string {
+	name = strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || unicode.IsSpace(r)
+	}), " ")
+	first, size := utf8.DecodeRuneInString(name)
+	if size == 0 {
+		return ""
+	}
+	return string(unicode.ToUpper(first)) + name[size:]
+}
+
+// CategoryPage returns the name of the category described by a page
+// titled title, and false if the page is not a category page.
+func CategoryPage(title string) (string, bool) {
+	if len(title) < len(CategoryNamespace) || !strings.EqualFold(title[:len(CategoryNamespace)], CategoryNamespace) {
+		return "", false
+	}
+	name := categoryName(title[len(CategoryNamespace):])
+	return name, name != ""
+}
+
+// writeCategoriesFile writes the categories of the documents of index, in
+// ordinal order.
+func writeCategoriesFile(dir string, index *InvertedIndex, docIDs []string) error {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return writeTable(filepath.Join(dir, CategoriesFile), categoriesMagic, len(docIDs), func(i int, buf []byte) []byte {
+		for j, name := range index.Categories[docIDs[i]] {
+			if j > 0 {
+				buf = append(buf, '\n')
+			}
+			buf = append(buf, name...)
+		}
+		return buf
+	})
+}
+
+// Categories returns the names of the categories of the document with
+// ordinal ord.
+func (r *SegmentReader) Categories(ord uint32) ([]string, error) {
+	if int(ord) >= r.categories.numEntries {
+		return nil, NewCorruptIndexError(r.categories.path, "missing entry")
+	}
+	raw, err := r.categories.entry(int(ord))
+	if err != nil || len(raw) == 0 {
+		return nil, err
+	}
+	return strings.Split(string(raw), "\n"), nil
+}
+
+// CategoryGraph links categories to their parents and subcategories, as
+// the categories of their category pages give them. Categories are keyed
+// by name normalized with TitleKey.
+type CategoryGraph struct {
+	parents  map[string][]string
+	children map[string][]string
+	names    map[string]string
+}
+
+func NewCategoryGraph() *CategoryGraph {
+	return &CategoryGraph{
+		parents:  make(map[string][]string),
+		children: make(map[string][]string),
+		names:    make(map[string]string),
+	}
+}
+
+// AddCategory records that the category named name is in the parent
+// categories. A category listed twice keeps the parents of the first.
+func (g *CategoryGraph) AddCategory(name string, parents []string) {
+	key := TitleKey(name)
+	if _, ok := g.names[key]; ok {
+		return
+	}
+	g.names[key] = name
+	for _, parent := range parents {
+		parentKey := TitleKey(parent)
+		if parentKey == key {
+			continue
+		}
+		g.parents[key] = append(g.parents[key], parent)
+		g.children[parentKey] = append(g.children[parentKey], name)
+	}
+}
+
+// Len returns the number of category pages in the graph.
+func (g *CategoryGraph) Len() int {
+	return len(g.names)
+}
+
+// Parents returns the names of the categories the category named name is
+// in.
+func (g *CategoryGraph) Parents(name string) []string {
+	return g.parents[TitleKey(name)]
+}
+
+// Subcategories returns the names of the categories in the category named
+// name, and in those down to depth levels, in breadth-first order. The
+// category itself comes first, and cycles are followed once.
+func (g *CategoryGraph) Subcategories(name string, depth int) []string {
+	seen := map[string]bool{TitleKey(name): true}
+	names := []string{name}
+	level := names
+	for ; depth > 0 && len(level) > 0; depth-- {
+		var next []string
+		for _, parent := range level {
+			children := append([]string(nil), g.children[TitleKey(parent)]...)
+			sort.Strings(children)
+			for _, child := range children {
+				if key := TitleKey(child); !seen[key] {
+					seen[key] = true
+					next = append(next, child)
+				}
+			}
+		}
+		names = append(names, next...)
+		level = next
+	}
+	return names
+}
+
+// AddCategoryPages adds the category pages of the segment to graph,
+// leaving out redirects and the documents for which skip returns true.
+func (r *SegmentReader) AddCategoryPages(graph *CategoryGraph, skip func(ord uint32) bool) error {
+	for ord, info := range r.docs {
+		name, ok := CategoryPage(info.Title)
+		if !ok || info.Redirect != "" || skip(uint32(ord)) {
+			continue
+		}
+		parents, err := r.Categories(uint32(ord))
+		if err != nil {
+			return err
+		}
+		graph.AddCategory(name, parents)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package indexer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategoryNames(t *testing.T) {
+	assert.Equal(t, []string{"Nobel laureates in Physics", "Physicists", "ßwiss people"}, CategoryNames(
+		"[[Category:nobel laureates_in  Physics|Einstein]] [[category: Physicists]] [[Category:Physicists]] "+
+			"see [[:Category:Chemists]] [[Category:ßwiss people]] [[Category: ]] [[Paris]]"))
+	assert.Empty(t, CategoryNames("no categories"))
+}
+
+func TestCategoryPage(t *testing.T) {
+	name, ok := CategoryPage("Category:nobel_laureates")
+	assert.True(t, ok)
+	assert.Equal(t, "Nobel laureates", name)
+	name, ok = CategoryPage("category:Physics")
+	assert.True(t, ok)
+	assert.Equal(t, "Physics", name)
+
+	for _, title := range []string{"Physics", "Category:", "Cat"} {
+		_, ok := CategoryPage(title)
+		assert.False(t, ok, title)
+	}
+}
+
+func TestSegmentReader_Categories(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	const dump = `<mediawiki>
+<page><title>Albert Einstein</title><id>1</id><revision><text>physicist [[Category:Physicists]] [[Category:Nobel laureates in Physics]]</text></revision></page>
+<page><title>Einstein</title><id>2</id><redirect title="Albert Einstein" /><revision><text>#REDIRECT [[Albert Einstein]] [[Category:Physicists]]</text></revision></page>
+<page><title>Marie Curie</title><id>3</id><revision><text>chemist [[Category:physicists]]</text></revision></page>
+</mediawiki>`
+	require.NoError(t, NewWikiXMLParser(indexPath).ParseReader(context.Background(), strings.NewReader(dump)))
+
+	r, err := OpenSegmentReader(SegmentPath(indexPath, "_0"))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, r.Close()) }()
+
+	categories, err := r.Categories(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Physicists", "Nobel laureates in Physics"}, categories)
+	categories, err = r.Categories(1)
+	require.NoError(t, err)
+	assert.Empty(t, categories, "redirects have no categories")
+
+	members, err := r.CategoryMembers("physicists")
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{0, 2}, members)
+	members, err = r.CategoryMembers("Nobel_laureates in physics")
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{0}, members)
+	members, err = r.CategoryMembers("Chemists")
+	require.NoError(t, err)
+	assert.Empty(t, members)
+
+	_, err = r.Categories(3)
+	var wikiErr *WikiError
+	require.ErrorAs(t, err, &wikiErr)
+	assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+}
+
+func TestCategoryGraph(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := NewInvertedIndex()
+	for docID, page := range map[string]struct {
+		title   string
+		parents []string
+	}{
+		"1": {"Category:Physicists", []string{"Scientists"}},
+		"2": {"Category:Scientists", []string{"People", "Scientists"}},
+		"3": {"Category:Nobel laureates in Physics", []string{"Physicists", "Nobel laureates"}},
+		"4": {"Category:People", []string{"Physicists"}},
+		"5": {"Albert Einstein", []string{"Physicists"}},
+		"6": {"Category:Chemists", []string{"Scientists"}},
+	} {
+		idx.AddDocument(docID, page.title)
+		idx.SetCategories(docID, page.parents)
+	}
+	writer := NewIndexWriter(indexPath)
+	require.NoError(t, writer.WriteIndex(idx))
+	_, _, err := writer.Delete("6")
+	require.NoError(t, err)
+
+	r, err := OpenIndexReader(indexPath)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, r.Close()) }()
+	graph := NewCategoryGraph()
+	for i, segment := range r.Segments {
+		require.NoError(t, segment.AddCategoryPages(graph, func(ord uint32) bool { return r.IsDead(i, ord) }))
+	}
+
+	assert.Equal(t, 4, graph.Len(), "deleted pages and articles are left out")
+	assert.Equal(t, []string{"People"}, graph.Parents("scientists"), "a category is not its own parent")
+	assert.Equal(t, []string{"Physicists"}, graph.Subcategories("Physicists", 0))
+	assert.Equal(t, []string{"Physicists", "Nobel laureates in Physics", "People"}, graph.Subcategories("Physicists", 1))
+	// The cycle through People and Scientists ends.
+	assert.Equal(t, []string{"Scientists", "Physicists", "Nobel laureates in Physics", "People"}, graph.Subcategories("Scientists", 5))
+	assert.Equal(t, []string{"Chemists"}, graph.Subcategories("Chemists", 2))
+}
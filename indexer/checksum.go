@@ -0,0 +1,127 @@
+package indexer
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileChecksum is the size and CRC-32C of a file of a segment, recorded in
+// the segment's manifest when it is written.
+type FileChecksum struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	CRC32C uint32 `json:"crc32c"`
+}
+
+// checksumFiles returns the checksums of the files in dir, by name.
+func checksumFiles(dir string) ([]FileChecksum, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, NewIOError("list segment files", err)
+	}
+	var files []FileChecksum
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == ManifestFile {
+			continue
+		}
+		size, crc, err := checksumFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, NewIOError("checksum "+entry.Name(), err)
+		}
+		files = append(files, FileChecksum{Name: entry.Name(), Size: size, CRC32C: crc})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+func checksumFile(path string) (int64, uint32, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := crc32.New(castagnoli)
+	size, err := io.Copy(hash, file)
+	return size, hash.Sum32(), err
+}
+
+// openChecksumLimit is the size up to which the checksum of a segment file
+// is verified whenever the segment is opened.
+const openChecksumLimit = 1 << 20
+
+// CheckFiles reports the first file listed in m that is missing from dir
+// or has another size, which catches truncated segments without reading
+// them, or another checksum if the file is the document table, which is
+// read whole anyway, or no larger than openChecksumLimit. Larger files
+// are left to VerifyFiles; their table footers and posting blocks carry
+// checksums of their own.
+func (m *Manifest) CheckFiles(dir string) error {
+	for _, file := range m.Files {
+		path := filepath.Join(dir, file.Name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return NewCorruptIndexError(path, "missing file")
+		}
+		if info.Size() != file.Size {
+			return NewCorruptIndexError(path, fmt.Sprintf("size %d, expected %d", info.Size(), file.Size))
+		}
+		if file.Name != DocsFile && file.Size > openChecksumLimit {
+			continue
+		}
+		if _, err := readChecked(path, file.CRC32C); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readChecked reads the file at path and checks it against its CRC-32C.
+func readChecked(path string, crc uint32) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewIOError("read "+path, err)
+	}
+	if sum := crc32.Checksum(data, castagnoli); sum != crc {
+		return nil, NewCorruptIndexError(path, fmt.Sprintf("checksum %08x, expected %08x", sum, crc))
+	}
+	return data, nil
+}
+
+// VerifyFiles reads every file of the segment in dir and reports those
+// that are missing, have another size or checksum than m records, or are
+// not listed in m.
+func (m *Manifest) VerifyFiles(dir string) []*WikiError {
+	var problems []*WikiError
+	listed := make(map[string]bool, len(m.Files))
+	for _, file := range m.Files {
+		listed[file.Name] = true
+		path := filepath.Join(dir, file.Name)
+		size, crc, err := checksumFile(path)
+		switch {
+		case os.IsNotExist(err):
+			problems = append(problems, NewCorruptIndexError(path, "missing file"))
+		case err != nil:
+			problems = append(problems, NewIOError("read "+path, err))
+		case size != file.Size:
+			problems = append(problems, NewCorruptIndexError(path, fmt.Sprintf("size %d, expected %d", size, file.Size)))
+		case crc != file.CRC32C:
+			problems = append(problems, NewCorruptIndexError(path, fmt.Sprintf("checksum %08x, expected %08x", crc, file.CRC32C)))
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return append(problems, NewIOError("list "+dir, err))
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); name != ManifestFile && !listed[name] {
+			problems = append(problems, NewCorruptIndexError(filepath.Join(dir, name), "file not listed in the manifest"))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,397 @@
+package indexer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"path/filepath"
+	"sort"
+)
+
+// A segment stores its term dictionary in TermsFile and the posting lists
+// in PostingsFile.
+//
+// terms.dict is a table file (see writeTable) with one entry per term:
+//
+//	entry:  uvarint len(term), term, uvarint docFreq,
+//	        uvarint postings offset, uvarint postings length,
+//	        uvarint max frequency, uvarint field mask union,
+//	        field frequencies of the max frequency of each field,
+//	        uvarint len(form), form
+//
+// The form is the word the term was most commonly stemmed from, left empty
+// when it is the term itself.
+//
+// postings.dat: magic, posting lists
+//
+//	list:    skip entries, blocks of up to BlockSize postings
+//	skip:    uvarint last doc ordinal delta, uvarint block byte length,
+//	         uint32 CRC-32C of the block
+//	block:   postings
+//	posting: uvarint doc ordinal delta, byte field mask, field frequencies
+//	field frequencies: one uvarint per field in the mask, in bit order
+//
+// There is one skip entry per block, so the number of blocks follows from
+// the document frequency. Doc ordinals are positions in the segment's
+// document table; the deltas continue across block boundaries, and the
+// last doc of a block is relative to the last doc of the previous one. All
+// fixed-width integers are little endian.
+const (
+	TermsFile    = "terms.dict"
+	PostingsFile = "postings.dat"
+
+	// BlockSize is the number of postings per block of a posting list.
+	BlockSize = 128
+)
+
+var (
+	termsMagic    = []byte("WFTD")
+	postingsMagic = []byte("WFPS")
+)
+
+// castagnoli is the CRC-32C table used for the checksums of posting blocks
+// and segment files, which most CPUs compute in hardware.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// TermInfo is a dictionary entry: a term, its document frequency within
+// the segment and where its posting list is stored. MaxFrequency, Fields
+// and MaxFieldFreqs bound the postings of the term, so a query can compute
+// the best score any document could get from it without reading the list.
+// Form is the word the term was most commonly stemmed from, for display.
+type TermInfo struct {
+	Term          string
+	Form          string
+	DocFreq       int
+	MaxFrequency  int
+	Fields        FieldMask
+	MaxFieldFreqs [NumFields]uint32
+	offset        int64
+	length        int64
+}
+
+// appendFieldFreqs appends the frequencies of the fields in mask.
+func appendFieldFreqs(buf []byte, mask FieldMask, freqs *[NumFields]uint32) []byte {
+	for i := range NumFields {
+		if mask&(1<<i) != 0 {
+			buf = binary.AppendUvarint(buf, uint64(freqs[i]))
+		}
+	}
+	return buf
+}
+
+// readFieldFreqs decodes the frequencies of the fields in mask into freqs
+// and returns their sum and the number of bytes read. Every field must
+// occur at least once.
+func readFieldFreqs(data []byte, mask FieldMask, freqs *[NumFields]uint32) (total, n int, ok bool) {
+	for i := range NumFields {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		freq, k := binary.Uvarint(data[n:])
+		if k <= 0 || freq == 0 || freq > math.MaxUint32 {
+			return 0, 0, false
+		}
+		freqs[i] = uint32(freq)
+		total += int(freq)
+		n += k
+	}
+	return total, n, mask != 0
+}
+
+// encodePostings appends the block-encoded posting list of ords, which
+// must be ascending, to buf.
+func encodePostings(buf []byte, ords []uint32, postings []Posting) []byte {
+	var skips, blocks []byte
+	prev := uint32(0)
+	for start := 0; start < len(ords); start += BlockSize {
+		end := min(start+BlockSize, len(ords))
+
+		size := len(blocks)
+		for i := start; i < end; i++ {
+			blocks = binary.AppendUvarint(blocks, uint64(ords[i]-prev))
+			blocks = append(blocks, byte(postings[i].Fields))
+			blocks = appendFieldFreqs(blocks, postings[i].Fields, &postings[i].FieldFreqs)
+			prev = ords[i]
+		}
+
+		lastDelta := ords[end-1]
+		if start > 0 {
+			lastDelta -= ords[start-1]
+		}
+		skips = binary.AppendUvarint(skips, uint64(lastDelta))
+		skips = binary.AppendUvarint(skips, uint64(len(blocks)-size))
+		skips = binary.LittleEndian.AppendUint32(skips, crc32.Checksum(blocks[size:], castagnoli))
+	}
+
+	buf = append(buf, skips...)
+	return append(buf, blocks...)
+}
+
+// writePostingsFile writes the posting lists of all terms of index and
+// returns their dictionary entries in term order.
+func writePostingsFile(dir string, index *InvertedIndex, ordinals map[string]uint32) ([]TermInfo, error) {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	terms := make([]string, 0, len(index.Index))
+	for term := range index.Index {
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	sort.Strings(terms)
+
+	infos := make([]TermInfo, 0, len(terms))
+	err := writeBuffered(filepath.Join(dir, PostingsFile), func(writer *bufio.Writer) error {
+		if _, err := writer.Write(postingsMagic); err != nil {
+			return err
+		}
+		offset := int64(len(postingsMagic))
+
+		var buf []byte
+		var ords []uint32
+		var postings []Posting
+		for _, term := range terms {
+			docIDs := sortedKeys(index.Index[term])
+			ords, postings = ords[:0], postings[:0]
+			maxFreq, fields := 0, FieldMask(0)
+			var maxFieldFreqs [NumFields]uint32
+			for _, docID := range docIDs {
+				posting := index.Index[term][docID]
+				ords = append(ords, ordinals[docID])
+				postings = append(postings, posting)
+				maxFreq = max(maxFreq, posting.Frequency)
+				fields |= posting.Fields
+				for i, freq := range posting.FieldFreqs {
+					maxFieldFreqs[i] = max(maxFieldFreqs[i], freq)
+				}
+			}
+
+			buf = encodePostings(buf[:0], ords, postings)
+			if _, err := writer.Write(buf); err != nil {
+				return err
+			}
+			infos = append(infos, TermInfo{
+				Term:          term,
+				Form:          index.form(term),
+				DocFreq:       len(ords),
+				MaxFrequency:  maxFreq,
+				Fields:        fields,
+				MaxFieldFreqs: maxFieldFreqs,
+				offset:        offset,
+				length:        int64(len(buf)),
+			})
+			offset += int64(len(buf))
+		}
+		return nil
+	})
+	return infos, err
+}
+
+// writeTermsFile writes the term dictionary for infos, which must be
+// sorted by term.
+func writeTermsFile(dir string, infos []TermInfo) error {
+	return writeTable(filepath.Join(dir, TermsFile), termsMagic, len(infos), func(i int, buf []byte) []byte {
+		info := &infos[i]
+		buf = binary.AppendUvarint(buf, uint64(len(info.Term)))
+		buf = append(buf, info.Term...)
+		buf = binary.AppendUvarint(buf, uint64(info.DocFreq))
+		buf = binary.AppendUvarint(buf, uint64(info.offset))
+		buf = binary.AppendUvarint(buf, uint64(info.length))
+		buf = binary.AppendUvarint(buf, uint64(info.MaxFrequency))
+		buf = binary.AppendUvarint(buf, uint64(info.Fields))
+		buf = appendFieldFreqs(buf, info.Fields, &info.MaxFieldFreqs)
+		form := info.Form
+		if form == info.Term {
+			form = ""
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(form)))
+		return append(buf, form...)
+	})
+}
+
+// PostingIterator decodes a posting list in doc ordinal order. When the
+// segment is memory-mapped it reads the blocks in place without copying,
+// and Advance skips whole blocks using the skip entries.
+type PostingIterator struct {
+	data      []byte
+	remaining int
+	maxDoc    uint32
+
+	// skips holds the skip entries of the blocks not loaded yet; they are
+	// parsed lazily, since most lists are read only partially or once.
+	skips     []byte
+	block     []byte
+	blockLeft int
+	blockLast uint32
+
+	started bool
+	done    bool
+	doc     uint32
+	posting Posting
+	err     error
+}
+
+func newPostingIterator(data []byte, docFreq int, maxDoc uint32) *PostingIterator {
+	it := &PostingIterator{data: data, remaining: docFreq, maxDoc: maxDoc}
+
+	// Find the end of the skip entries, where the first block starts.
+	skipLen := 0
+	for i := 0; i < (docFreq+BlockSize-1)/BlockSize; i++ {
+		for j := 0; j < 2; j++ {
+			_, k := binary.Uvarint(data[skipLen:])
+			if k <= 0 {
+				it.err = errCorruptPostings
+				return it
+			}
+			skipLen += k
+		}
+		if skipLen += 4; skipLen > len(data) {
+			it.err = errCorruptPostings
+			return it
+		}
+	}
+	it.skips, it.data = data[:skipLen], data[skipLen:]
+	return it
+}
+
+// nextSkip reads the skip entry of the next block.
+func (it *PostingIterator) nextSkip() (last uint32, size int, checksum uint32, ok bool) {
+	lastDelta, k := binary.Uvarint(it.skips)
+	if k <= 0 {
+		it.err = errCorruptPostings
+		return 0, 0, 0, false
+	}
+	n, m := binary.Uvarint(it.skips[k:])
+	base := uint64(0)
+	if it.started {
+		base = uint64(it.doc)
+	}
+	if m <= 0 || lastDelta+base >= uint64(it.maxDoc) || n == 0 || n > uint64(len(it.data)) || len(it.skips) < k+m+4 {
+		it.err = errCorruptPostings
+		return 0, 0, 0, false
+	}
+	checksum = binary.LittleEndian.Uint32(it.skips[k+m:])
+	it.skips = it.skips[k+m+4:]
+	return uint32(base + lastDelta), int(n), checksum, true
+}
+
+// Next advances to the next posting and reports whether there is one.
+func (it *PostingIterator) Next() bool {
+	if it.err != nil || it.remaining == 0 {
+		it.done = true
+		return false
+	}
+
+	if it.blockLeft == 0 {
+		last, size, checksum, ok := it.nextSkip()
+		if !ok {
+			return false
+		}
+		if crc32.Checksum(it.data[:size], castagnoli) != checksum {
+			it.err = errPostingsChecksum
+			return false
+		}
+		it.block, it.data = it.data[:size], it.data[size:]
+		it.blockLeft = min(it.remaining, BlockSize)
+		it.blockLast = last
+	}
+
+	delta, k := binary.Uvarint(it.block)
+	if k <= 0 || k >= len(it.block) {
+		it.err = errCorruptPostings
+		return false
+	}
+	posting := Posting{Fields: FieldMask(it.block[k])}
+	freq, m, ok := readFieldFreqs(it.block[k+1:], posting.Fields, &posting.FieldFreqs)
+	if !ok {
+		it.err = errCorruptPostings
+		return false
+	}
+	posting.Frequency = freq
+	it.block = it.block[k+1+m:]
+
+	if it.started && delta == 0 {
+		it.err = errCorruptPostings
+		return false
+	}
+	next := uint64(it.doc) + delta
+	if !it.started {
+		next = delta
+		it.started = true
+	}
+	if next > uint64(it.blockLast) {
+		it.err = errCorruptPostings
+		return false
+	}
+	it.doc = uint32(next)
+	it.posting = posting
+	it.remaining--
+	it.blockLeft--
+
+	if it.blockLeft == 0 && (len(it.block) != 0 || it.doc != it.blockLast) {
+		it.err = errCorruptPostings
+		return false
+	}
+	if it.remaining == 0 && (len(it.skips) != 0 || len(it.data) != 0) {
+		it.err = errCorruptPostings
+		return false
+	}
+	return true
+}
+
+// Advance moves to the first posting whose doc ordinal is at least target
+// and reports whether there is one. It never moves backwards, so when the
+// current posting already qualifies it stays put. Blocks that end before
+// target are skipped without being decoded.
+func (it *PostingIterator) Advance(target uint32) bool {
+	if it.started && !it.done && it.doc >= target && it.err == nil {
+		return true
+	}
+
+	if it.blockLeft > 0 && it.blockLast < target {
+		it.remaining -= it.blockLeft
+		it.doc, it.block, it.blockLeft = it.blockLast, nil, 0
+	}
+	for it.blockLeft == 0 && it.remaining > BlockSize && it.err == nil {
+		// Peek at the last doc of the next block.
+		lastDelta, _ := binary.Uvarint(it.skips)
+		base := uint64(0)
+		if it.started {
+			base = uint64(it.doc)
+		}
+		if base+lastDelta >= uint64(target) {
+			break
+		}
+		last, size, _, ok := it.nextSkip()
+		if !ok {
+			return false
+		}
+		it.data = it.data[size:]
+		it.remaining -= BlockSize
+		it.doc, it.started = last, true
+	}
+
+	for it.Next() {
+		if it.doc >= target {
+			return true
+		}
+	}
+	return false
+}
+
+// Doc returns the ordinal of the current document.
+func (it *PostingIterator) Doc() uint32 {
+	return it.doc
+}
+
+// Posting returns the fields and frequency of the current document.
+func (it *PostingIterator) Posting() Posting {
+	return it.posting
+}
+
+// Err returns the decoding error that stopped iteration, if any.
+func (it *PostingIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,146 @@
+package indexer
+
+import (
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"strings"
+)
+
+// Tombstones are kept per segment in "<segment>_<gen>.del" files next to
+// the segment directories. Segments stay immutable; deleting documents
+// writes a new generation of the tombstone file and a new commit point.
+const deletesSuffix = ".del"
+
+func deletesFileName(segment string, gen int) string {
+	return fmt.Sprintf("%s_%d%s", segment, gen, deletesSuffix)
+}
+
+// ReadSegmentDeletes returns the tombstoned documents of seg.
+func ReadSegmentDeletes(indexPath string, seg SegmentInfo) (map[string]bool, error) {
+	deletes := make(map[string]bool, seg.DelCount)
+	if seg.DelGen == 0 {
+		return deletes, nil
+	}
+
+	data, err := readChecked(filepath.Join(indexPath, deletesFileName(seg.Name, seg.DelGen)), seg.DelCRC32C)
+	if err != nil {
+		return nil, err
+	}
+	for docID := range strings.SplitSeq(string(data), "\n") {
+		if docID != "" {
+			deletes[docID] = true
+		}
+	}
+	return deletes, nil
+}
+
+// Delete tombstones every document matching one of keys, which are
+// document IDs or page titles. It returns the number of documents newly
+// deleted and the keys that matched no document.
+func (w *IndexWriter) Delete(keys ...string) (int, []string, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	commit, err := ReadCommit(w.indexPath)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	docs := make([]map[string]DocInfo, len(commit.Segments))
+	ids := make(map[string]bool)
+	titles := make(map[string][]string)
+	for i, seg := range commit.Segments {
+		if docs[i], err = ReadSegmentDocs(SegmentPath(w.indexPath, seg.Name)); err != nil {
+			return 0, nil, err
+		}
+		for docID, info := range docs[i] {
+			ids[docID] = true
+			title := TitleKey(info.Title)
+			titles[title] = append(titles[title], docID)
+		}
+	}
+
+	targets := make(map[string]bool)
+	var missing []string
+	for _, key := range keys {
+		switch {
+		case ids[key]:
+			targets[key] = true
+		case len(titles[TitleKey(key)]) > 0:
+			for _, docID := range titles[TitleKey(key)] {
+				targets[docID] = true
+			}
+		default:
+			missing = append(missing, key)
+		}
+	}
+
+	deleted := make(map[string]bool)
+	for i, seg := range commit.Segments {
+		tombstones, err := ReadSegmentDeletes(w.indexPath, seg)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		changed := false
+		for docID := range targets {
+			if _, ok := docs[i][docID]; ok && !tombstones[docID] {
+				tombstones[docID] = true
+				deleted[docID] = true
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		gen := seg.DelGen + 1
+		data := strings.Join(sortedKeys(tombstones), "\n") + "\n"
+		if err := writeFileAtomic(w.indexPath, deletesFileName(seg.Name, gen), []byte(data)); err != nil {
+			return 0, nil, NewIOError("write tombstones", err)
+		}
+		commit.Segments[i].DelGen = gen
+		commit.Segments[i].DelCRC32C = crc32.Checksum([]byte(data), castagnoli)
+		commit.Segments[i].DelCount = len(tombstones)
+	}
+
+	if len(deleted) == 0 {
+		return 0, missing, nil
+	}
+	if err := writeCommit(w.indexPath, commit); err != nil {
+		return 0, nil, err
+	}
+	return len(deleted), missing, nil
+}
+
+// Compact merges all segments into one, physically dropping deleted and
+// superseded documents.
+func (w *IndexWriter) Compact() error {
+	if err := w.WaitForMerges(); err != nil {
+		return err
+	}
+
+	for {
+		commit, err := ReadCommit(w.indexPath)
+		if err != nil {
+			return err
+		}
+		if len(commit.Segments) == 0 || (len(commit.Segments) == 1 && commit.Segments[0].DelCount == 0) {
+			return nil
+		}
+
+		merged, err := w.merge(commit.Segments)
+		if err != nil {
+			return err
+		}
+		if merged {
+			return nil
+		}
+	}
+}
+
+// isDeletesFile reports whether name is a tombstone file.
+func isDeletesFile(name string) bool {
+	return strings.HasSuffix(name, deletesSuffix)
+}
@@ -0,0 +1,106 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexWriter_Delete(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	first := NewInvertedIndex()
+	first.Add("physic", "1", NewPosting(BODY, 1))
+	first.AddDocument("1", "Albert Einstein")
+	first.Add("physic", "2", NewPosting(BODY, 2))
+	first.AddDocument("2", "Niels Bohr")
+	first.Add("physic", "3", NewPosting(BODY, 3))
+	first.AddDocument("3", "Max Planck")
+	require.NoError(t, NewIndexWriter(indexPath).WriteIndex(first))
+
+	// Document 2 is updated in a second segment.
+	second := NewInvertedIndex()
+	second.Add("physic", "2", NewPosting(TITLE, 1))
+	second.AddDocument("2", "Niels Bohr")
+	writer := NewIndexWriter(indexPath)
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+
+	deleted, missing, err := writer.Delete("1", "niels_bohr", "Nobody")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, []string{"Nobody"}, missing)
+
+	commit, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	require.Len(t, commit.Segments, 2)
+	assert.Equal(t, 1, commit.Segments[0].DelGen)
+	assert.Equal(t, 2, commit.Segments[0].DelCount)
+	assert.Equal(t, 1, commit.Segments[1].DelGen)
+	assert.Equal(t, 1, commit.Segments[1].DelCount)
+	assert.Equal(t, 1, commit.DocCount())
+
+	tombstones, err := ReadSegmentDeletes(indexPath, commit.Segments[0])
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"1": true, "2": true}, tombstones)
+
+	// Deleting again is a no-op and does not write a new commit.
+	deleted, missing, err = writer.Delete("1")
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	assert.Empty(t, missing)
+
+	deleted, _, err = writer.Delete("3")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	commit, err = ReadCommit(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, 2, commit.Segments[0].DelGen)
+	assert.FileExists(t, filepath.Join(indexPath, deletesFileName(commit.Segments[0].Name, 1)), "kept for the previous commit")
+	assert.FileExists(t, filepath.Join(indexPath, deletesFileName(commit.Segments[0].Name, 2)))
+
+	require.NoError(t, writer.UpdateRanks())
+	assert.NoFileExists(t, filepath.Join(indexPath, deletesFileName(commit.Segments[0].Name, 1)))
+}
+
+func TestIndexWriter_Compact(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	idx := NewInvertedIndex()
+	idx.Add("physic", "1", NewPosting(BODY, 1))
+	idx.Add("physic", "2", NewPosting(BODY, 2))
+	idx.Add("quantum", "2", NewPosting(BODY, 1))
+	writer := NewIndexWriter(indexPath)
+	require.NoError(t, writer.WriteIndex(idx))
+
+	_, _, err := writer.Delete("2")
+	require.NoError(t, err)
+	require.NoError(t, writer.Compact())
+
+	commit, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	require.Len(t, commit.Segments, 1)
+	assert.Equal(t, SegmentInfo{Name: commit.Segments[0].Name, DocCount: 1}, commit.Segments[0])
+
+	dir := SegmentPath(indexPath, commit.Segments[0].Name)
+	assert.Equal(t, []string{"physic:1$8$1"}, dumpTerms(t, dir, "p"))
+	assert.Empty(t, dumpTerms(t, dir, "q"))
+
+	// Only the previous commit still has a tombstone file.
+	matches, err := filepath.Glob(filepath.Join(indexPath, "*"+deletesSuffix))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(indexPath, deletesFileName("_0", 1))}, matches)
+
+	// Nothing left to compact.
+	require.NoError(t, writer.Compact())
+	again, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, commit.Generation, again.Generation)
+
+	_, err = os.Stat(dir)
+	assert.NoError(t, err)
+}
@@ -12,6 +12,8 @@ const (
 	ErrIndexNotFound
 	ErrInvalidTerm
 	ErrIOError
+	ErrIncompatibleIndex
+	ErrCorruptIndex
 )
 
 type WikiError struct {
@@ -68,3 +70,18 @@ func NewIOError(operation string, cause error) *WikiError {
 		Cause:   cause,
 	}
 }
+
+func NewIncompatibleIndexError(path, reason string, cause error) *WikiError {
+	return &WikiError{
+		Type:    ErrIncompatibleIndex,
+		Message: fmt.Sprintf("incompatible index %s: %s", path, reason),
+		Cause:   cause,
+	}
+}
+
+func NewCorruptIndexError(path, reason string) *WikiError {
+	return &WikiError{
+		Type:    ErrCorruptIndex,
+		Message: fmt.Sprintf("corrupt index file %s: %s", path, reason),
+	}
+}
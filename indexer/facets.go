@@ -0,0 +1,294 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Facets are properties of pages that search results can be counted and
+// filtered by: the categories of a page, the type of its first infobox,
+// its namespace and the year of the revision indexed.
+const (
+	FacetCategory  = "category"
+	FacetInfobox   = "infobox"
+	FacetNamespace = "namespace"
+	FacetYear      = "year"
+)
+
+// FacetNames lists the facets in the order searches report them.
+func FacetNames() []string {
+	return []string{FacetCategory, FacetInfobox, FacetNamespace, FacetYear}
+}
+
+// MainNamespace is the namespace of articles.
+const MainNamespace = "Main"
+
+// MembersFile is the dictionary of the facet values of a segment's
+// documents, with the documents having each of them. It is a table file
+// (see writeTable) with one entry per value, in key order, the key being
+// the facet and the value normalized with TitleKey, separated by a NUL
+// byte:
+//
+//	entry: uvarint len(key), key, uvarint len(value), value,
+//	       uvarint count, uvarint ordinal deltas
+//
+// FacetsFile holds the facet values of every document as doc values, so
+// that the values of the matches of a query can be counted without
+// decoding strings. It is a table file with one entry per document in
+// ordinal order, the entry being the positions of its values in
+// MembersFile:
+//
+//	entry: uvarint count, uvarint position deltas
+const (
+	MembersFile = "members.dat"
+	FacetsFile  = "facets.dat"
+)
+
+var (
+	membersMagic = []byte("WFMB")
+	facetsMagic  = []byte("WFFC")
+)
+
+// FacetValue is the value of a facet for a document.
+type FacetValue struct {
+	Facet string
+	Value string
+}
+
+// facetKey returns the key of a facet value in MembersFile.
+func facetKey(facet, value string) string {
+	return facet + "\x00" + TitleKey(value)
+}
+
+var infoboxTypeRegex = regexp.MustCompile(`(?i)\{\{\s*(infobox(?:[ _][^|}\n]*)?)\s*[|}\n]`)
+
+// PageFacets returns the values of the facets of a page besides its
+// categories: its namespace, the year of its revision, if the timestamp is
+// known, and the type of its first infobox, such as "infobox person".
+func PageFacets(doc *Document) []FacetValue {
+	values := []FacetValue{{Facet: FacetNamespace, Value: Namespace(doc.Title)}}
+	if edited, err := time.Parse(time.RFC3339, doc.Timestamp); err == nil {
+		values = append(values, FacetValue{Facet: FacetYear, Value: strconv.Itoa(edited.Year())})
+	}
+	if match := infoboxTypeRegex.FindStringSubmatch(doc.Content); match != nil {
+		values = append(values, FacetValue{Facet: FacetInfobox, Value: TitleKey(match[1])})
+	}
+	return values
+}
+
+// Namespace returns the namespace of the page titled title, such as
+// "Category" for "Category:Physicists" and MainNamespace for articles.
+func Namespace(title string) string {
+	if prefix, _, ok := strings.Cut(title, ":"); ok {
+		if prefix = strings.ToLower(strings.TrimSpace(prefix)); prefix == "category" || linkNamespaces[prefix] {
+			return categoryName(prefix)
+		}
+	}
+	return MainNamespace
+}
+
+// docFacets returns the facet values of docID, its categories first. The
+// caller must hold the read lock.
+func (idx *InvertedIndex) docFacets(docID string) []FacetValue {
+	var values []FacetValue
+	for _, name := range idx.Categories[docID] {
+		values = append(values, FacetValue{Facet: FacetCategory, Value: name})
+	}
+	return append(values, idx.Facets[docID]...)
+}
+
+// writeFacetsFiles writes the facet values of the documents of index with
+// their members, and the values of every document in ordinal order. A
+// value is shown as it was first written.
+func writeFacetsFiles(dir string, index *InvertedIndex, docIDs []string) error {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	type facetEntry struct {
+		value string
+		ords  []uint32
+	}
+	entries := make(map[string]*facetEntry)
+	docKeys := make([][]string, len(docIDs))
+	for ord, docID := range docIDs {
+		for _, v := range index.docFacets(docID) {
+			key := facetKey(v.Facet, v.Value)
+			e := entries[key]
+			if e == nil {
+				e = &facetEntry{value: v.Value}
+				entries[key] = e
+			}
+			if n := len(e.ords); n == 0 || e.ords[n-1] != uint32(ord) {
+				e.ords = append(e.ords, uint32(ord))
+				docKeys[ord] = append(docKeys[ord], key)
+			}
+		}
+	}
+
+	keys := sortedKeys(entries)
+	err := writeTable(filepath.Join(dir, MembersFile), membersMagic, len(keys), func(i int, buf []byte) []byte {
+		e := entries[keys[i]]
+		buf = binary.AppendUvarint(buf, uint64(len(keys[i])))
+		buf = append(buf, keys[i]...)
+		buf = binary.AppendUvarint(buf, uint64(len(e.value)))
+		buf = append(buf, e.value...)
+		buf = binary.AppendUvarint(buf, uint64(len(e.ords)))
+		prev := uint32(0)
+		for _, ord := range e.ords {
+			buf = binary.AppendUvarint(buf, uint64(ord-prev))
+			prev = ord
+		}
+		return buf
+	})
+	if err != nil {
+		return err
+	}
+
+	positions := make(map[string]uint32, len(keys))
+	for i, key := range keys {
+		positions[key] = uint32(i)
+	}
+	var docPositions []uint32
+	return writeTable(filepath.Join(dir, FacetsFile), facetsMagic, len(docIDs), func(i int, buf []byte) []byte {
+		docPositions = docPositions[:0]
+		for _, key := range docKeys[i] {
+			docPositions = append(docPositions, positions[key])
+		}
+		slices.Sort(docPositions)
+		buf = binary.AppendUvarint(buf, uint64(len(docPositions)))
+		prev := uint32(0)
+		for _, pos := range docPositions {
+			buf = binary.AppendUvarint(buf, uint64(pos-prev))
+			prev = pos
+		}
+		return buf
+	})
+}
+
+// NumFacetValues returns the number of distinct facet values in the
+// segment.
+func (r *SegmentReader) NumFacetValues() int {
+	return r.members.numEntries
+}
+
+// memberEntry decodes the key and value of the entry of MembersFile at
+// position pos, and returns the rest of it.
+func (r *SegmentReader) memberEntry(pos int) (key, value, rest []byte, err error) {
+	raw, err := r.members.entry(pos)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, rest, ok := termBytes(raw)
+	if ok {
+		value, rest, ok = termBytes(rest)
+	}
+	if !ok {
+		return nil, nil, nil, NewCorruptIndexError(r.members.path, "malformed entry")
+	}
+	return key, value, rest, nil
+}
+
+// FacetValue returns the facet value at position pos of the segment's
+// dictionary, as DocFacets gives positions.
+func (r *SegmentReader) FacetValue(pos uint32) (FacetValue, error) {
+	if int(pos) >= r.members.numEntries {
+		return FacetValue{}, NewCorruptIndexError(r.members.path, "missing entry")
+	}
+	key, value, _, err := r.memberEntry(int(pos))
+	if err != nil {
+		return FacetValue{}, err
+	}
+	facet, _, ok := bytes.Cut(key, []byte{0})
+	if !ok {
+		return FacetValue{}, NewCorruptIndexError(r.members.path, "malformed entry")
+	}
+	return FacetValue{Facet: string(facet), Value: string(value)}, nil
+}
+
+// FacetMembers returns the ordinals of the documents having value for
+// facet, in ascending order. Values are matched with TitleKey.
+func (r *SegmentReader) FacetMembers(facet, value string) ([]uint32, error) {
+	key := facetKey(facet, value)
+	i, err := r.members.search([]byte(key), func(entry []byte) ([]byte, bool) {
+		k, _, ok := termBytes(entry)
+		return k, ok
+	})
+	if err != nil || i == r.members.numEntries {
+		return nil, err
+	}
+	k, _, rest, err := r.memberEntry(i)
+	if err != nil || string(k) != key {
+		return nil, err
+	}
+	return decodeDeltas(r.members.path, rest, uint64(len(r.docIDs)))
+}
+
+// CategoryMembers returns the ordinals of the documents in the category
+// named name, in ascending order.
+func (r *SegmentReader) CategoryMembers(name string) ([]uint32, error) {
+	return r.FacetMembers(FacetCategory, name)
+}
+
+// DocFacets appends to buf the positions of the facet values of the
+// document with ordinal ord, in ascending order. FacetValue returns the
+// value at a position.
+func (r *SegmentReader) DocFacets(ord uint32, buf []uint32) ([]uint32, error) {
+	if int(ord) >= r.facets.numEntries {
+		return nil, NewCorruptIndexError(r.facets.path, "missing entry")
+	}
+	raw, err := r.facets.entry(int(ord))
+	if err != nil {
+		return nil, err
+	}
+	return appendDeltas(r.facets.path, raw, uint64(r.members.numEntries), buf)
+}
+
+// Facets returns the facet values of the document with ordinal ord.
+func (r *SegmentReader) Facets(ord uint32) ([]FacetValue, error) {
+	positions, err := r.DocFacets(ord, nil)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]FacetValue, len(positions))
+	for i, pos := range positions {
+		if values[i], err = r.FacetValue(pos); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// decodeDeltas decodes a count followed by that many strictly ascending
+// numbers below limit, stored as deltas, from the file at path.
+func decodeDeltas(path string, raw []byte, limit uint64) ([]uint32, error) {
+	return appendDeltas(path, raw, limit, nil)
+}
+
+// appendDeltas decodes numbers like decodeDeltas and appends them to buf.
+func appendDeltas(path string, raw []byte, limit uint64, buf []uint32) ([]uint32, error) {
+	count, n := binary.Uvarint(raw)
+	if n <= 0 || count > limit {
+		return nil, NewCorruptIndexError(path, "malformed entry")
+	}
+	raw = raw[n:]
+	buf = slices.Grow(buf, int(count))
+	value := uint64(0)
+	for i := range count {
+		delta, n := binary.Uvarint(raw)
+		if n <= 0 || (i > 0 && delta == 0) {
+			return nil, NewCorruptIndexError(path, "malformed entry")
+		}
+		if value += delta; value >= limit {
+			return nil, NewCorruptIndexError(path, "malformed entry")
+		}
+		buf = append(buf, uint32(value))
+		raw = raw[n:]
+	}
+	return buf, nil
+}
@@ -0,0 +1,118 @@
+package indexer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageFacets(t *testing.T) {
+	assert.Equal(t, []FacetValue{
+		{Facet: FacetNamespace, Value: MainNamespace},
+		{Facet: FacetYear, Value: "2024"},
+		{Facet: FacetInfobox, Value: "infobox person"},
+	}, PageFacets(&Document{
+		Title:     "Albert Einstein",
+		Timestamp: "2024-03-01T10:00:00Z",
+		Content:   "{{Short description}} {{ Infobox_Person\n| name = Albert}} {{Infobox scientist}}",
+	}))
+	assert.Equal(t, []FacetValue{{Facet: FacetNamespace, Value: "Category"}}, PageFacets(&Document{
+		Title:     "Category:Physicists",
+		Timestamp: "yesterday",
+		Content:   "{{Infoboxes}}",
+	}))
+}
+
+func TestNamespace(t *testing.T) {
+	assert.Equal(t, "Category", Namespace("category:Physicists"))
+	assert.Equal(t, "Template", Namespace("Template:Infobox person"))
+	assert.Equal(t, MainNamespace, Namespace("Star Wars: A New Hope"))
+	assert.Equal(t, MainNamespace, Namespace("Physics"))
+}
+
+func TestSegmentReader_Facets(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	const dump = `<mediawiki>
+<page><title>Albert Einstein</title><id>1</id><revision><timestamp>2024-03-01T10:00:00Z</timestamp><text>{{Infobox scientist}} physicist [[Category:Physicists]]</text></revision></page>
+<page><title>Category:Physicists</title><id>2</id><revision><timestamp>2023-03-01T10:00:00Z</timestamp><text>people [[Category:Scientists]]</text></revision></page>
+<page><title>Marie Curie</title><id>3</id><revision><timestamp>2024-05-01T10:00:00Z</timestamp><text>{{infobox_scientist}} chemist [[Category:physicists]]</text></revision></page>
+</mediawiki>`
+	require.NoError(t, NewWikiXMLParser(indexPath).ParseReader(context.Background(), strings.NewReader(dump)))
+
+	check := func(segment string) {
+		t.Helper()
+		r, err := OpenSegmentReader(SegmentPath(indexPath, segment))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, r.Close()) }()
+
+		members, err := r.FacetMembers(FacetInfobox, "Infobox_scientist")
+		require.NoError(t, err)
+		assert.Equal(t, []uint32{0, 2}, members)
+		members, err = r.FacetMembers(FacetYear, "2024")
+		require.NoError(t, err)
+		assert.Equal(t, []uint32{0, 2}, members)
+		members, err = r.FacetMembers(FacetNamespace, "category")
+		require.NoError(t, err)
+		assert.Equal(t, []uint32{1}, members)
+		members, err = r.FacetMembers(FacetYear, "1999")
+		require.NoError(t, err)
+		assert.Empty(t, members)
+
+		values, err := r.Facets(0)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []FacetValue{
+			{Facet: FacetCategory, Value: "Physicists"},
+			{Facet: FacetInfobox, Value: "infobox scientist"},
+			{Facet: FacetNamespace, Value: MainNamespace},
+			{Facet: FacetYear, Value: "2024"},
+		}, values)
+
+		positions, err := r.DocFacets(2, nil)
+		require.NoError(t, err)
+		assert.Len(t, positions, 4)
+		assert.IsIncreasing(t, positions)
+		value, err := r.FacetValue(positions[0])
+		require.NoError(t, err)
+		assert.Equal(t, FacetValue{Facet: FacetCategory, Value: "Physicists"}, value, "categories sort first")
+		buf := make([]uint32, 0, 8)
+		reused, err := r.DocFacets(2, buf)
+		require.NoError(t, err)
+		assert.Equal(t, positions, reused)
+		assert.Same(t, &buf[:1][0], &reused[0], "positions are decoded into buf")
+
+		_, err = r.DocFacets(3, nil)
+		var wikiErr *WikiError
+		require.ErrorAs(t, err, &wikiErr)
+		assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+	}
+	check("_0")
+
+	// Facets survive merges.
+	writer := NewIndexWriter(indexPath)
+	extra := NewInvertedIndex()
+	extra.AddDocument("4", "Niels Bohr")
+	extra.SetFacets("4", PageFacets(&Document{Title: "Niels Bohr", Timestamp: "2022-01-01T00:00:00Z"}))
+	require.NoError(t, writer.AppendIndex(extra))
+	require.NoError(t, writer.Compact())
+
+	r, err := OpenIndexReader(indexPath)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, r.Close()) }()
+	require.Len(t, r.Segments, 1)
+	segment := r.Segments[0]
+	members, err := segment.FacetMembers(FacetYear, "2022")
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, "4", segment.DocID(members[0]))
+	members, err = segment.FacetMembers(FacetInfobox, "infobox scientist")
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+	members, err = segment.CategoryMembers("Physicists")
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+}
@@ -0,0 +1,134 @@
+package indexer
+
+// MaxEdits is the largest edit distance fuzzy matching supports.
+const MaxEdits = 2
+
+// levenshtein is a Levenshtein automaton for a word: its states are rows
+// of the edit distance table between the word and the input read so far,
+// capped at maxEdits+1. A row whose every entry exceeds maxEdits is dead:
+// no continuation of the input can match.
+type levenshtein struct {
+	word     string
+	maxEdits int
+}
+
+func (l levenshtein) start() []int {
+	row := make([]int, len(l.word)+1)
+	for i := range row {
+		row[i] = min(i, l.maxEdits+1)
+	}
+	return row
+}
+
+// step appends the state after reading c in state row to next.
+func (l levenshtein) step(row []int, c byte, next []int) []int {
+	next = append(next, min(row[0]+1, l.maxEdits+1))
+	for i := 1; i <= len(l.word); i++ {
+		cost := 1
+		if l.word[i-1] == c {
+			cost = 0
+		}
+		next = append(next, min(row[i-1]+cost, row[i]+1, next[i-1]+1, l.maxEdits+1))
+	}
+	return next
+}
+
+func (l levenshtein) dead(row []int) bool {
+	for _, d := range row {
+		if d <= l.maxEdits {
+			return false
+		}
+	}
+	return true
+}
+
+// distance returns the edit distance of the input leading to row, which
+// matches if it is at most maxEdits.
+func (l levenshtein) distance(row []int) int {
+	return row[len(row)-1]
+}
+
+// prefixSuccessor returns the smallest string greater than every string
+// starting with prefix, or "" if there is none.
+func prefixSuccessor(prefix string) string {
+	b := []byte(prefix)
+	for len(b) > 0 {
+		if b[len(b)-1] < 0xff {
+			b[len(b)-1]++
+			return string(b)
+		}
+		b = b[:len(b)-1]
+	}
+	return ""
+}
+
+// FuzzyTerms calls fn with each term of the segment within maxEdits
+// insertions, deletions or substitutions of word, and its distance, in
+// dictionary order until fn returns false. It runs a Levenshtein automaton
+// along the sorted dictionary: rows are shared between terms with a common
+// prefix, and once a prefix kills the automaton every term starting with
+// it is skipped with a seek.
+func (r *SegmentReader) FuzzyTerms(word string, maxEdits int, fn func(info TermInfo, distance int) bool) error {
+	l := levenshtein{word: word, maxEdits: min(max(maxEdits, 0), MaxEdits)}
+
+	// rows[j] is the state after the first j bytes of prev.
+	rows := [][]int{l.start()}
+	prev := ""
+
+	for i := 0; i < r.NumTerms(); {
+		info, err := r.TermAt(i)
+		if err != nil {
+			return err
+		}
+		term := info.Term
+
+		shared := 0
+		for shared < len(prev) && shared < len(term) && prev[shared] == term[shared] {
+			shared++
+		}
+		rows = rows[:shared+1]
+		prev = term
+
+		deadAt := -1
+		for j := shared; j < len(term); j++ {
+			next := l.step(rows[j], term[j], nil)
+			rows = append(rows, next)
+			if l.dead(next) {
+				deadAt = j + 1
+				break
+			}
+		}
+
+		if deadAt < 0 {
+			if d := l.distance(rows[len(term)]); d <= l.maxEdits && !fn(info, d) {
+				return nil
+			}
+			i++
+			continue
+		}
+
+		// No term starting with term[:deadAt] can match.
+		successor := prefixSuccessor(term[:deadAt])
+		if successor == "" {
+			return nil
+		}
+		if i, err = r.Seek(successor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithinEdits reports whether term is within maxEdits insertions, deletions
+// or substitutions of word.
+func WithinEdits(word, term string, maxEdits int) bool {
+	l := levenshtein{word: word, maxEdits: min(max(maxEdits, 0), MaxEdits)}
+	row := l.start()
+	for i := 0; i < len(term); i++ {
+		row = l.step(row, term[i], nil)
+		if l.dead(row) {
+			return false
+		}
+	}
+	return l.distance(row) <= l.maxEdits
+}
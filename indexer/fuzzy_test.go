@@ -0,0 +1,83 @@
+package indexer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// editDistance is the textbook Levenshtein distance.
+func editDistance(a, b string) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			prev, row[j] = row[j], min(prev+cost, row[j]+1, row[j-1]+1)
+		}
+	}
+	return row[len(b)]
+}
+
+func TestSegmentReader_FuzzyTerms(t *testing.T) {
+	idx := NewInvertedIndex()
+	terms := []string{
+		"a", "ab", "ein", "einstein", "einsteinium", "eisenstein", "enstein",
+		"einstien", "feinstein", "stein", "weinstein", "zeinstein", "zz",
+	}
+	for _, term := range terms {
+		idx.Add(term, "doc1", NewPosting(BODY, 1))
+	}
+	idx.AddDocument("doc1", "Doc")
+
+	r, err := OpenSegmentReader(writeTestSegment(t, idx))
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	for _, word := range []string{"einstein", "einstien", "stien", "ab", "b", ""} {
+		for edits := 0; edits <= MaxEdits; edits++ {
+			t.Run(fmt.Sprintf("%s~%d", word, edits), func(t *testing.T) {
+				expected := make(map[string]int)
+				for _, term := range terms {
+					if d := editDistance(word, term); d <= edits {
+						expected[term] = d
+					}
+				}
+
+				matched := make(map[string]int)
+				require.NoError(t, r.FuzzyTerms(word, edits, func(info TermInfo, distance int) bool {
+					matched[info.Term] = distance
+					return true
+				}))
+				assert.Equal(t, expected, matched)
+			})
+		}
+	}
+
+	var first []string
+	require.NoError(t, r.FuzzyTerms("einstein", 2, func(info TermInfo, _ int) bool {
+		first = append(first, info.Term)
+		return false
+	}))
+	assert.Equal(t, []string{"einstein"}, first)
+}
+
+func TestWithinEdits(t *testing.T) {
+	words := []string{"", "a", "ab", "ein", "einstein", "einstien", "stein", "weinstein"}
+	for _, word := range words {
+		for _, term := range words {
+			for edits := 0; edits <= MaxEdits; edits++ {
+				assert.Equal(t, editDistance(word, term) <= edits, WithinEdits(word, term, edits), "%q %q %d", word, term, edits)
+			}
+		}
+	}
+}
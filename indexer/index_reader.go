@@ -0,0 +1,100 @@
+package indexer
+
+// IndexReader opens every segment of the latest commit of an index, for
+// tools that walk the whole index rather than search it.
+type IndexReader struct {
+	Commit    *CommitPoint
+	Segments  []*SegmentReader
+	Manifests []*Manifest
+	// Ranks holds the standing of the articles in the link graph, by
+	// document ID.
+	Ranks map[string]DocRank
+	// dead marks, per segment, the ordinals of the documents that are
+	// deleted or superseded by a version in a newer segment.
+	dead [][]bool
+}
+
+// OpenIndexReader opens the segments of the index at indexPath.
+func OpenIndexReader(indexPath string) (*IndexReader, error) {
+	commit, err := ReadCommit(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	ranks, err := ReadRanks(indexPath, commit)
+	if err != nil {
+		return nil, err
+	}
+	r := &IndexReader{Commit: commit, Ranks: ranks}
+	for _, info := range commit.Segments {
+		dir := SegmentPath(indexPath, info.Name)
+		manifest, err := ReadManifest(dir)
+		if err == nil {
+			err = manifest.CheckFiles(dir)
+		}
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+		deletes, err := ReadSegmentDeletes(indexPath, info)
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+		segment, err := OpenSegmentReader(dir)
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+
+		dead := make([]bool, segment.DocCount())
+		for docID := range deletes {
+			if ord, ok := segment.Ordinal(docID); ok {
+				dead[ord] = true
+			}
+		}
+		r.Segments = append(r.Segments, segment)
+		r.Manifests = append(r.Manifests, manifest)
+		r.dead = append(r.dead, dead)
+	}
+
+	// Only the newest version of a document is live.
+	seen := make(map[string]bool)
+	for i := len(r.Segments) - 1; i >= 0; i-- {
+		for ord := range r.dead[i] {
+			docID := r.Segments[i].DocID(uint32(ord))
+			if seen[docID] {
+				r.dead[i][ord] = true
+			}
+			seen[docID] = true
+		}
+	}
+	return r, nil
+}
+
+// Close closes the segments.
+func (r *IndexReader) Close() error {
+	var err error
+	for _, segment := range r.Segments {
+		if closeErr := segment.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// IsDead reports whether the document with ordinal ord in the i-th segment
+// is deleted or superseded.
+func (r *IndexReader) IsDead(i int, ord uint32) bool {
+	return r.dead[i][ord]
+}
+
+// Locate returns the segment position and ordinal of the newest version
+// of a document, which is live unless IsDead says otherwise.
+func (r *IndexReader) Locate(docID string) (int, uint32, bool) {
+	for i := len(r.Segments) - 1; i >= 0; i-- {
+		if ord, ok := r.Segments[i].Ordinal(docID); ok {
+			return i, ord, true
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,51 @@
+package indexer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexReader(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	first := NewInvertedIndex()
+	for _, docID := range []string{"1", "2", "3"} {
+		first.AddDocument(docID, "Page "+docID)
+		first.Add("page", docID, NewPosting(TITLE, 1))
+	}
+	require.NoError(t, NewIndexWriter(indexPath).WriteIndex(first))
+
+	second := NewInvertedIndex()
+	second.AddDocument("2", "Page 2")
+	second.Add("page", "2", NewPosting(TITLE, 1))
+	writer := NewIndexWriter(indexPath)
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+	_, _, err := writer.Delete("3")
+	require.NoError(t, err)
+
+	r, err := OpenIndexReader(indexPath)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, r.Close()) }()
+
+	require.Len(t, r.Segments, 2)
+	require.Len(t, r.Manifests, 2)
+	for docID, want := range map[string]struct {
+		segment int
+		dead    bool
+	}{"1": {0, false}, "2": {1, false}, "3": {0, true}} {
+		i, ord, ok := r.Locate(docID)
+		require.True(t, ok, docID)
+		assert.Equal(t, want.segment, i, docID)
+		assert.Equal(t, want.dead, r.IsDead(i, ord), docID)
+	}
+	ord, ok := r.Segments[0].Ordinal("2")
+	require.True(t, ok)
+	assert.True(t, r.IsDead(0, ord), "superseded")
+
+	_, _, ok = r.Locate("4")
+	assert.False(t, ok)
+}
@@ -5,71 +5,235 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
+	"strings"
+	"sync"
 )
 
+// IndexWriter turns in-memory inverted indexes into immutable segments and
+// publishes them through commit points. A single IndexWriter may be used
+// from several goroutines, but only one writer should modify an index
+// directory at a time.
 type IndexWriter struct {
-	indexPath string
+	indexPath   string
+	source      SourceInfo
+	mergePolicy MergePolicy
+
+	// mutex serializes publishing segments and writing commits.
+	mutex        sync.Mutex
+	cleanup      sync.Once
+	merges       sync.WaitGroup
+	merging      bool
+	mergePending bool
+	mergeErr     error
 }
 
 func NewIndexWriter(indexPath string) *IndexWriter {
-	return &IndexWriter{indexPath: indexPath}
+	return &IndexWriter{
+		indexPath:   indexPath,
+		mergePolicy: NewLogMergePolicy(),
+	}
+}
+
+// SetSource records the dump the index is built from in its manifest.
+func (w *IndexWriter) SetSource(source SourceInfo) {
+	w.source = source
+}
+
+// SetMergePolicy replaces the policy used to pick background merges.
+func (w *IndexWriter) SetMergePolicy(policy MergePolicy) {
+	w.mergePolicy = policy
 }
 
+// WriteIndex replaces the whole index with a single segment built from
+// index. The previous commit stays live until the new one is complete.
 func (w *IndexWriter) WriteIndex(index *InvertedIndex) error {
-	if err := os.MkdirAll(w.indexPath, 0755); err != nil {
+	return w.addSegment(index, NewManifest(w.source), true)
+}
+
+// AppendIndex adds index as a new segment after the existing ones and
+// schedules background merges. Documents in the new segment supersede
+// older versions with the same ID.
+func (w *IndexWriter) AppendIndex(index *InvertedIndex) error {
+	if err := w.addSegment(index, NewManifest(w.source), false); err != nil {
 		return err
 	}
+	w.maybeMerge()
+	return nil
+}
 
-	for char := 'a'; char <= 'z'; char++ {
-		if err := w.writeIndexFile(char, index); err != nil {
-			return err
-		}
+func (w *IndexWriter) addSegment(index *InvertedIndex, manifest *Manifest, replace bool) error {
+	tempDir, err := w.buildSegment(index, manifest, !replace)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
 
-func (w *IndexWriter) writeIndexFile(char rune, index *InvertedIndex) error {
-	filename := filepath.Join(w.indexPath, fmt.Sprintf("index%c.idx", char))
-	file, err := os.Create(filename)
+	commit, err := readCommitOrEmpty(w.indexPath)
 	if err != nil {
+		_ = os.RemoveAll(tempDir)
 		return err
 	}
-	defer func() { _ = file.Close() }()
 
-	writer := bufio.NewWriter(file)
-	defer func() { _ = writer.Flush() }()
+	name, err := publishSegment(w.indexPath, tempDir)
+	if err != nil {
+		_ = os.RemoveAll(tempDir)
+		return err
+	}
 
-	var terms []string
-	index.mutex.RLock()
-	for term := range index.Index {
-		if len(term) > 0 && rune(term[0]) == char {
-			terms = append(terms, term)
-		}
+	seg := SegmentInfo{Name: name, DocCount: len(index.Docs)}
+	if replace {
+		commit.Segments = []SegmentInfo{seg}
+	} else {
+		commit.Segments = append(commit.Segments, seg)
+	}
+	return writeCommit(w.indexPath, commit)
+}
+
+// buildSegment writes index into a fresh temp directory inside the index
+// and returns its path. Every file is flushed and synced before returning.
+// If shared, the segment joins the committed ones, whose articles its
+// redirects may lead to.
+func (w *IndexWriter) buildSegment(index *InvertedIndex, manifest *Manifest, shared bool) (string, error) {
+	if err := os.MkdirAll(w.indexPath, 0755); err != nil {
+		return "", NewInvalidPathError(w.indexPath, err)
+	}
+
+	var cleanupErr error
+	w.cleanup.Do(func() { cleanupErr = removeStaleTemps(w.indexPath) })
+	if cleanupErr != nil {
+		return "", NewIOError("clean index directory", cleanupErr)
+	}
+
+	tempDir, err := os.MkdirTemp(w.indexPath, tempPrefix)
+	if err != nil {
+		return "", NewIOError("create temp directory", err)
 	}
+
+	if err := w.writeSegment(tempDir, index, manifest, shared); err != nil {
+		_ = os.RemoveAll(tempDir)
+		return "", err
+	}
+	return tempDir, nil
+}
+
+func (w *IndexWriter) writeSegment(dir string, index *InvertedIndex, manifest *Manifest, shared bool) error {
+	indexAnchors(index)
+
+	index.mutex.RLock()
+	docIDs := sortedKeys(index.Docs)
 	index.mutex.RUnlock()
 
-	sort.Strings(terms)
+	ordinals := make(map[string]uint32, len(docIDs))
+	for ord, docID := range docIDs {
+		ordinals[docID] = uint32(ord)
+	}
 
-	for _, term := range terms {
-		index.mutex.RLock()
-		postings := index.Index[term]
-		index.mutex.RUnlock()
+	infos, err := writePostingsFile(dir, index, ordinals)
+	if err != nil {
+		return err
+	}
+	if err := writeTermsFile(dir, infos); err != nil {
+		return err
+	}
+	if err := writeKGramsFile(dir, infos); err != nil {
+		return err
+	}
 
-		var docIDs []string
-		for docID := range postings {
-			docIDs = append(docIDs, docID)
+	if err := w.writeDocsFile(dir, index, docIDs); err != nil {
+		return err
+	}
+	var external map[string]int
+	if shared {
+		if external, err = w.articleLengths(missingArticles(index, docIDs)); err != nil {
+			return err
 		}
-		sort.Strings(docIDs)
+	}
+	if err := writeTitlesFiles(dir, index, docIDs, external); err != nil {
+		return err
+	}
+	if err := writeAbstractsFile(dir, index, docIDs); err != nil {
+		return err
+	}
+	if err := writeTextFile(dir, index, docIDs); err != nil {
+		return err
+	}
+	if err := writeLinksFile(dir, index, docIDs); err != nil {
+		return err
+	}
+	if err := writeAnchorsFile(dir, index, docIDs); err != nil {
+		return err
+	}
+	if err := writeCategoriesFile(dir, index, docIDs); err != nil {
+		return err
+	}
+	if err := writeFacetsFiles(dir, index, docIDs); err != nil {
+		return err
+	}
+
+	// The manifest is written last, with the checksums of the others.
+	m := *manifest
+	if m.Files, err = checksumFiles(dir); err != nil {
+		return err
+	}
+	if err := WriteManifest(dir, &m); err != nil {
+		return NewIOError("write manifest", err)
+	}
+	return nil
+}
 
-		_, _ = fmt.Fprintf(writer, "%s", term)
+func (w *IndexWriter) writeDocsFile(dir string, index *InvertedIndex, docIDs []string) error {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return writeBuffered(filepath.Join(dir, DocsFile), func(writer *bufio.Writer) error {
 		for _, docID := range docIDs {
-			posting := postings[docID]
-			_, _ = fmt.Fprintf(writer, ":%s$%s", docID, posting.String())
+			info := index.Docs[docID]
+			line := fmt.Sprintf("%s\t%d\t%s", docID, info.Length, singleLine(info.Title))
+			if info.Redirect != "" {
+				line += "\t" + singleLine(info.Redirect)
+			}
+			if _, err := fmt.Fprintln(writer, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// singleLine replaces the tabs and line breaks of a title with spaces.
+func singleLine(title string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
 		}
-		_, _ = fmt.Fprintln(writer)
+		return r
+	}, title)
+}
+
+// writeBuffered creates path, lets write fill it through a buffered writer
+// and then flushes, syncs and closes it, reporting the first failure.
+func writeBuffered(path string, write func(*bufio.Writer) error) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return NewIOError("create "+filepath.Base(path), err)
 	}
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = NewIOError("close "+filepath.Base(path), closeErr)
+		}
+	}()
 
+	writer := bufio.NewWriter(file)
+	if err := write(writer); err != nil {
+		return NewIOError("write "+filepath.Base(path), err)
+	}
+	if err := writer.Flush(); err != nil {
+		return NewIOError("flush "+filepath.Base(path), err)
+	}
+	if err := file.Sync(); err != nil {
+		return NewIOError("sync "+filepath.Base(path), err)
+	}
 	return nil
 }
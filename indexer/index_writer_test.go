@@ -1,7 +1,8 @@
 package indexer
 
 import (
-	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -18,48 +19,183 @@ func TestIndexWriter_WriteIndex(t *testing.T) {
 
 	idx := NewInvertedIndex()
 	// Add terms starting with different letters
-	idx.Add("apple", "doc1", Posting{Fields: TITLE, Frequency: 1})
-	idx.Add("ant", "doc2", Posting{Fields: BODY, Frequency: 2})
-	idx.Add("banana", "doc1", Posting{Fields: BODY, Frequency: 1})
-	idx.Add("cherry", "doc3", Posting{Fields: TITLE | BODY, Frequency: 3})
+	idx.Add("apple", "doc1", NewPosting(TITLE, 1))
+	idx.Add("ant", "doc2", NewPosting(BODY, 2))
+	idx.Add("banana", "doc1", NewPosting(BODY, 1))
+	idx.Add("cherry", "doc3", NewPosting(TITLE, 1))
+	idx.Add("cherry", "doc3", NewPosting(BODY, 2))
+	idx.AddDocument("doc1", "Apple\tBanana")
 
 	writer := NewIndexWriter(indexPath)
+	writer.SetSource(SourceInfo{Name: "test.xml", Checksum: "sha256:00"})
 	err := writer.WriteIndex(idx)
 	require.NoError(t, err)
 
-	// Verify files a-z are created
-	for char := 'a'; char <= 'z'; char++ {
-		filename := filepath.Join(indexPath, fmt.Sprintf("index%c.idx", char))
-		assert.FileExists(t, filename)
+	commit, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	require.Equal(t, []SegmentInfo{{Name: "_0", DocCount: 3}}, commit.Segments)
+	genPath := SegmentPath(indexPath, commit.Segments[0].Name)
+
+	manifest, err := ReadManifest(genPath)
+	require.NoError(t, err)
+	assert.Equal(t, "test.xml", manifest.Source.Name)
+
+	// Verify the segment files are created
+	for _, name := range []string{TermsFile, PostingsFile, DocsFile, ManifestFile} {
+		assert.FileExists(t, filepath.Join(genPath, name))
 	}
 
-	// Verify content of indexa.idx (should have ant and apple, sorted)
-	contentA := readIndexFile(t, filepath.Join(indexPath, "indexa.idx"))
+	// Verify terms starting with a (should have ant and apple, sorted)
+	contentA := dumpTerms(t, genPath, "a")
 	require.Len(t, contentA, 2)
 	assert.True(t, strings.HasPrefix(contentA[0], "ant:"))
 	assert.True(t, strings.HasPrefix(contentA[1], "apple:"))
 
-	// Verify specific format of an entry
-	// Format: term:docID$fields$freq
+	// Verify specific postings of an entry
+	// Dumped as: term:docID$fields$freq
 	// ant:doc2$8$2
 	assert.Equal(t, "ant:doc2$8$2", contentA[0])
 	assert.Equal(t, "apple:doc1$32$1", contentA[1])
 
-	// Verify indexb.idx
-	contentB := readIndexFile(t, filepath.Join(indexPath, "indexb.idx"))
+	// Verify terms starting with b
+	contentB := dumpTerms(t, genPath, "b")
 	require.Len(t, contentB, 1)
 	assert.Equal(t, "banana:doc1$8$1", contentB[0])
+
+	// Verify the document table with lengths in tokens
+	docs, err := ReadSegmentDocs(genPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]DocInfo{
+		"doc1": {Title: "Apple Banana", Length: 2},
+		"doc2": {Length: 2},
+		"doc3": {Length: 3},
+	}, docs)
+}
+
+func TestIndexWriter_Commits(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	first := NewInvertedIndex()
+	first.Add("apple", "doc1", NewPosting(TITLE, 1))
+	require.NoError(t, NewIndexWriter(indexPath).WriteIndex(first))
+
+	firstCommit, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	firstSeg := SegmentPath(indexPath, firstCommit.Segments[0].Name)
+
+	// A build that crashed before publishing leaves only a temp directory
+	// behind; readers keep seeing the previous commit.
+	stale := filepath.Join(indexPath, tempPrefix+"crashed")
+	require.NoError(t, os.MkdirAll(stale, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(stale, TermsFile), []byte("apr"), 0644))
+
+	current, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, firstCommit, current)
+
+	second := NewInvertedIndex()
+	second.Add("avocado", "doc2", NewPosting(BODY, 1))
+	writer := NewIndexWriter(indexPath)
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+
+	appended, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, firstCommit.Generation+1, appended.Generation)
+	require.Len(t, appended.Segments, 2)
+	assert.Equal(t, firstCommit.Segments[0], appended.Segments[0])
+	assert.Equal(t, 2, appended.DocCount())
+	assert.NoDirExists(t, stale)
+
+	third := NewInvertedIndex()
+	third.Add("apricot", "doc3", NewPosting(BODY, 1))
+	require.NoError(t, NewIndexWriter(indexPath).WriteIndex(third))
+
+	rebuilt, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	require.Len(t, rebuilt.Segments, 1)
+	rebuiltSeg := SegmentPath(indexPath, rebuilt.Segments[0].Name)
+	assert.Equal(t, []string{"apricot:doc3$8$1"}, dumpTerms(t, rebuiltSeg, "a"))
+
+	names := func() []string {
+		entries, err := os.ReadDir(indexPath)
+		require.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	// The previous commit keeps its segments for the searchers that read
+	// it; older ones are removed.
+	assert.ElementsMatch(t, []string{
+		commitName(appended.Generation), appended.Segments[0].Name, appended.Segments[1].Name,
+		commitName(rebuilt.Generation), rebuilt.Segments[0].Name,
+	}, names())
+
+	fourth := NewInvertedIndex()
+	fourth.Add("apple", "doc4", NewPosting(BODY, 1))
+	require.NoError(t, NewIndexWriter(indexPath).WriteIndex(fourth))
+	latest, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	assert.NoDirExists(t, firstSeg)
+	assert.ElementsMatch(t, []string{
+		commitName(rebuilt.Generation), rebuilt.Segments[0].Name,
+		commitName(latest.Generation), latest.Segments[0].Name,
+	}, names())
 }
 
-func readIndexFile(t *testing.T, path string) []string {
-	file, err := os.Open(path)
+func TestReadCommit_Errors(t *testing.T) {
+	var wikiErr *WikiError
+
+	_, err := ReadCommit(filepath.Join(t.TempDir(), "missing"))
+	require.True(t, errors.As(err, &wikiErr))
+	assert.Equal(t, ErrIndexNotFound, wikiErr.Type)
+
+	_, err = ReadCommit(t.TempDir())
+	require.True(t, errors.As(err, &wikiErr))
+	assert.Equal(t, ErrIndexNotFound, wikiErr.Type)
+
+	indexPath := t.TempDir()
+	data, err := encodeCommit(&CommitPoint{Generation: 1, Segments: []SegmentInfo{{Name: "../x"}}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(indexPath, "segments_1"), data, 0644))
+	_, err = ReadCommit(indexPath)
+	require.True(t, errors.As(err, &wikiErr))
+	assert.Equal(t, ErrIncompatibleIndex, wikiErr.Type)
+
+	data, err = encodeCommit(&CommitPoint{Generation: 2, Segments: []SegmentInfo{{Name: "_0", DocCount: 5}}})
 	require.NoError(t, err)
-	defer func() { _ = file.Close() }()
+	data = bytes.Replace(data, []byte(`"doc_count": 5`), []byte(`"doc_count": 6`), 1)
+	require.NoError(t, os.WriteFile(filepath.Join(indexPath, "segments_2"), data, 0644))
+	_, err = ReadCommit(indexPath)
+	require.True(t, errors.As(err, &wikiErr))
+	assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+}
+
+// dumpTerms decodes the terms of the segment in dir that start with prefix
+// as "term:docID$fields$freq:..." lines.
+func dumpTerms(t *testing.T, dir, prefix string) []string {
+	r, err := OpenSegmentReader(dir)
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
 
 	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	for i := 0; i < r.NumTerms(); i++ {
+		info, err := r.TermAt(i)
+		require.NoError(t, err)
+		if !strings.HasPrefix(info.Term, prefix) {
+			continue
+		}
+
+		it, err := r.Postings(info)
+		require.NoError(t, err)
+		line := info.Term
+		for it.Next() {
+			line += fmt.Sprintf(":%s$%s", r.DocID(it.Doc()), it.Posting())
+		}
+		require.NoError(t, it.Err())
+		lines = append(lines, line)
 	}
 	return lines
 }
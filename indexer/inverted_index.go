@@ -2,20 +2,82 @@ package indexer
 
 import (
 	"fmt"
+	"math/bits"
 	"sync"
 )
 
 type InvertedIndex struct {
 	Index map[string]map[string]Posting
-	mutex sync.RWMutex
+	// Docs holds the title and length in tokens of every indexed document.
+	Docs map[string]DocInfo
+	// Forms counts, per term, the documents in which each word was the
+	// one the term was most often stemmed from.
+	Forms map[string]map[string]int
+	// Abstracts holds the opening text of every document.
+	Abstracts map[string]string
+	// Texts holds the plain text of every document, compressed as in
+	// TextFile, for snippets.
+	Texts map[string][]byte
+	// Links holds the titles every document links to, as LinkTargets
+	// returns them.
+	Links map[string][]string
+	// Categories holds the names of the categories of every document, as
+	// CategoryNames returns them.
+	Categories map[string][]string
+	// Facets holds the facet values of every document besides its
+	// categories.
+	Facets map[string][]FacetValue
+	// Anchors holds the links of every document with their anchor texts,
+	// as LinkAnchors returns them.
+	Anchors map[string][]Anchor
+	mutex   sync.RWMutex
 }
 
 func NewInvertedIndex() *InvertedIndex {
 	return &InvertedIndex{
-		Index: make(map[string]map[string]Posting),
+		Index:      make(map[string]map[string]Posting),
+		Docs:       make(map[string]DocInfo),
+		Forms:      make(map[string]map[string]int),
+		Abstracts:  make(map[string]string),
+		Texts:      make(map[string][]byte),
+		Links:      make(map[string][]string),
+		Categories: make(map[string][]string),
+		Facets:     make(map[string][]FacetValue),
+		Anchors:    make(map[string][]Anchor),
 	}
 }
 
+// AddForm records that term was stemmed from form in count documents.
+func (idx *InvertedIndex) AddForm(term, form string, count int) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	if idx.Forms[term] == nil {
+		idx.Forms[term] = make(map[string]int)
+	}
+	idx.Forms[term][form] += count
+}
+
+// hasForm reports whether a form of term was recorded.
+func (idx *InvertedIndex) hasForm(term string) bool {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	return len(idx.Forms[term]) > 0
+}
+
+// form returns the word term was most commonly stemmed from, or term
+// itself if none was recorded. The caller must hold the read lock.
+func (idx *InvertedIndex) form(term string) string {
+	if form := dominantForm(idx.Forms[term]); form != "" {
+		return form
+	}
+	return term
+}
+
+// Add records posting for term in docID, adding to what is already there.
+// Anchor text is written by other pages, so it does not count towards the
+// length of the document.
 func (idx *InvertedIndex) Add(term, docID string, posting Posting) {
 	idx.mutex.Lock()
 	defer idx.mutex.Unlock()
@@ -24,12 +86,107 @@ func (idx *InvertedIndex) Add(term, docID string, posting Posting) {
 		idx.Index[term] = make(map[string]Posting)
 	}
 
+	info := idx.Docs[docID]
+	info.Length += posting.Frequency - posting.FieldFrequency(ANCHOR)
+	idx.Docs[docID] = info
+
 	existing := idx.Index[term][docID]
 	existing.Fields |= posting.Fields
 	existing.Frequency += posting.Frequency
+	for i, freq := range posting.FieldFreqs {
+		existing.FieldFreqs[i] += freq
+	}
 	idx.Index[term][docID] = existing
 }
 
+// AddDocument registers docID with its title, so that it is stored even if
+// it yields no terms.
+func (idx *InvertedIndex) AddDocument(docID, title string) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	info := idx.Docs[docID]
+	info.Title = title
+	idx.Docs[docID] = info
+}
+
+// SetRedirect marks docID as a redirect to the article titled target.
+func (idx *InvertedIndex) SetRedirect(docID, target string) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	info := idx.Docs[docID]
+	info.Redirect = target
+	idx.Docs[docID] = info
+}
+
+// SetAbstract stores the opening text of docID.
+func (idx *InvertedIndex) SetAbstract(docID, abstract string) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.Abstracts[docID] = abstract
+}
+
+// SetText stores the plain text of docID, which is compressed right away.
+func (idx *InvertedIndex) SetText(docID, text string) {
+	compressed := compressText(text)
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.Texts[docID] = compressed
+}
+
+// SetLinks stores the titles docID links to.
+func (idx *InvertedIndex) SetLinks(docID string, targets []string) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.Links[docID] = targets
+}
+
+// SetCategories stores the names of the categories of docID.
+func (idx *InvertedIndex) SetCategories(docID string, names []string) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.Categories[docID] = names
+}
+
+// SetFacets stores the facet values of docID besides its categories.
+func (idx *InvertedIndex) SetFacets(docID string, values []FacetValue) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.Facets[docID] = values
+}
+
+// SetAnchors stores the links of docID with their anchor texts.
+func (idx *InvertedIndex) SetAnchors(docID string, anchors []Anchor) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.Anchors[docID] = anchors
+}
+
+// NewPosting returns a posting for freq occurrences in a single field.
+func NewPosting(field FieldMask, freq int) Posting {
+	p := Posting{Fields: field, Frequency: freq}
+	p.FieldFreqs[fieldIndex(field)] = uint32(freq)
+	return p
+}
+
+// fieldIndex returns the bit position of a single field.
+func fieldIndex(field FieldMask) int {
+	return bits.TrailingZeros8(uint8(field))
+}
+
+// FieldFrequency returns how often the term occurs in field.
+func (p Posting) FieldFrequency(field FieldMask) int {
+	return int(p.FieldFreqs[fieldIndex(field)])
+}
+
 func (p Posting) String() string {
 	return fmt.Sprintf("%d$%d", p.Fields, p.Frequency)
 }
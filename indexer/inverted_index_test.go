@@ -21,11 +21,11 @@ func TestInvertedIndex_Add(t *testing.T) {
 			term:  "test",
 			docID: "doc1",
 			postings: []Posting{
-				{Fields: BODY, Frequency: 1},
+				NewPosting(BODY, 1),
 			},
 			expected: map[string]map[string]Posting{
 				"test": {
-					"doc1": {Fields: BODY, Frequency: 1},
+					"doc1": NewPosting(BODY, 1),
 				},
 			},
 		},
@@ -34,12 +34,26 @@ func TestInvertedIndex_Add(t *testing.T) {
 			term:  "test",
 			docID: "doc1",
 			postings: []Posting{
-				{Fields: BODY, Frequency: 1},
-				{Fields: BODY, Frequency: 2},
+				NewPosting(BODY, 1),
+				NewPosting(BODY, 2),
 			},
 			expected: map[string]map[string]Posting{
 				"test": {
-					"doc1": {Fields: BODY, Frequency: 3},
+					"doc1": NewPosting(BODY, 3),
+				},
+			},
+		},
+		{
+			name:  "merge fields",
+			term:  "test",
+			docID: "doc1",
+			postings: []Posting{
+				NewPosting(TITLE, 1),
+				NewPosting(BODY, 4),
+			},
+			expected: map[string]map[string]Posting{
+				"test": {
+					"doc1": {Fields: TITLE | BODY, Frequency: 5, FieldFreqs: [NumFields]uint32{3: 4, 5: 1}},
 				},
 			},
 		},
@@ -69,7 +83,7 @@ func TestInvertedIndex_ConcurrentAdd(t *testing.T) {
 			term := fmt.Sprintf("term-%d", id%10)
 			docID := fmt.Sprintf("doc-%d", id)
 			for j := 0; j < numAddsPerRoutine; j++ {
-				idx.Add(term, docID, Posting{Fields: BODY, Frequency: 1})
+				idx.Add(term, docID, NewPosting(BODY, 1))
 			}
 		}(i)
 	}
@@ -90,8 +104,8 @@ func TestPosting_String(t *testing.T) {
 		posting  Posting
 		expected string
 	}{
-		{"basic", Posting{Fields: BODY, Frequency: 5}, "8$5"},
-		{"zero", Posting{Fields: 0, Frequency: 0}, "0$0"},
+		{"basic", NewPosting(BODY, 5), "8$5"},
+		{"zero", Posting{}, "0$0"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,152 @@
+package indexer
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LinksFile holds the pages every document of a segment links to, so that
+// the link graph can be rebuilt across segments. It is a table file (see
+// writeTable) with one entry per document in ordinal order, the entry
+// being the distinct target titles, normalized with TitleKey, sorted and
+// separated by newlines.
+const LinksFile = "links.dat"
+
+var linksMagic = []byte("WFLK")
+
+var (
+	linkTargetRegex = regexp.MustCompile(`\[\[([^\[\]|]+)`)
+	linkAnchorRegex = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|([^\[\]]*))?\]\]`)
+)
+
+// linkNamespaces are the namespaces of link targets that are not articles.
+var linkNamespaces = map[string]bool{
+	"category": true, "file": true, "image": true, "media": true,
+	"template": true, "wikipedia": true, "help": true, "portal": true,
+	"user": true, "talk": true, "special": true,
+}
+
+// LinkTargets returns the titles of the articles the wiki text links to,
+// normalized with TitleKey, sorted and without duplicates. Section anchors
+// are dropped, so "[[Paris#History|history]]" links to "paris", and links
+// into other namespaces, such as categories and files, are left out.
+func LinkTargets(wikiText string) []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, match := range linkTargetRegex.FindAllStringSubmatch(wikiText, -1) {
+		if key, ok := linkTarget(match[1]); ok && !seen[key] {
+			seen[key] = true
+			targets = append(targets, key)
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// linkTarget returns the key of the article a link target names, and false
+// for links to sections of the same page or into other namespaces.
+func linkTarget(target string) (string, bool) {
+	target, _, _ = strings.Cut(target, "#")
+	target = strings.TrimPrefix(strings.TrimSpace(target), ":")
+	if namespace, _, ok := strings.Cut(target, ":"); ok && linkNamespaces[TitleKey(namespace)] {
+		return "", false
+	}
+	key := TitleKey(target)
+	return key, key != ""
+}
+
+// Anchor is the text of a link, which describes the article it leads to.
+// Target is the title of the article, normalized with TitleKey.
+type Anchor struct {
+	Target string
+	Text   string
+}
+
+// LinkAnchors returns the links of the wiki text to articles in the order
+// they appear, with their anchor texts: the part after the pipe, or the
+// target itself for a link without one, with runs of white space
+// collapsed.
+func LinkAnchors(wikiText string) []Anchor {
+	var anchors []Anchor
+	for _, match := range linkAnchorRegex.FindAllStringSubmatch(wikiText, -1) {
+		key, ok := linkTarget(match[1])
+		if !ok {
+			continue
+		}
+		text := strings.Join(strings.Fields(match[2]), " ")
+		if text == "" {
+			text = strings.Join(strings.Fields(match[1]), " ")
+		}
+		anchors = append(anchors, Anchor{Target: key, Text: text})
+	}
+	return anchors
+}
+
+// titleResolver finds the articles that titles lead to, directly or
+// through redirects, ignoring case.
+type titleResolver struct {
+	articles  map[string]string
+	redirects map[string]string
+}
+
+func newTitleResolver() *titleResolver {
+	return &titleResolver{articles: make(map[string]string), redirects: make(map[string]string)}
+}
+
+// add registers a document; for several articles with the same key the
+// first one wins.
+func (r *titleResolver) add(docID string, info DocInfo) {
+	key := TitleKey(info.Title)
+	if info.Redirect != "" {
+		r.redirects[key] = TitleKey(info.Redirect)
+	} else if _, ok := r.articles[key]; !ok {
+		r.articles[key] = docID
+	}
+}
+
+// resolve returns the ID of the article key leads to.
+func (r *titleResolver) resolve(key string) (string, bool) {
+	for range maxRedirectHops + 1 {
+		if docID, ok := r.articles[key]; ok {
+			return docID, true
+		}
+		target, ok := r.redirects[key]
+		if !ok {
+			break
+		}
+		key = target
+	}
+	return "", false
+}
+
+// writeLinksFile writes the link targets of the documents of index, in
+// ordinal order.
+func writeLinksFile(dir string, index *InvertedIndex, docIDs []string) error {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return writeTable(filepath.Join(dir, LinksFile), linksMagic, len(docIDs), func(i int, buf []byte) []byte {
+		for j, target := range index.Links[docIDs[i]] {
+			if j > 0 {
+				buf = append(buf, '\n')
+			}
+			buf = append(buf, target...)
+		}
+		return buf
+	})
+}
+
+// Links returns the titles the document with ordinal ord links to, as
+// LinkTargets gives them.
+func (r *SegmentReader) Links(ord uint32) ([]string, error) {
+	if int(ord) >= r.links.numEntries {
+		return nil, NewCorruptIndexError(r.links.path, "missing entry")
+	}
+	raw, err := r.links.entry(int(ord))
+	if err != nil || len(raw) == 0 {
+		return nil, err
+	}
+	return strings.Split(string(raw), "\n"), nil
+}
@@ -0,0 +1,194 @@
+package indexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FormatVersion is bumped whenever the on-disk layout of the index changes
+// in a way older readers cannot handle.
+const FormatVersion = 21
+
+const ManifestFile = "manifest.json"
+
+// Manifest records how an index was built so that a reader can refuse an
+// index produced by a different analyzer chain or file layout.
+type Manifest struct {
+	FormatVersion int            `json:"format_version"`
+	Analyzer      AnalyzerConfig `json:"analyzer"`
+	Fields        []FieldDef     `json:"fields"`
+	Source        SourceInfo     `json:"source"`
+	BuildTime     time.Time      `json:"build_time"`
+	// Files lists the other files of a segment with their checksums.
+	Files []FileChecksum `json:"files,omitempty"`
+}
+
+type AnalyzerConfig struct {
+	Tokenizer      string   `json:"tokenizer"`
+	MinTokenLength int      `json:"min_token_length"`
+	Lowercase      bool     `json:"lowercase"`
+	Stemmer        string   `json:"stemmer"`
+	Stopwords      []string `json:"stopwords"`
+	Language       string   `json:"language"`
+}
+
+type FieldDef struct {
+	Name string    `json:"name"`
+	Mask FieldMask `json:"mask"`
+}
+
+type SourceInfo struct {
+	Name     string `json:"name"`
+	Checksum string `json:"checksum"`
+}
+
+// DefaultAnalyzer describes the analyzer chain implemented by WikiTextParser.
+func DefaultAnalyzer() AnalyzerConfig {
+	stopwords := make([]string, 0, len(stopWords))
+	for word := range stopWords {
+		stopwords = append(stopwords, word)
+	}
+	sort.Strings(stopwords)
+
+	return AnalyzerConfig{
+		Tokenizer:      "regexp:" + tokenPattern,
+		MinTokenLength: 2,
+		Lowercase:      true,
+		Stemmer:        "porter",
+		Stopwords:      stopwords,
+		Language:       "en",
+	}
+}
+
+// DefaultFields lists the fields WikiTextParser and the anchor texts of
+// links can emit.
+func DefaultFields() []FieldDef {
+	return []FieldDef{
+		{Name: "geobox", Mask: GEOBOX},
+		{Name: "infobox", Mask: INFOBOX},
+		{Name: "links", Mask: LINKS},
+		{Name: "body", Mask: BODY},
+		{Name: "category", Mask: CATEGORY},
+		{Name: "title", Mask: TITLE},
+		{Name: "anchor", Mask: ANCHOR},
+	}
+}
+
+// ParseFields returns the mask of a comma separated list of field names,
+// such as "title,category".
+func ParseFields(names string) (FieldMask, error) {
+	fields := DefaultFields()
+	var mask FieldMask
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		i := slices.IndexFunc(fields, func(f FieldDef) bool { return f.Name == name })
+		if i < 0 {
+			return 0, fmt.Errorf("unknown field %q", name)
+		}
+		mask |= fields[i].Mask
+	}
+	return mask, nil
+}
+
+// Names returns the names of the fields in m, in bit order.
+func (m FieldMask) Names() []string {
+	var names []string
+	for _, f := range DefaultFields() {
+		if m&f.Mask != 0 {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+func NewManifest(source SourceInfo) *Manifest {
+	return &Manifest{
+		FormatVersion: FormatVersion,
+		Analyzer:      DefaultAnalyzer(),
+		Fields:        DefaultFields(),
+		Source:        source,
+		BuildTime:     time.Now().UTC(),
+	}
+}
+
+// CheckCompatible reports whether an index described by m can be searched
+// with the analyzer chain compiled into this binary.
+func (m *Manifest) CheckCompatible() error {
+	if m.FormatVersion != FormatVersion {
+		return fmt.Errorf("format version %d, expected %d", m.FormatVersion, FormatVersion)
+	}
+
+	want := DefaultAnalyzer()
+	got := m.Analyzer
+	switch {
+	case got.Tokenizer != want.Tokenizer:
+		return fmt.Errorf("tokenizer %q, expected %q", got.Tokenizer, want.Tokenizer)
+	case got.MinTokenLength != want.MinTokenLength:
+		return fmt.Errorf("minimum token length %d, expected %d", got.MinTokenLength, want.MinTokenLength)
+	case got.Lowercase != want.Lowercase:
+		return fmt.Errorf("lowercase %t, expected %t", got.Lowercase, want.Lowercase)
+	case got.Stemmer != want.Stemmer:
+		return fmt.Errorf("stemmer %q, expected %q", got.Stemmer, want.Stemmer)
+	case got.Language != want.Language:
+		return fmt.Errorf("language %q, expected %q", got.Language, want.Language)
+	case !slices.Equal(got.Stopwords, want.Stopwords):
+		return fmt.Errorf("stopword list differs from the %d built-in stopwords", len(want.Stopwords))
+	}
+
+	fields := make(map[string]FieldMask, len(m.Fields))
+	for _, field := range m.Fields {
+		fields[field.Name] = field.Mask
+	}
+	for _, field := range DefaultFields() {
+		mask, ok := fields[field.Name]
+		if !ok {
+			return fmt.Errorf("field %q is not defined", field.Name)
+		}
+		if mask != field.Mask {
+			return fmt.Errorf("field %q has mask %d, expected %d", field.Name, mask, field.Mask)
+		}
+	}
+
+	return nil
+}
+
+func WriteManifest(indexPath string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileSync(filepath.Join(indexPath, ManifestFile), append(data, '\n'))
+}
+
+// ReadManifest loads the manifest of the index at indexPath and verifies
+// that it is compatible with this binary.
+func ReadManifest(indexPath string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(indexPath, ManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		if _, statErr := os.Stat(indexPath); statErr != nil {
+			return nil, NewIndexNotFoundError(indexPath)
+		}
+		return nil, NewIncompatibleIndexError(indexPath, "missing "+ManifestFile, err)
+	}
+	if err != nil {
+		return nil, NewIOError("read manifest", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, NewIncompatibleIndexError(indexPath, "malformed "+ManifestFile, err)
+	}
+
+	if err := m.CheckCompatible(); err != nil {
+		return nil, NewIncompatibleIndexError(indexPath, err.Error(), nil)
+	}
+
+	return &m, nil
+}
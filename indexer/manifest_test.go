@@ -0,0 +1,83 @@
+package indexer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest_CheckCompatible(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(*Manifest)
+		expectErr bool
+	}{
+		{"default", func(m *Manifest) {}, false},
+		{"format version", func(m *Manifest) { m.FormatVersion = 0 }, true},
+		{"stemmer", func(m *Manifest) { m.Analyzer.Stemmer = "snowball" }, true},
+		{"tokenizer", func(m *Manifest) { m.Analyzer.Tokenizer = "whitespace" }, true},
+		{"language", func(m *Manifest) { m.Analyzer.Language = "de" }, true},
+		{"stopwords", func(m *Manifest) { m.Analyzer.Stopwords = m.Analyzer.Stopwords[1:] }, true},
+		{"missing field", func(m *Manifest) { m.Fields = m.Fields[1:] }, true},
+		{"field mask", func(m *Manifest) { m.Fields[0].Mask = 1 << 7 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManifest(SourceInfo{})
+			tt.modify(m)
+			err := m.CheckCompatible()
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	tempDir := t.TempDir()
+
+	source := SourceInfo{Name: "enwiki.xml", Checksum: "sha256:abc"}
+	require.NoError(t, WriteManifest(tempDir, NewManifest(source)))
+
+	m, err := ReadManifest(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, m.FormatVersion)
+	assert.Equal(t, source, m.Source)
+	assert.Equal(t, DefaultAnalyzer(), m.Analyzer)
+	assert.False(t, m.BuildTime.IsZero())
+
+	var wikiErr *WikiError
+
+	_, err = ReadManifest(filepath.Join(tempDir, "missing"))
+	require.True(t, errors.As(err, &wikiErr))
+	assert.Equal(t, ErrIndexNotFound, wikiErr.Type)
+
+	emptyDir := t.TempDir()
+	_, err = ReadManifest(emptyDir)
+	require.True(t, errors.As(err, &wikiErr))
+	assert.Equal(t, ErrIncompatibleIndex, wikiErr.Type)
+
+	require.NoError(t, os.WriteFile(filepath.Join(emptyDir, ManifestFile), []byte("{"), 0644))
+	_, err = ReadManifest(emptyDir)
+	require.True(t, errors.As(err, &wikiErr))
+	assert.Equal(t, ErrIncompatibleIndex, wikiErr.Type)
+}
+
+func TestParseFields(t *testing.T) {
+	mask, err := ParseFields("title, Category")
+	require.NoError(t, err)
+	assert.Equal(t, TITLE|CATEGORY, mask)
+	assert.Equal(t, []string{"category", "title"}, mask.Names())
+
+	_, err = ParseFields("title,abstract")
+	assert.ErrorContains(t, err, `unknown field "abstract"`)
+	_, err = ParseFields("")
+	assert.Error(t, err)
+}
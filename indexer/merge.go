@@ -0,0 +1,257 @@
+package indexer
+
+import (
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// MergePolicy decides which segments of a commit should be merged.
+type MergePolicy interface {
+	// FindMerges returns groups of adjacent segments, each of which is
+	// to be merged into a single segment. Groups must not overlap.
+	FindMerges(segments []SegmentInfo) [][]SegmentInfo
+}
+
+// LogMergePolicy groups segments into levels by the logarithm of their
+// document count and merges MergeFactor adjacent segments of the same
+// level, so each document is rewritten a logarithmic number of times.
+type LogMergePolicy struct {
+	MergeFactor int
+	// MinDocs is the size below which all segments share the lowest level.
+	MinDocs int
+}
+
+func NewLogMergePolicy() *LogMergePolicy {
+	return &LogMergePolicy{
+		MergeFactor: 10,
+		MinDocs:     1000,
+	}
+}
+
+func (p *LogMergePolicy) level(docCount int) int {
+	if docCount <= p.MinDocs {
+		return 0
+	}
+	return int(math.Log(float64(docCount)/float64(p.MinDocs)) / math.Log(float64(p.MergeFactor)))
+}
+
+func (p *LogMergePolicy) FindMerges(segments []SegmentInfo) [][]SegmentInfo {
+	if p.MergeFactor < 2 {
+		return nil
+	}
+
+	var merges [][]SegmentInfo
+	start := 0
+	for start < len(segments) {
+		level := p.level(segments[start].LiveDocs())
+		end := start + 1
+		for end < len(segments) && end-start < p.MergeFactor && p.level(segments[end].LiveDocs()) == level {
+			end++
+		}
+		if end-start == p.MergeFactor {
+			merges = append(merges, segments[start:end])
+		}
+		start = end
+	}
+	return merges
+}
+
+// WaitForMerges blocks until background merges have finished and returns
+// the error of the first merge that failed, if any.
+func (w *IndexWriter) WaitForMerges() error {
+	w.merges.Wait()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	err := w.mergeErr
+	w.mergeErr = nil
+	return err
+}
+
+// maybeMerge starts a background merge pass unless one is already running,
+// in which case that pass re-checks the policy once it is done.
+func (w *IndexWriter) maybeMerge() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.merging {
+		w.mergePending = true
+		return
+	}
+	w.merging = true
+	w.merges.Add(1)
+
+	go func() {
+		defer w.merges.Done()
+		for {
+			err := w.runMerges()
+
+			w.mutex.Lock()
+			if err != nil && w.mergeErr == nil {
+				w.mergeErr = err
+			}
+			if err != nil || !w.mergePending {
+				w.merging = false
+				w.mutex.Unlock()
+				return
+			}
+			w.mergePending = false
+			w.mutex.Unlock()
+		}
+	}()
+}
+
+// runMerges applies the merge policy until it finds nothing to merge.
+func (w *IndexWriter) runMerges() error {
+	for {
+		w.mutex.Lock()
+		commit, err := ReadCommit(w.indexPath)
+		w.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+
+		merges := w.mergePolicy.FindMerges(commit.Segments)
+		if len(merges) == 0 {
+			return nil
+		}
+
+		merged, err := w.merge(merges[0])
+		if err != nil {
+			return err
+		}
+		if !merged {
+			// The commit changed underneath us; look at it again.
+			continue
+		}
+	}
+}
+
+// merge writes the documents of group into a new segment and commits it
+// in place of group. It reports false without error if group is no longer
+// part of the latest commit, e.g. because the index was rebuilt meanwhile.
+func (w *IndexWriter) merge(group []SegmentInfo) (bool, error) {
+	merged, sources, err := w.readGroup(group)
+	if err != nil {
+		return false, err
+	}
+
+	manifest := NewManifest(SourceInfo{Name: strings.Join(sources, ",")})
+	tempDir, err := w.buildSegment(merged, manifest, true)
+	if err != nil {
+		return false, err
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	commit, err := ReadCommit(w.indexPath)
+	if err != nil {
+		_ = os.RemoveAll(tempDir)
+		return false, err
+	}
+
+	start := indexOfGroup(commit.Segments, group)
+	if start < 0 {
+		_ = os.RemoveAll(tempDir)
+		return false, nil
+	}
+
+	name, err := publishSegment(w.indexPath, tempDir)
+	if err != nil {
+		_ = os.RemoveAll(tempDir)
+		return false, err
+	}
+
+	segments := append([]SegmentInfo{}, commit.Segments[:start]...)
+	segments = append(segments, SegmentInfo{Name: name, DocCount: len(merged.Docs)})
+	segments = append(segments, commit.Segments[start+len(group):]...)
+	commit.Segments = segments
+
+	return true, writeCommit(w.indexPath, commit)
+}
+
+// readGroup loads the segments of group into one inverted index, leaving
+// out deleted documents. Where a document occurs in several segments, the
+// newest version wins.
+func (w *IndexWriter) readGroup(group []SegmentInfo) (*InvertedIndex, []string, error) {
+	newer := make(map[string]bool)
+	loaded := make([]*InvertedIndex, len(group))
+	var sources []string
+
+	for i := len(group) - 1; i >= 0; i-- {
+		dir := SegmentPath(w.indexPath, group[i].Name)
+
+		docs, err := ReadSegmentDocs(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		exclude, err := ReadSegmentDeletes(w.indexPath, group[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		maps.Copy(exclude, newer)
+
+		idx, err := loadSegment(dir, exclude)
+		if err != nil {
+			return nil, nil, err
+		}
+		for docID := range docs {
+			newer[docID] = true
+		}
+		loaded[i] = idx
+
+		if manifest, err := ReadManifest(dir); err == nil && manifest.Source.Name != "" {
+			sources = append(sources, strings.Split(manifest.Source.Name, ",")...)
+		}
+	}
+
+	merged := NewInvertedIndex()
+	for _, idx := range loaded {
+		for term, postings := range idx.Index {
+			for docID, posting := range postings {
+				merged.Add(term, docID, posting)
+			}
+		}
+		for docID, info := range idx.Docs {
+			merged.Docs[docID] = info
+		}
+		maps.Copy(merged.Abstracts, idx.Abstracts)
+		maps.Copy(merged.Texts, idx.Texts)
+		maps.Copy(merged.Links, idx.Links)
+		maps.Copy(merged.Anchors, idx.Anchors)
+		maps.Copy(merged.Categories, idx.Categories)
+		maps.Copy(merged.Facets, idx.Facets)
+		for term, forms := range idx.Forms {
+			for form, count := range forms {
+				merged.AddForm(term, form, count)
+			}
+		}
+	}
+
+	sort.Strings(sources)
+	return merged, slices.Compact(sources), nil
+}
+
+// indexOfGroup returns where group starts as a contiguous run inside
+// segments, or -1. Segments that gained tombstones since group was read
+// do not match, so that a merge never drops a concurrent delete.
+func indexOfGroup(segments, group []SegmentInfo) int {
+	for start := 0; start+len(group) <= len(segments); start++ {
+		match := true
+		for i, seg := range group {
+			if segments[start+i] != seg {
+				match = false
+				break
+			}
+		}
+		if match {
+			return start
+		}
+	}
+	return -1
+}
@@ -0,0 +1,120 @@
+package indexer
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogMergePolicy_FindMerges(t *testing.T) {
+	policy := &LogMergePolicy{MergeFactor: 3, MinDocs: 10}
+
+	segs := func(counts ...int) []SegmentInfo {
+		var out []SegmentInfo
+		for i, count := range counts {
+			out = append(out, SegmentInfo{Name: fmt.Sprintf("_%d", i), DocCount: count})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		segments []SegmentInfo
+		expected [][]string
+	}{
+		{"too few", segs(1, 2), nil},
+		{"one level", segs(1, 2, 3), [][]string{{"_0", "_1", "_2"}}},
+		{"mixed levels", segs(100, 1, 2, 3, 4), [][]string{{"_1", "_2", "_3"}}},
+		{"not adjacent", segs(1, 100, 2, 200, 3), nil},
+		{"two groups", segs(1, 1, 1, 50, 50, 50), [][]string{{"_0", "_1", "_2"}, {"_3", "_4", "_5"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names [][]string
+			for _, group := range policy.FindMerges(tt.segments) {
+				var groupNames []string
+				for _, seg := range group {
+					groupNames = append(groupNames, seg.Name)
+				}
+				names = append(names, groupNames)
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func TestIndexWriter_BackgroundMerge(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	writer := NewIndexWriter(indexPath)
+	writer.SetMergePolicy(&LogMergePolicy{MergeFactor: 3, MinDocs: 10})
+
+	// Each batch re-indexes doc0 so that only its newest version survives.
+	for i := 0; i < 3; i++ {
+		idx := NewInvertedIndex()
+		idx.Add(fmt.Sprintf("version%c", 'a'+i), "doc0", NewPosting(BODY, 1))
+		idx.Add("common", fmt.Sprintf("doc%d", i+1), NewPosting(BODY, i+1))
+		idx.AddForm("common", []string{"commoner", "commonly", "commonly"}[i], 1)
+		idx.SetAbstract("doc0", fmt.Sprintf("Version %d.", i))
+		require.NoError(t, writer.AppendIndex(idx))
+	}
+	require.NoError(t, writer.WaitForMerges())
+
+	commit, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	require.Len(t, commit.Segments, 1)
+	assert.Equal(t, 4, commit.Segments[0].DocCount)
+
+	dir := SegmentPath(indexPath, commit.Segments[0].Name)
+	assert.Equal(t, []string{"versionc:doc0$8$1"}, dumpTerms(t, dir, "v"))
+	assert.Equal(t, []string{"common:doc1$8$1:doc2$8$2:doc3$8$3"}, dumpTerms(t, dir, "c"))
+
+	r, err := OpenSegmentReader(dir)
+	require.NoError(t, err)
+	info, _, err := r.Lookup("common")
+	require.NoError(t, err)
+	assert.Equal(t, "commonly", info.Form)
+	ord, ok := r.Ordinal("doc0")
+	require.True(t, ok)
+	abstract, err := r.Abstract(ord)
+	require.NoError(t, err)
+	assert.Equal(t, "Version 2.", abstract)
+	require.NoError(t, r.Close())
+
+	docs, err := ReadSegmentDocs(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]DocInfo{
+		"doc0": {Length: 1},
+		"doc1": {Length: 1},
+		"doc2": {Length: 2},
+		"doc3": {Length: 3},
+	}, docs)
+}
+
+func TestIndexWriter_CompactSegments(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	writer := NewIndexWriter(indexPath)
+	writer.SetMergePolicy(&LogMergePolicy{MergeFactor: 10, MinDocs: 10})
+	for i := 0; i < 3; i++ {
+		idx := NewInvertedIndex()
+		idx.Add("term", fmt.Sprintf("doc%d", i), NewPosting(TITLE, 1))
+		require.NoError(t, writer.AppendIndex(idx))
+	}
+	require.NoError(t, writer.WaitForMerges())
+
+	commit, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	assert.Len(t, commit.Segments, 3)
+
+	require.NoError(t, writer.Compact())
+
+	commit, err = ReadCommit(indexPath)
+	require.NoError(t, err)
+	require.Len(t, commit.Segments, 1)
+	assert.Equal(t, 3, commit.Segments[0].DocCount)
+}
@@ -0,0 +1,28 @@
+//go:build linux
+
+package indexer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the whole file read-only into memory.
+func mmapFile(file *os.File, size int64) (blob, error) {
+	if size == 0 {
+		return &bytesBlob{}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, errors.New("file too large to map")
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &bytesBlob{
+		data:    data,
+		release: func() error { return syscall.Munmap(data) },
+	}, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package indexer
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile is not supported on this platform; openBlob falls back to
+// positional reads.
+func mmapFile(file *os.File, size int64) (blob, error) {
+	return nil, errors.New("mmap not supported")
+}
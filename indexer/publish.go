@@ -0,0 +1,322 @@
+package indexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	commitPrefix  = "segments_"
+	segmentPrefix = "_"
+	tempPrefix    = ".tmp-"
+)
+
+// SegmentInfo describes one immutable segment referenced by a commit.
+type SegmentInfo struct {
+	Name     string `json:"name"`
+	DocCount int    `json:"doc_count"`
+	// DelGen is the generation of the segment's tombstone file, 0 if it
+	// has no deleted documents.
+	DelGen   int `json:"del_gen,omitempty"`
+	DelCount int `json:"del_count,omitempty"`
+	// DelCRC32C is the checksum of the tombstone file.
+	DelCRC32C uint32 `json:"del_crc32c,omitempty"`
+}
+
+// LiveDocs returns the number of documents of the segment not deleted.
+func (s SegmentInfo) LiveDocs() int {
+	return s.DocCount - s.DelCount
+}
+
+// CommitPoint lists the live segments of an index, oldest first. A commit
+// is published by atomically writing segments_N; readers open the highest
+// N, so a failed or interrupted build leaves the previous commit live.
+type CommitPoint struct {
+	Generation int           `json:"generation"`
+	Segments   []SegmentInfo `json:"segments"`
+	// RanksGen is the generation of the ranks file, 0 if the ranks were
+	// never computed.
+	RanksGen    int    `json:"ranks_gen,omitempty"`
+	RanksCRC32C uint32 `json:"ranks_crc32c,omitempty"`
+	// CRC32C is the checksum of the commit point, computed over its JSON
+	// form with CRC32C set to 0.
+	CRC32C uint32 `json:"crc32c"`
+}
+
+func commitName(gen int) string {
+	return commitPrefix + strconv.Itoa(gen)
+}
+
+func parseNumbered(name, prefix string) (int, bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// SegmentPath returns the directory holding the files of segment name.
+func SegmentPath(indexPath, name string) string {
+	return filepath.Join(indexPath, name)
+}
+
+// DocCount returns the number of live documents across all segments,
+// counting a document once per segment that holds a version of it.
+func (c *CommitPoint) DocCount() int {
+	total := 0
+	for _, seg := range c.Segments {
+		total += seg.LiveDocs()
+	}
+	return total
+}
+
+// ReadCommit loads the most recent commit point of the index at indexPath.
+func ReadCommit(indexPath string) (*CommitPoint, error) {
+	entries, err := os.ReadDir(indexPath)
+	if err != nil {
+		return nil, NewIndexNotFoundError(indexPath)
+	}
+
+	latest := -1
+	for _, entry := range entries {
+		if gen, ok := parseNumbered(entry.Name(), commitPrefix); ok && gen > latest {
+			latest = gen
+		}
+	}
+	if latest < 0 {
+		return nil, NewIndexNotFoundError(indexPath)
+	}
+	return readCommitGen(indexPath, latest)
+}
+
+// readCommitGen loads the commit point of generation gen.
+func readCommitGen(indexPath string, gen int) (*CommitPoint, error) {
+	data, err := os.ReadFile(filepath.Join(indexPath, commitName(gen)))
+	if err != nil {
+		return nil, NewIOError("read commit point", err)
+	}
+
+	var commit CommitPoint
+	if err := json.Unmarshal(data, &commit); err != nil {
+		return nil, NewIncompatibleIndexError(indexPath, "malformed "+commitName(gen), err)
+	}
+	crc := commit.CRC32C
+	if _, err := encodeCommit(&commit); err != nil || commit.CRC32C != crc {
+		return nil, NewCorruptIndexError(filepath.Join(indexPath, commitName(gen)), "checksum mismatch")
+	}
+	for _, seg := range commit.Segments {
+		if _, ok := parseNumbered(seg.Name, segmentPrefix); !ok {
+			return nil, NewIncompatibleIndexError(indexPath, fmt.Sprintf("malformed segment name %q", seg.Name), nil)
+		}
+	}
+	return &commit, nil
+}
+
+// readCommitOrEmpty is ReadCommit for writers, which may start from an
+// empty directory.
+func readCommitOrEmpty(indexPath string) (*CommitPoint, error) {
+	commit, err := ReadCommit(indexPath)
+	if err == nil {
+		return commit, nil
+	}
+	var wikiErr *WikiError
+	if errors.As(err, &wikiErr) && wikiErr.Type == ErrIndexNotFound {
+		return &CommitPoint{}, nil
+	}
+	return nil, err
+}
+
+// nextSegmentName picks a segment name not used by any directory already
+// present, including segments orphaned by a crash before their commit.
+func nextSegmentName(indexPath string) (string, error) {
+	entries, err := os.ReadDir(indexPath)
+	if err != nil {
+		return "", err
+	}
+	next := 0
+	for _, entry := range entries {
+		if n, ok := parseNumbered(entry.Name(), segmentPrefix); ok && n >= next {
+			next = n + 1
+		}
+	}
+	return segmentPrefix + strconv.Itoa(next), nil
+}
+
+// publishSegment moves a completed temp directory into place under a fresh
+// segment name.
+func publishSegment(indexPath, tempDir string) (string, error) {
+	if err := syncDir(tempDir); err != nil {
+		return "", NewIOError("sync segment directory", err)
+	}
+
+	name, err := nextSegmentName(indexPath)
+	if err != nil {
+		return "", NewIOError("list index directory", err)
+	}
+	if err := os.Rename(tempDir, SegmentPath(indexPath, name)); err != nil {
+		return "", NewIOError("publish segment", err)
+	}
+	if err := syncDir(indexPath); err != nil {
+		return "", NewIOError("sync index directory", err)
+	}
+	return name, nil
+}
+
+// writeCommit publishes commit as the next generation and then removes
+// the commit points, segments, tombstone and ranks files referenced
+// neither by it nor by the commit it replaces. Like Lucene's default
+// deletion policy, the previous commit is kept until the next one, so
+// that a searcher that read it but has not opened its segments yet still
+// finds them.
+func writeCommit(indexPath string, commit *CommitPoint) error {
+	entries, err := os.ReadDir(indexPath)
+	if err != nil {
+		return NewIOError("list index directory", err)
+	}
+	previousGen := -1
+	for _, entry := range entries {
+		if gen, ok := parseNumbered(entry.Name(), commitPrefix); ok {
+			previousGen = max(previousGen, gen)
+			if gen >= commit.Generation {
+				commit.Generation = gen + 1
+			}
+		}
+	}
+	if commit.Generation == 0 {
+		commit.Generation = 1
+	}
+
+	data, err := encodeCommit(commit)
+	if err != nil {
+		return err
+	}
+	name := commitName(commit.Generation)
+	if err := writeFileAtomic(indexPath, name, data); err != nil {
+		return NewIOError("write commit point", err)
+	}
+
+	live := map[string]bool{name: true}
+	keep := func(c *CommitPoint) {
+		if c.RanksGen > 0 {
+			live[ranksFileName(c.RanksGen)] = true
+		}
+		for _, seg := range c.Segments {
+			live[seg.Name] = true
+			if seg.DelGen > 0 {
+				live[deletesFileName(seg.Name, seg.DelGen)] = true
+			}
+		}
+	}
+	keep(commit)
+	// A previous commit that cannot be read is of no use to searchers.
+	if previousGen >= 0 {
+		if previous, err := readCommitGen(indexPath, previousGen); err == nil {
+			live[commitName(previousGen)] = true
+			keep(previous)
+		}
+	}
+	for _, entry := range entries {
+		_, isCommit := parseNumbered(entry.Name(), commitPrefix)
+		_, isSegment := parseNumbered(entry.Name(), segmentPrefix)
+		isData := isDeletesFile(entry.Name()) || isRanksFile(entry.Name())
+		if (isCommit || isSegment || isData) && !live[entry.Name()] {
+			if err := os.RemoveAll(filepath.Join(indexPath, entry.Name())); err != nil {
+				return NewIOError("remove obsolete index files", err)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeCommit returns the contents of the file of commit, setting its
+// checksum.
+func encodeCommit(commit *CommitPoint) ([]byte, error) {
+	commit.CRC32C = 0
+	data, err := json.MarshalIndent(commit, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	commit.CRC32C = crc32.Checksum(data, castagnoli)
+	if data, err = json.MarshalIndent(commit, "", "  "); err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// removeStaleTemps deletes temp directories left behind by builds that
+// crashed before publishing.
+func removeStaleTemps(indexPath string) error {
+	entries, err := os.ReadDir(indexPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), tempPrefix) {
+			if err := os.RemoveAll(filepath.Join(indexPath, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeFileAtomic replaces dir/name with data so that readers observe
+// either the old or the new contents, never a partial write.
+func writeFileAtomic(dir, name string, data []byte) error {
+	tmp := filepath.Join(dir, tempPrefix+name)
+	if err := writeFileSync(tmp, data); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return syncDir(dir)
+}
+
+func writeFileSync(path string, data []byte) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dir.Close() }()
+	return dir.Sync()
+}
@@ -0,0 +1,219 @@
+package indexer
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Ranks are computed over the link graph of the whole index and kept in a
+// "ranks_<gen>.dat" file next to the segments, named by the commit. The
+// file has a line per live article:
+//
+//	docID \t inlinks \t pagerank
+//
+// Ranks are keyed by document ID, so they stay valid when segments are
+// merged, and documents added since they were computed have none.
+const (
+	ranksPrefix = "ranks_"
+	ranksSuffix = ".dat"
+)
+
+const (
+	// PageRankDamping is the probability that a random surfer follows a
+	// link rather than jumping to a random page.
+	PageRankDamping = 0.85
+
+	pageRankIterations = 100
+	pageRankTolerance  = 1e-9
+
+	// maxRedirectHops bounds the chain of redirects a link is followed
+	// through, so that redirect loops end.
+	maxRedirectHops = 5
+)
+
+// DocRank is the standing of an article in the link graph: the number of
+// other articles linking to it and its PageRank, scaled so that the
+// average article has a PageRank of 1.
+type DocRank struct {
+	Inlinks  int
+	PageRank float64
+}
+
+func ranksFileName(gen int) string {
+	return ranksPrefix + strconv.Itoa(gen) + ranksSuffix
+}
+
+// isRanksFile reports whether name is a ranks file.
+func isRanksFile(name string) bool {
+	_, ok := parseNumbered(strings.TrimSuffix(name, ranksSuffix), ranksPrefix)
+	return ok && strings.HasSuffix(name, ranksSuffix)
+}
+
+// ComputeRanks builds the link graph of the live articles of r and ranks
+// them. Links are resolved to articles through their titles and redirects,
+// ignoring case; links to missing pages and from a page to itself are
+// dropped, and several links from one page to another count once.
+func ComputeRanks(r *IndexReader) (map[string]DocRank, error) {
+	type node struct {
+		docID   string
+		segment int
+		ord     uint32
+	}
+	var nodes []node
+	titles := newTitleResolver()
+	for i, segment := range r.Segments {
+		for ord := range segment.DocCount() {
+			if !r.IsDead(i, uint32(ord)) {
+				titles.add(segment.DocID(uint32(ord)), segment.Doc(uint32(ord)))
+			}
+		}
+	}
+	// The articles titles resolve to are the nodes of the graph.
+	index := make(map[string]int, len(titles.articles))
+	for i, segment := range r.Segments {
+		for ord := range segment.DocCount() {
+			docID := segment.DocID(uint32(ord))
+			if _, ok := index[docID]; !ok && !r.IsDead(i, uint32(ord)) && titles.articles[TitleKey(segment.Doc(uint32(ord)).Title)] == docID {
+				index[docID] = len(nodes)
+				nodes = append(nodes, node{docID: docID, segment: i, ord: uint32(ord)})
+			}
+		}
+	}
+
+	out := make([][]int, len(nodes))
+	inlinks := make([]int, len(nodes))
+	for n, nd := range nodes {
+		targets, err := r.Segments[nd.segment].Links(nd.ord)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[int]bool, len(targets))
+		for _, target := range targets {
+			docID, ok := titles.resolve(target)
+			if m := index[docID]; ok && m != n && !seen[m] {
+				seen[m] = true
+				out[n] = append(out[n], m)
+				inlinks[m]++
+			}
+		}
+	}
+
+	pageRank := computePageRank(out)
+	ranks := make(map[string]DocRank, len(nodes))
+	for n, nd := range nodes {
+		ranks[nd.docID] = DocRank{Inlinks: inlinks[n], PageRank: pageRank[n] * float64(len(nodes))}
+	}
+	return ranks, nil
+}
+
+// computePageRank runs the power iteration over the graph with the given
+// out-links until it converges. The rank of pages without links is spread
+// over all pages, so the ranks always sum to 1.
+func computePageRank(out [][]int) []float64 {
+	n := len(out)
+	if n == 0 {
+		return nil
+	}
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+	for range pageRankIterations {
+		dangling := 0.0
+		for i, links := range out {
+			if len(links) == 0 {
+				dangling += rank[i]
+			}
+		}
+		base := (1-PageRankDamping)/float64(n) + PageRankDamping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for i, links := range out {
+			share := PageRankDamping * rank[i] / float64(len(links))
+			for _, j := range links {
+				next[j] += share
+			}
+		}
+
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < pageRankTolerance {
+			break
+		}
+	}
+	return rank
+}
+
+// UpdateRanks recomputes the ranks of the articles of the index from its
+// link graph and publishes them with a new commit.
+func (w *IndexWriter) UpdateRanks() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	r, err := OpenIndexReader(w.indexPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	ranks, err := ComputeRanks(r)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, docID := range sortedKeys(ranks) {
+		rank := ranks[docID]
+		fmt.Fprintf(&buf, "%s\t%d\t%s\n", docID, rank.Inlinks, strconv.FormatFloat(rank.PageRank, 'g', 8, 64))
+	}
+	commit := r.Commit
+	gen := commit.RanksGen + 1
+	if err := writeFileAtomic(w.indexPath, ranksFileName(gen), buf.Bytes()); err != nil {
+		return NewIOError("write ranks", err)
+	}
+	commit.RanksGen = gen
+	commit.RanksCRC32C = crc32.Checksum(buf.Bytes(), castagnoli)
+	return writeCommit(w.indexPath, commit)
+}
+
+// ReadRanks returns the ranks published with commit, which are empty if
+// they were never computed.
+func ReadRanks(indexPath string, commit *CommitPoint) (map[string]DocRank, error) {
+	ranks := make(map[string]DocRank)
+	if commit.RanksGen == 0 {
+		return ranks, nil
+	}
+
+	path := filepath.Join(indexPath, ranksFileName(commit.RanksGen))
+	data, err := readChecked(path, commit.RanksCRC32C)
+	if err != nil {
+		return nil, err
+	}
+
+	for line := range strings.Lines(string(data)) {
+		parts := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+		if len(parts) != 3 {
+			return nil, NewCorruptIndexError(path, "malformed line")
+		}
+		inlinks, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, NewCorruptIndexError(path, "malformed inlink count")
+		}
+		pageRank, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, NewCorruptIndexError(path, "malformed PageRank")
+		}
+		ranks[parts[0]] = DocRank{Inlinks: inlinks, PageRank: pageRank}
+	}
+	return ranks, nil
+}
@@ -0,0 +1,132 @@
+package indexer
+
+import (
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkTargets(t *testing.T) {
+	// Links in file captions count; the file itself does not.
+	assert.Equal(t, []string{"eiffel tower", "france", "paris", "seine"}, LinkTargets(
+		"[[Paris]] on the [[Seine|river]], capital of [[France#History|France]], see [[paris]] "+
+			"[[Category:Cities]] [[File:Paris.jpg|thumb|The [[Eiffel Tower]]]] [[:Category:Rivers]]"))
+	assert.Equal(t, []string{"eiffel tower", "star wars: a new hope"},
+		LinkTargets("[[Star Wars: A New Hope]] [[Eiffel_Tower]] [[#Section]] [[ ]]"))
+	assert.Empty(t, LinkTargets("no links"))
+}
+
+func TestLinkAnchors(t *testing.T) {
+	assert.Equal(t, []Anchor{
+		{Target: "paris", Text: "Paris"},
+		{Target: "seine", Text: "the river"},
+		{Target: "france", Text: "French"},
+	}, LinkAnchors("[[Paris]] on [[Seine| the river ]] [[Category:Cities]] [[France#History|French]] [[#Section|here]]"))
+	assert.Equal(t, []Anchor{{Target: "paris", Text: "city of light"}}, LinkAnchors("[[Paris|city\n of\tlight]]"))
+	assert.Empty(t, LinkAnchors("no links"))
+}
+
+func TestComputePageRank(t *testing.T) {
+	// 0 and 1 link to each other, 2 links to 0 and 3 links nowhere.
+	rank := computePageRank([][]int{{1}, {0}, {0}, nil})
+	sum := 0.0
+	for _, r := range rank {
+		sum += r
+	}
+	assert.InDelta(t, 1, sum, 1e-9)
+	assert.Greater(t, rank[0], rank[1])
+	assert.Greater(t, rank[1], rank[2])
+	assert.InDelta(t, rank[2], rank[3], 1e-12)
+	assert.Nil(t, computePageRank(nil))
+}
+
+func TestIndexWriter_UpdateRanks(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	addPage := func(idx *InvertedIndex, docID, title string, links ...string) {
+		idx.AddDocument(docID, title)
+		idx.Add("page", docID, NewPosting(BODY, 1))
+		idx.SetLinks(docID, links)
+	}
+
+	first := NewInvertedIndex()
+	addPage(first, "1", "Paris", "france", "paris")
+	addPage(first, "2", "France", "paris")
+	addPage(first, "3", "Paris Hilton", "paris", "new york")
+	addPage(first, "4", "City of Light")
+	first.SetRedirect("4", "Paris")
+	writer := NewIndexWriter(indexPath)
+	require.NoError(t, writer.WriteIndex(first))
+
+	// Links through a redirect count for the article it leads to, and a
+	// second segment takes part in the graph.
+	second := NewInvertedIndex()
+	addPage(second, "5", "Eiffel Tower", "city of light", "paris hilton")
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+	require.NoError(t, writer.UpdateRanks())
+
+	commit, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, 1, commit.RanksGen)
+	ranks, err := ReadRanks(indexPath, commit)
+	require.NoError(t, err)
+	require.Len(t, ranks, 4, "redirects are not ranked")
+	assert.Equal(t, 3, ranks["1"].Inlinks)
+	assert.Equal(t, 1, ranks["2"].Inlinks)
+	assert.Equal(t, 1, ranks["3"].Inlinks)
+	assert.Equal(t, 0, ranks["5"].Inlinks)
+	assert.Greater(t, ranks["1"].PageRank, ranks["2"].PageRank)
+	assert.Greater(t, ranks["2"].PageRank, ranks["3"].PageRank)
+	total := 0.0
+	for _, rank := range ranks {
+		total += rank.PageRank
+	}
+	assert.InDelta(t, 4, total, 1e-6)
+
+	// The links survive merges, and the old ranks file is removed once no
+	// commit kept refers to it.
+	require.NoError(t, writer.Compact())
+	require.NoError(t, writer.UpdateRanks())
+	assert.FileExists(t, filepath.Join(indexPath, ranksFileName(1)), "kept for the previous commit")
+	require.NoError(t, writer.UpdateRanks())
+	commit, err = ReadCommit(indexPath)
+	require.NoError(t, err)
+	merged, err := ReadRanks(indexPath, commit)
+	require.NoError(t, err)
+	assert.Equal(t, ranks, merged)
+	assert.NoFileExists(t, filepath.Join(indexPath, ranksFileName(1)))
+	assert.FileExists(t, filepath.Join(indexPath, ranksFileName(3)))
+
+	r, err := OpenIndexReader(indexPath)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, r.Close()) }()
+	assert.Equal(t, merged, r.Ranks)
+	links, err := r.Segments[0].Links(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"france", "paris"}, links)
+}
+
+func TestReadRanks_Corrupt(t *testing.T) {
+	indexPath := t.TempDir()
+	data := []byte("1\tmany\t1\n")
+	require.NoError(t, os.WriteFile(filepath.Join(indexPath, ranksFileName(1)), data, 0644))
+
+	_, err := ReadRanks(indexPath, &CommitPoint{RanksGen: 1, RanksCRC32C: crc32.Checksum(data, castagnoli)})
+	var wikiErr *WikiError
+	require.ErrorAs(t, err, &wikiErr)
+	assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+	assert.Contains(t, wikiErr.Error(), "malformed inlink count")
+
+	_, err = ReadRanks(indexPath, &CommitPoint{RanksGen: 1, RanksCRC32C: 1})
+	require.ErrorAs(t, err, &wikiErr)
+	assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+	assert.Contains(t, wikiErr.Error(), "checksum")
+
+	ranks, err := ReadRanks(indexPath, &CommitPoint{})
+	require.NoError(t, err)
+	assert.Empty(t, ranks)
+}
@@ -0,0 +1,378 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DocsFile lists the documents of a segment, sorted by ID, with their
+// lengths in tokens, their titles and, for redirect pages, the titles they
+// lead to. A document's position in this file is its ordinal within the
+// segment.
+const DocsFile = "docs.idx"
+
+var (
+	errCorruptPostings  = NewCorruptIndexError(PostingsFile, "malformed posting list")
+	errPostingsChecksum = NewCorruptIndexError(PostingsFile, "posting block checksum mismatch")
+)
+
+// SegmentReader reads the files of one segment. The term dictionary and
+// posting lists are memory-mapped where possible, so lookups do not go
+// through the file system once the pages are warm. A SegmentReader is safe
+// for concurrent use until it is closed.
+type SegmentReader struct {
+	dir        string
+	terms      *table
+	kgrams     *table
+	titles     *table
+	suggest    *table
+	abstracts  *table
+	text       *table
+	links      *table
+	anchors    *table
+	categories *table
+	members    *table
+	facets     *table
+	postings   blob
+
+	docIDs   []string
+	docs     []DocInfo
+	ordinals map[string]uint32
+}
+
+// OpenSegmentReader opens the segment stored in dir.
+func OpenSegmentReader(dir string) (*SegmentReader, error) {
+	docIDs, docs, err := readDocTable(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &SegmentReader{
+		dir:      dir,
+		docIDs:   docIDs,
+		docs:     docs,
+		ordinals: make(map[string]uint32, len(docIDs)),
+	}
+	for ord, docID := range docIDs {
+		r.ordinals[docID] = uint32(ord)
+	}
+
+	if r.terms, err = openTable(filepath.Join(dir, TermsFile), termsMagic); err != nil {
+		return nil, err
+	}
+	if r.kgrams, err = openTable(filepath.Join(dir, KGramsFile), kgramsMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.titles, err = openTable(filepath.Join(dir, TitlesFile), titlesMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.suggest, err = openTable(filepath.Join(dir, SuggestFile), suggestMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.abstracts, err = openTable(filepath.Join(dir, AbstractsFile), abstractsMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.text, err = openTable(filepath.Join(dir, TextFile), textMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.links, err = openTable(filepath.Join(dir, LinksFile), linksMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.anchors, err = openTable(filepath.Join(dir, AnchorsFile), anchorsMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.categories, err = openTable(filepath.Join(dir, CategoriesFile), categoriesMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.members, err = openTable(filepath.Join(dir, MembersFile), membersMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.facets, err = openTable(filepath.Join(dir, FacetsFile), facetsMagic); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	if r.postings, err = openBlob(filepath.Join(dir, PostingsFile)); err != nil {
+		_ = r.Close()
+		return nil, NewIOError("open posting lists", err)
+	}
+	if err := r.readHeader(); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *SegmentReader) readHeader() error {
+	postingsPath := filepath.Join(r.dir, PostingsFile)
+	if r.postings.Size() < int64(len(postingsMagic)) {
+		return NewCorruptIndexError(postingsPath, "file too short")
+	}
+	head, err := r.postings.Bytes(0, int64(len(postingsMagic)))
+	if err != nil {
+		return NewIOError("read posting lists", err)
+	}
+	if !bytes.Equal(head, postingsMagic) {
+		return NewCorruptIndexError(postingsPath, "bad magic")
+	}
+	return nil
+}
+
+// Close releases the mappings and file handles of the segment. Postings
+// returned by the reader must not be used afterwards.
+func (r *SegmentReader) Close() error {
+	var err error
+	for _, t := range []*table{r.terms, r.kgrams, r.titles, r.suggest, r.abstracts, r.text, r.links, r.anchors, r.categories, r.members, r.facets} {
+		if t != nil {
+			if closeErr := t.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if r.postings != nil {
+		if closeErr := r.postings.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// NumTerms returns the number of distinct terms in the segment.
+func (r *SegmentReader) NumTerms() int {
+	return r.terms.numEntries
+}
+
+// termBytes splits the term off a raw dictionary entry.
+func termBytes(entry []byte) ([]byte, []byte, bool) {
+	n, k := binary.Uvarint(entry)
+	if k <= 0 || n > uint64(len(entry)-k) {
+		return nil, nil, false
+	}
+	return entry[k : k+int(n)], entry[k+int(n):], true
+}
+
+// TermAt returns the i-th term of the dictionary in sorted order.
+func (r *SegmentReader) TermAt(i int) (TermInfo, error) {
+	if i < 0 || i >= r.terms.numEntries {
+		return TermInfo{}, NewInvalidTermError(strconv.Itoa(i))
+	}
+	raw, err := r.terms.entry(i)
+	if err != nil {
+		return TermInfo{}, err
+	}
+	term, rest, ok := termBytes(raw)
+	if !ok {
+		return TermInfo{}, NewCorruptIndexError(filepath.Join(r.dir, TermsFile), "malformed entry")
+	}
+
+	var values [5]uint64
+	for j := range values {
+		v, k := binary.Uvarint(rest)
+		if k <= 0 {
+			return TermInfo{}, NewCorruptIndexError(filepath.Join(r.dir, TermsFile), "malformed entry")
+		}
+		values[j] = v
+		rest = rest[k:]
+	}
+	info := TermInfo{
+		Term:         string(term),
+		DocFreq:      int(values[0]),
+		offset:       int64(values[1]),
+		length:       int64(values[2]),
+		MaxFrequency: int(values[3]),
+		Fields:       FieldMask(values[4]),
+	}
+	if values[4] > math.MaxUint8 {
+		return TermInfo{}, NewCorruptIndexError(filepath.Join(r.dir, TermsFile), "malformed entry")
+	}
+	_, n, ok := readFieldFreqs(rest, info.Fields, &info.MaxFieldFreqs)
+	if !ok && info.DocFreq > 0 {
+		return TermInfo{}, NewCorruptIndexError(filepath.Join(r.dir, TermsFile), "malformed entry")
+	}
+	form, _, ok := termBytes(rest[n:])
+	if !ok {
+		return TermInfo{}, NewCorruptIndexError(filepath.Join(r.dir, TermsFile), "malformed entry")
+	}
+	info.Form = info.Term
+	if len(form) > 0 {
+		info.Form = string(form)
+	}
+	return info, nil
+}
+
+// Seek returns the position of the first term not less than term, which
+// is NumTerms if there is none.
+func (r *SegmentReader) Seek(term string) (int, error) {
+	return r.terms.search([]byte(term), func(entry []byte) ([]byte, bool) {
+		t, _, ok := termBytes(entry)
+		return t, ok
+	})
+}
+
+// Lookup finds term in the dictionary.
+func (r *SegmentReader) Lookup(term string) (TermInfo, bool, error) {
+	i, err := r.Seek(term)
+	if err != nil || i == r.terms.numEntries {
+		return TermInfo{}, false, err
+	}
+	info, err := r.TermAt(i)
+	if err != nil || info.Term != term {
+		return TermInfo{}, false, err
+	}
+	return info, true, nil
+}
+
+// Postings returns an iterator over the posting list of info.
+func (r *SegmentReader) Postings(info TermInfo) (*PostingIterator, error) {
+	data, err := r.postings.Bytes(info.offset, info.length)
+	if err != nil {
+		return nil, NewCorruptIndexError(filepath.Join(r.dir, PostingsFile), "posting list out of range")
+	}
+	return newPostingIterator(data, info.DocFreq, uint32(len(r.docIDs))), nil
+}
+
+// DocCount returns the number of documents stored in the segment,
+// including deleted ones.
+func (r *SegmentReader) DocCount() int {
+	return len(r.docIDs)
+}
+
+// DocID returns the external ID of the document with ordinal ord.
+func (r *SegmentReader) DocID(ord uint32) string {
+	return r.docIDs[ord]
+}
+
+// Doc returns the stored information of the document with ordinal ord.
+func (r *SegmentReader) Doc(ord uint32) DocInfo {
+	return r.docs[ord]
+}
+
+// Ordinal returns the ordinal of the document with the given ID.
+func (r *SegmentReader) Ordinal(docID string) (uint32, bool) {
+	ord, ok := r.ordinals[docID]
+	return ord, ok
+}
+
+// readDocTable loads the document table of the segment in dir in ordinal
+// order.
+func readDocTable(dir string) ([]string, []DocInfo, error) {
+	file, err := os.Open(filepath.Join(dir, DocsFile))
+	if err != nil {
+		return nil, nil, NewIOError("open document table", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var docIDs []string
+	var docs []DocInfo
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(parts) < 3 {
+			return nil, nil, NewCorruptIndexError(filepath.Join(dir, DocsFile), "malformed line")
+		}
+		length, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, nil, NewCorruptIndexError(filepath.Join(dir, DocsFile), "malformed length")
+		}
+		docIDs = append(docIDs, parts[0])
+		info := DocInfo{Title: parts[2], Length: length}
+		if len(parts) == 4 {
+			info.Redirect = parts[3]
+		}
+		docs = append(docs, info)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, NewIOError("read document table", err)
+	}
+	return docIDs, docs, nil
+}
+
+// ReadSegmentDocs loads the document table of the segment in dir.
+func ReadSegmentDocs(dir string) (map[string]DocInfo, error) {
+	docIDs, infos, err := readDocTable(dir)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]DocInfo, len(docIDs))
+	for i, docID := range docIDs {
+		docs[docID] = infos[i]
+	}
+	return docs, nil
+}
+
+// loadSegment reads the segment in dir back into memory, skipping the
+// documents in exclude.
+func loadSegment(dir string, exclude map[string]bool) (*InvertedIndex, error) {
+	r, err := OpenSegmentReader(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	idx := NewInvertedIndex()
+	for i := 0; i < r.NumTerms(); i++ {
+		info, err := r.TermAt(i)
+		if err != nil {
+			return nil, err
+		}
+		it, err := r.Postings(info)
+		if err != nil {
+			return nil, err
+		}
+		for it.Next() {
+			if docID := r.DocID(it.Doc()); !exclude[docID] {
+				idx.Add(info.Term, docID, it.Posting())
+			}
+		}
+		idx.AddForm(info.Term, info.Form, info.DocFreq)
+		if it.Err() != nil {
+			return nil, it.Err()
+		}
+	}
+
+	for ord, docID := range r.docIDs {
+		if !exclude[docID] {
+			idx.Docs[docID] = r.docs[ord]
+			if idx.Abstracts[docID], err = r.Abstract(uint32(ord)); err != nil {
+				return nil, err
+			}
+			text, err := r.compressedText(uint32(ord))
+			if err != nil {
+				return nil, err
+			}
+			if len(text) > 0 {
+				idx.Texts[docID] = bytes.Clone(text)
+			}
+			if idx.Links[docID], err = r.Links(uint32(ord)); err != nil {
+				return nil, err
+			}
+			if idx.Anchors[docID], err = r.Anchors(uint32(ord)); err != nil {
+				return nil, err
+			}
+			if idx.Categories[docID], err = r.Categories(uint32(ord)); err != nil {
+				return nil, err
+			}
+			values, err := r.Facets(uint32(ord))
+			if err != nil {
+				return nil, err
+			}
+			idx.Facets[docID] = slices.DeleteFunc(values, func(v FacetValue) bool { return v.Facet == FacetCategory })
+		}
+	}
+	return idx, nil
+}
@@ -0,0 +1,214 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestSegment(t *testing.T, idx *InvertedIndex) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, NewIndexWriter(dir).WriteIndex(idx))
+	return SegmentPath(dir, "_0")
+}
+
+func TestSegmentReader_RoundTrip(t *testing.T) {
+	idx := NewInvertedIndex()
+	const numDocs = 3*BlockSize + 7
+	for i := 0; i < numDocs; i++ {
+		docID := fmt.Sprintf("doc%04d", i)
+		idx.AddDocument(docID, "Title "+docID)
+		idx.Add("common", docID, NewPosting(BODY, i%5+1))
+		if i%100 == 0 {
+			idx.Add("rare", docID, NewPosting(TITLE, 1))
+			idx.Add("rare", docID, NewPosting(BODY, 1))
+		}
+	}
+	idx.AddForm("rare", "rarer", 2)
+	idx.AddForm("rare", "rarest", 1)
+	dir := writeTestSegment(t, idx)
+
+	r, err := OpenSegmentReader(dir)
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	assert.Equal(t, 2, r.NumTerms())
+	assert.Equal(t, numDocs, r.DocCount())
+
+	info, found, err := r.Lookup("common")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, numDocs, info.DocFreq)
+	assert.Equal(t, "common", info.Form)
+
+	it, err := r.Postings(info)
+	require.NoError(t, err)
+	n := 0
+	for it.Next() {
+		docID := fmt.Sprintf("doc%04d", n)
+		assert.Equal(t, docID, r.DocID(it.Doc()))
+		assert.Equal(t, NewPosting(BODY, n%5+1), it.Posting())
+		assert.Equal(t, "Title "+docID, r.Doc(it.Doc()).Title)
+		n++
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, numDocs, n)
+
+	info, found, err = r.Lookup("rare")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, TITLE|BODY, info.Fields)
+	assert.Equal(t, "rarer", info.Form)
+	assert.Equal(t, 1, int(info.MaxFieldFreqs[fieldIndex(TITLE)]))
+	it, err = r.Postings(info)
+	require.NoError(t, err)
+	require.True(t, it.Next())
+	assert.Equal(t, 1, it.Posting().FieldFrequency(TITLE))
+	assert.Equal(t, 1, it.Posting().FieldFrequency(BODY))
+	assert.Equal(t, 2, it.Posting().Frequency)
+
+	for _, term := range []string{"", "a", "commo", "commons", "zzz"} {
+		_, found, err := r.Lookup(term)
+		require.NoError(t, err)
+		assert.False(t, found, term)
+	}
+}
+
+func TestReaderAtBlob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blob")
+	require.NoError(t, os.WriteFile(path, []byte("hello world"), 0644))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	b := &readerAtBlob{file: file, size: 11}
+	defer func() { _ = b.Close() }()
+
+	data, err := b.Bytes(6, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(data))
+
+	_, err = b.Bytes(6, 6)
+	assert.Error(t, err)
+}
+
+func TestOpenSegmentReader_Corrupt(t *testing.T) {
+	idx := NewInvertedIndex()
+	idx.Add("apple", "doc1", NewPosting(BODY, 1))
+	idx.AddDocument("doc1", "Apple")
+
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, dir string)
+	}{
+		{
+			name: "bad terms magic",
+			corrupt: func(t *testing.T, dir string) {
+				path := filepath.Join(dir, TermsFile)
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				copy(data, "XXXX")
+				require.NoError(t, os.WriteFile(path, data, 0644))
+			},
+		},
+		{
+			name: "truncated terms",
+			corrupt: func(t *testing.T, dir string) {
+				require.NoError(t, os.Truncate(filepath.Join(dir, TermsFile), 6))
+			},
+		},
+		{
+			name: "bad postings magic",
+			corrupt: func(t *testing.T, dir string) {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, PostingsFile), []byte("nope"), 0644))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestSegment(t, idx)
+			tt.corrupt(t, dir)
+
+			_, err := OpenSegmentReader(dir)
+			var wikiErr *WikiError
+			require.True(t, errors.As(err, &wikiErr), "got %v", err)
+			assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+		})
+	}
+}
+
+func TestPostingIterator_Corrupt(t *testing.T) {
+	data := encodePostings(nil, []uint32{0, 3}, []Posting{NewPosting(BODY, 1), NewPosting(BODY, 1)})
+
+	// The block ends outside a two document segment.
+	it := newPostingIterator(data, 2, 2)
+	assert.False(t, it.Next())
+	assert.Error(t, it.Err())
+
+	// More bytes than the document frequency accounts for.
+	it = newPostingIterator(data, 1, 4)
+	assert.False(t, it.Next())
+	assert.Error(t, it.Err())
+
+	// A flipped bit in the block fails its checksum.
+	data[len(data)-1] ^= 1
+	it = newPostingIterator(data, 2, 4)
+	assert.False(t, it.Next())
+	assert.Equal(t, errPostingsChecksum, it.Err())
+}
+
+func TestPostingIterator_Advance(t *testing.T) {
+	// Every third ordinal, spread over several blocks.
+	const docFreq = 3*BlockSize + 10
+	var ords []uint32
+	var postings []Posting
+	for i := 0; i < docFreq; i++ {
+		ords = append(ords, uint32(3*i))
+		postings = append(postings, NewPosting(BODY, i+1))
+	}
+	data := encodePostings(nil, ords, postings)
+	maxDoc := uint32(3 * docFreq)
+
+	tests := []struct {
+		name    string
+		targets []uint32
+		want    []uint32
+	}{
+		{"first", []uint32{0}, []uint32{0}},
+		{"exact", []uint32{9}, []uint32{9}},
+		{"between", []uint32{10}, []uint32{12}},
+		{"skip blocks", []uint32{3*BlockSize*2 + 1}, []uint32{3*BlockSize*2 + 3}},
+		{"last block", []uint32{3 * (docFreq - 1)}, []uint32{3 * (docFreq - 1)}},
+		{"never backwards", []uint32{600, 30, 600}, []uint32{600, 600, 600}},
+		{"ascending", []uint32{5, 200, 385, 386, 1000}, []uint32{6, 201, 387, 387, 1002}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := newPostingIterator(data, docFreq, maxDoc)
+			for i, target := range tt.targets {
+				require.True(t, it.Advance(target))
+				assert.Equal(t, tt.want[i], it.Doc())
+				assert.Equal(t, int(it.Doc()/3+1), it.Posting().Frequency)
+			}
+
+			// The iterator carries on with the postings after the last one.
+			last := it.Doc()
+			if it.Next() {
+				assert.Equal(t, last+3, it.Doc())
+			}
+			require.NoError(t, it.Err())
+		})
+	}
+
+	it := newPostingIterator(data, docFreq, maxDoc)
+	assert.False(t, it.Advance(maxDoc))
+	assert.False(t, it.Advance(0))
+	assert.NoError(t, it.Err())
+}
@@ -0,0 +1,317 @@
+package indexer
+
+import (
+	"container/heap"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// IndexSummary describes what an index holds, for checking a build before
+// it is put to use. Term and posting counts include the postings of
+// deleted or superseded documents until merges drop them, like the
+// document frequencies in the dictionaries.
+type IndexSummary struct {
+	Generation int `json:"generation"`
+	// Documents counts the live documents, each once, and Deleted the
+	// stored versions that are deleted or superseded by a newer one.
+	Documents    int     `json:"documents"`
+	Deleted      int     `json:"deleted"`
+	AvgDocLength float64 `json:"avg_doc_length"`
+
+	UniqueTerms   int `json:"unique_terms"`
+	TotalPostings int `json:"total_postings"`
+	// TopTerms are the terms found in most documents, most common first.
+	TopTerms []TermCount `json:"top_terms"`
+	// PostingLengths counts the terms by the length of their posting list
+	// across segments, in buckets of powers of two.
+	PostingLengths []LengthBucket `json:"posting_lengths"`
+	// Fields tells how the postings spread over the fields, in bit order.
+	Fields []FieldSummary `json:"fields"`
+
+	Segments  []SegmentSummary `json:"segments"`
+	Files     []FileSize       `json:"files"`
+	TotalSize int64            `json:"total_size"`
+}
+
+// TermCount is a term and the number of documents containing it.
+type TermCount struct {
+	Term    string `json:"term"`
+	DocFreq int    `json:"doc_freq"`
+}
+
+// LengthBucket counts the terms whose posting lists hold Min to Max
+// postings.
+type LengthBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Terms int `json:"terms"`
+}
+
+// FieldSummary counts the terms occurring in a field, the postings with
+// occurrences in it and the occurrences themselves.
+type FieldSummary struct {
+	Name        string    `json:"name"`
+	Mask        FieldMask `json:"mask"`
+	Terms       int       `json:"terms"`
+	Postings    int       `json:"postings"`
+	Occurrences int       `json:"occurrences"`
+}
+
+// SegmentSummary describes one segment and how it was built. Documents
+// counts every stored document, including the Deleted ones that are
+// deleted or superseded, and Size is the total size of its files.
+type SegmentSummary struct {
+	Name      string    `json:"name"`
+	Documents int       `json:"documents"`
+	Deleted   int       `json:"deleted"`
+	Terms     int       `json:"terms"`
+	Size      int64     `json:"size"`
+	Manifest  *Manifest `json:"manifest"`
+}
+
+// SummarizeSegment describes the segment r named name, built as manifest
+// says, whose documents isDead reports deleted or superseded.
+func SummarizeSegment(name string, r *SegmentReader, manifest *Manifest, isDead func(ord uint32) bool) SegmentSummary {
+	seg := SegmentSummary{
+		Name:      name,
+		Documents: r.DocCount(),
+		Terms:     r.NumTerms(),
+		Manifest:  manifest,
+	}
+	for ord := range uint32(r.DocCount()) {
+		if isDead(ord) {
+			seg.Deleted++
+		}
+	}
+	for _, file := range manifest.Files {
+		seg.Size += file.Size
+	}
+	return seg
+}
+
+// FileSize is the size of a file of the index, by path relative to it.
+type FileSize struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Summarize reads every dictionary and posting list of the index at
+// indexPath and returns its summary, with the topN most common terms.
+func Summarize(indexPath string, topN int) (*IndexSummary, error) {
+	r, err := OpenIndexReader(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	summary := &IndexSummary{Generation: r.Commit.Generation}
+	totalLength := 0
+	for i, segment := range r.Segments {
+		isDead := func(ord uint32) bool { return r.IsDead(i, ord) }
+		seg := SummarizeSegment(r.Commit.Segments[i].Name, segment, r.Manifests[i], isDead)
+		for ord := range uint32(segment.DocCount()) {
+			if !isDead(ord) {
+				summary.Documents++
+				totalLength += segment.Doc(ord).Length
+			}
+		}
+		summary.Deleted += seg.Deleted
+		summary.Segments = append(summary.Segments, seg)
+	}
+	if summary.Documents > 0 {
+		summary.AvgDocLength = float64(totalLength) / float64(summary.Documents)
+	}
+
+	if err := summary.countTerms(r.Segments, topN); err != nil {
+		return nil, err
+	}
+	if err := summary.sizeFiles(indexPath); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// countTerms walks the sorted dictionaries of the segments side by side,
+// so that a term found in several segments counts once, and decodes every
+// posting list to count the postings of each field.
+func (s *IndexSummary) countTerms(readers []*SegmentReader, topN int) error {
+	for i := range NumFields {
+		mask := FieldMask(1 << i)
+		if names := mask.Names(); len(names) > 0 {
+			s.Fields = append(s.Fields, FieldSummary{Name: names[0], Mask: mask})
+		}
+	}
+	fieldAt := make(map[FieldMask]*FieldSummary, len(s.Fields))
+	for i := range s.Fields {
+		fieldAt[s.Fields[i].Mask] = &s.Fields[i]
+	}
+
+	// current holds the next term of each segment, with an empty Term
+	// once the segment has no more.
+	next := make([]int, len(readers))
+	current := make([]TermInfo, len(readers))
+	advance := func(i int) error {
+		if next[i] == readers[i].NumTerms() {
+			current[i] = TermInfo{}
+			return nil
+		}
+		var err error
+		current[i], err = readers[i].TermAt(next[i])
+		next[i]++
+		return err
+	}
+	for i := range readers {
+		if err := advance(i); err != nil {
+			return err
+		}
+	}
+
+	top := &termHeap{}
+	for {
+		term, found := "", false
+		for _, info := range current {
+			if info.Term != "" && (!found || info.Term < term) {
+				term, found = info.Term, true
+			}
+		}
+		if !found {
+			break
+		}
+
+		docFreq := 0
+		var fields FieldMask
+		for i, info := range current {
+			if info.Term != term {
+				continue
+			}
+			docFreq += info.DocFreq
+			fields |= info.Fields
+			if err := countPostings(readers[i], info, fieldAt); err != nil {
+				return err
+			}
+			if err := advance(i); err != nil {
+				return err
+			}
+		}
+
+		s.UniqueTerms++
+		s.TotalPostings += docFreq
+		for mask, field := range fieldAt {
+			if fields&mask != 0 {
+				field.Terms++
+			}
+		}
+		s.countLength(docFreq)
+		if topN > 0 {
+			heap.Push(top, TermCount{Term: term, DocFreq: docFreq})
+			if top.Len() > topN {
+				heap.Pop(top)
+			}
+		}
+	}
+
+	s.TopTerms = make([]TermCount, top.Len())
+	for i := len(s.TopTerms) - 1; i >= 0; i-- {
+		s.TopTerms[i] = heap.Pop(top).(TermCount)
+	}
+	return nil
+}
+
+func countPostings(r *SegmentReader, info TermInfo, fieldAt map[FieldMask]*FieldSummary) error {
+	it, err := r.Postings(info)
+	if err != nil {
+		return err
+	}
+	for it.Next() {
+		posting := it.Posting()
+		for i := range NumFields {
+			if field := fieldAt[FieldMask(1<<i)]; field != nil && posting.Fields&field.Mask != 0 {
+				field.Postings++
+				field.Occurrences += int(posting.FieldFreqs[i])
+			}
+		}
+	}
+	return it.Err()
+}
+
+// countLength adds a posting list of n postings to its bucket: 1, 2-3,
+// 4-7 and so on.
+func (s *IndexSummary) countLength(n int) {
+	if n <= 0 {
+		return
+	}
+	b := bits.Len(uint(n)) - 1
+	for len(s.PostingLengths) <= b {
+		lo := 1 << len(s.PostingLengths)
+		s.PostingLengths = append(s.PostingLengths, LengthBucket{Min: lo, Max: 2*lo - 1})
+	}
+	s.PostingLengths[b].Terms++
+}
+
+// sizeFiles lists the files of the index with their sizes, and sizes each
+// segment by the files of its directory, manifest and tombstones included.
+func (s *IndexSummary) sizeFiles(indexPath string) error {
+	segments := make(map[string]*SegmentSummary, len(s.Segments))
+	for i := range s.Segments {
+		s.Segments[i].Size = 0
+		segments[s.Segments[i].Name] = &s.Segments[i]
+	}
+	err := filepath.WalkDir(indexPath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(indexPath, path)
+		if err != nil {
+			return err
+		}
+		s.Files = append(s.Files, FileSize{Path: filepath.ToSlash(rel), Size: info.Size()})
+		s.TotalSize += info.Size()
+		if seg := segments[filepath.Dir(rel)]; seg != nil {
+			seg.Size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return NewIOError("list index files", err)
+	}
+	sort.Slice(s.Files, func(i, j int) bool { return s.Files[i].Path < s.Files[j].Path })
+	return nil
+}
+
+// BuildTime returns when the newest segment was built.
+func (s *IndexSummary) BuildTime() time.Time {
+	var latest time.Time
+	for _, seg := range s.Segments {
+		if seg.Manifest.BuildTime.After(latest) {
+			latest = seg.Manifest.BuildTime
+		}
+	}
+	return latest
+}
+
+// termHeap keeps the most common terms seen, least common first. Ties go
+// to the term that sorts first.
+type termHeap []TermCount
+
+func (h termHeap) Len() int { return len(h) }
+func (h termHeap) Less(i, j int) bool {
+	if h[i].DocFreq != h[j].DocFreq {
+		return h[i].DocFreq < h[j].DocFreq
+	}
+	return h[i].Term > h[j].Term
+}
+func (h termHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *termHeap) Push(x any)   { *h = append(*h, x.(TermCount)) }
+func (h *termHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
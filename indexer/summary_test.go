@@ -0,0 +1,80 @@
+package indexer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	first := NewInvertedIndex()
+	first.AddDocument("1", "Albert Einstein")
+	first.Add("physic", "1", NewPosting(BODY, 3))
+	first.Add("einstein", "1", NewPosting(TITLE, 1))
+	first.AddDocument("2", "Niels Bohr")
+	first.Add("physic", "2", NewPosting(BODY, 1))
+	first.AddDocument("3", "Max Planck")
+	first.Add("physic", "3", NewPosting(BODY, 1))
+	first.Add("physic", "3", NewPosting(CATEGORY, 1))
+	first.Add("planck", "3", NewPosting(TITLE, 1))
+	require.NoError(t, NewIndexWriter(indexPath).WriteIndex(first))
+
+	second := NewInvertedIndex()
+	second.AddDocument("2", "Niels Bohr")
+	second.Add("bohr", "2", NewPosting(TITLE, 1))
+	second.Add("physic", "2", NewPosting(BODY, 2))
+	writer := NewIndexWriter(indexPath)
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+	_, _, err := writer.Delete("3")
+	require.NoError(t, err)
+
+	summary, err := Summarize(indexPath, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, summary.Documents)
+	assert.Equal(t, 2, summary.Deleted, "deleted 3 and superseded 2")
+	assert.Equal(t, 3.5, summary.AvgDocLength)
+	assert.Equal(t, 4, summary.UniqueTerms)
+	assert.Equal(t, 7, summary.TotalPostings)
+	assert.Equal(t, []TermCount{{"physic", 4}, {"bohr", 1}}, summary.TopTerms)
+	assert.Equal(t, []LengthBucket{{Min: 1, Max: 1, Terms: 3}, {Min: 2, Max: 3, Terms: 0}, {Min: 4, Max: 7, Terms: 1}},
+		summary.PostingLengths)
+
+	fields := make(map[string]FieldSummary)
+	for _, field := range summary.Fields {
+		fields[field.Name] = field
+	}
+	assert.Equal(t, FieldSummary{Name: "body", Mask: BODY, Terms: 1, Postings: 4, Occurrences: 7}, fields["body"])
+	assert.Equal(t, FieldSummary{Name: "title", Mask: TITLE, Terms: 3, Postings: 3, Occurrences: 3}, fields["title"])
+	assert.Equal(t, 1, fields["category"].Postings)
+
+	require.Len(t, summary.Segments, 2)
+	assert.Equal(t, "_0", summary.Segments[0].Name)
+	assert.Equal(t, 3, summary.Segments[0].Documents)
+	assert.Equal(t, 2, summary.Segments[0].Deleted)
+	assert.Equal(t, 0, summary.Segments[1].Deleted)
+	assert.Equal(t, FormatVersion, summary.Segments[1].Manifest.FormatVersion)
+	assert.False(t, summary.BuildTime().IsZero())
+
+	var segmentSizes int64
+	paths := make([]string, 0, len(summary.Files))
+	for _, file := range summary.Files {
+		paths = append(paths, file.Path)
+	}
+	for _, seg := range summary.Segments {
+		segmentSizes += seg.Size
+	}
+	assert.Contains(t, paths, "_0/"+PostingsFile)
+	assert.Contains(t, paths, "_1/"+TermsFile)
+	assert.Greater(t, summary.TotalSize, segmentSizes, "commit and tombstones are outside segments")
+
+	_, err = Summarize(filepath.Join(t.TempDir(), "missing"), 10)
+	var wikiErr *WikiError
+	require.ErrorAs(t, err, &wikiErr)
+	assert.Equal(t, ErrIndexNotFound, wikiErr.Type)
+}
@@ -0,0 +1,155 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"sort"
+)
+
+// A table file stores variable length entries that can be found by
+// position, the layout shared by the term dictionary and the k-gram index:
+//
+//	magic, entries, uint64 entry offsets, footer
+//	footer: uint64 number of entries, uint64 offset of the entry offsets,
+//	        uint32 CRC-32C of the two, magic
+const tableFooterSize = 8 + 8 + 4 + 4
+
+// writeTable writes the n entries produced by entry, which appends the
+// i-th entry to buf, to path.
+func writeTable(path string, magic []byte, n int, entry func(i int, buf []byte) []byte) error {
+	return writeBuffered(path, func(writer *bufio.Writer) error {
+		if _, err := writer.Write(magic); err != nil {
+			return err
+		}
+		offset := uint64(len(magic))
+
+		offsets := make([]uint64, 0, n)
+		var buf []byte
+		for i := 0; i < n; i++ {
+			buf = entry(i, buf[:0])
+			if _, err := writer.Write(buf); err != nil {
+				return err
+			}
+			offsets = append(offsets, offset)
+			offset += uint64(len(buf))
+		}
+
+		indexOffset := offset
+		for _, entryOffset := range offsets {
+			buf = binary.LittleEndian.AppendUint64(buf[:0], entryOffset)
+			if _, err := writer.Write(buf); err != nil {
+				return err
+			}
+		}
+
+		buf = binary.LittleEndian.AppendUint64(buf[:0], uint64(n))
+		buf = binary.LittleEndian.AppendUint64(buf, indexOffset)
+		buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(buf, castagnoli))
+		buf = append(buf, magic...)
+		_, err := writer.Write(buf)
+		return err
+	})
+}
+
+// table reads a table file, memory-mapped where possible.
+type table struct {
+	path        string
+	data        blob
+	headerSize  int64
+	numEntries  int
+	indexOffset int64
+}
+
+func openTable(path string, magic []byte) (*table, error) {
+	data, err := openBlob(path)
+	if err != nil {
+		return nil, NewIOError("open "+path, err)
+	}
+	t := &table{path: path, data: data, headerSize: int64(len(magic))}
+	if err := t.readFooter(magic); err != nil {
+		_ = data.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *table) readFooter(magic []byte) error {
+	size := t.data.Size()
+	if size < int64(len(magic))+tableFooterSize {
+		return NewCorruptIndexError(t.path, "file too short")
+	}
+	head, err := t.data.Bytes(0, int64(len(magic)))
+	if err != nil {
+		return NewIOError("read "+t.path, err)
+	}
+	footer, err := t.data.Bytes(size-tableFooterSize, tableFooterSize)
+	if err != nil {
+		return NewIOError("read "+t.path, err)
+	}
+	if !bytes.Equal(head, magic) || !bytes.Equal(footer[20:], magic) {
+		return NewCorruptIndexError(t.path, "bad magic")
+	}
+	if crc32.Checksum(footer[:16], castagnoli) != binary.LittleEndian.Uint32(footer[16:]) {
+		return NewCorruptIndexError(t.path, "footer checksum mismatch")
+	}
+
+	numEntries := binary.LittleEndian.Uint64(footer)
+	indexOffset := binary.LittleEndian.Uint64(footer[8:])
+	if indexOffset > uint64(size-tableFooterSize) || numEntries != (uint64(size-tableFooterSize)-indexOffset)/8 {
+		return NewCorruptIndexError(t.path, "bad footer")
+	}
+	t.numEntries = int(numEntries)
+	t.indexOffset = int64(indexOffset)
+	return nil
+}
+
+func (t *table) Close() error {
+	return t.data.Close()
+}
+
+// entry returns the raw i-th entry.
+func (t *table) entry(i int) ([]byte, error) {
+	width := int64(16)
+	if i == t.numEntries-1 {
+		width = 8
+	}
+	raw, err := t.data.Bytes(t.indexOffset+int64(i)*8, width)
+	if err != nil {
+		return nil, err
+	}
+
+	start := int64(binary.LittleEndian.Uint64(raw))
+	end := t.indexOffset
+	if len(raw) == 16 {
+		end = int64(binary.LittleEndian.Uint64(raw[8:]))
+	}
+	if start < t.headerSize || end < start || end > t.indexOffset {
+		return nil, NewCorruptIndexError(t.path, "bad entry offset")
+	}
+	return t.data.Bytes(start, end-start)
+}
+
+// search returns the position of the first entry whose key, as split off
+// by key, is not less than target.
+func (t *table) search(target []byte, key func(entry []byte) ([]byte, bool)) (int, error) {
+	var err error
+	i := sort.Search(t.numEntries, func(i int) bool {
+		if err != nil {
+			return true
+		}
+		raw, entryErr := t.entry(i)
+		if entryErr != nil {
+			err = entryErr
+			return true
+		}
+		k, ok := key(raw)
+		if !ok {
+			err = NewCorruptIndexError(t.path, "malformed entry")
+			return true
+		}
+		return bytes.Compare(k, target) >= 0
+	})
+	return i, err
+}
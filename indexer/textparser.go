@@ -5,6 +5,8 @@ import (
 	"strings"
 )
 
+const tokenPattern = `[a-z]+`
+
 // Stop words - using empty struct for memory-efficient set
 var stopWords = map[string]struct{}{
 	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {},
@@ -26,6 +28,20 @@ type WikiTextParser struct {
 	stemmer *Stemmer
 	doc     *Document
 	terms   map[string]Posting
+	// forms counts the words each term was stemmed from.
+	forms map[string]map[string]int
+	// tokens records every word parsed when analyzing.
+	tokens    []Token
+	recording bool
+}
+
+// Token is a word of a page as the analyzer sees it: the word, the term
+// it is indexed as and the field it is indexed in. Term is empty for the
+// stop words that are dropped.
+type Token struct {
+	Word  string
+	Term  string
+	Field FieldMask
 }
 
 func NewWikiTextParser(doc *Document) *WikiTextParser {
@@ -33,7 +49,30 @@ func NewWikiTextParser(doc *Document) *WikiTextParser {
 		stemmer: NewStemmer(),
 		doc:     doc,
 		terms:   make(map[string]Posting),
+		forms:   make(map[string]map[string]int),
+	}
+}
+
+// Forms returns, for every term of the parsed document, the word it was
+// most often stemmed from.
+func (p *WikiTextParser) Forms() map[string]string {
+	forms := make(map[string]string, len(p.forms))
+	for term, counts := range p.forms {
+		forms[term] = dominantForm(counts)
 	}
+	return forms
+}
+
+// dominantForm returns the most frequent form in counts, the smallest one
+// on ties.
+func dominantForm(counts map[string]int) string {
+	best := ""
+	for form, count := range counts {
+		if best == "" || count > counts[best] || (count == counts[best] && form < best) {
+			best = form
+		}
+	}
+	return best
 }
 
 func (p *WikiTextParser) Parse() map[string]Posting {
@@ -46,6 +85,28 @@ func (p *WikiTextParser) Parse() map[string]Posting {
 	return p.terms
 }
 
+// ParseAnchors parses the anchor texts of the links to a page into
+// postings of the ANCHOR field, and returns them with the forms of their
+// terms like Forms.
+func ParseAnchors(texts []string) (map[string]Posting, map[string]string) {
+	p := NewWikiTextParser(&Document{Metadata: make(map[string]string)})
+	defer p.stemmer.Release()
+
+	for _, text := range texts {
+		p.parseText(text, ANCHOR)
+	}
+	return p.terms, p.Forms()
+}
+
+// Analyze parses the document like Parse and returns every word it finds,
+// in the order they are read: the title first, then the categories,
+// infoboxes, geoboxes, links and body.
+func (p *WikiTextParser) Analyze() []Token {
+	p.recording = true
+	p.Parse()
+	return p.tokens
+}
+
 func (p *WikiTextParser) parseWikiText(text string) {
 	text = strings.ToLower(text)
 
@@ -125,17 +186,29 @@ func (p *WikiTextParser) parseInfobox(infoboxText string) {
 }
 
 func (p *WikiTextParser) parseText(text string, field FieldMask) {
-	wordRegex := regexp.MustCompile(`[a-z]+`)
+	wordRegex := regexp.MustCompile(tokenPattern)
 	words := wordRegex.FindAllString(strings.ToLower(text), -1)
 
 	for _, word := range words {
+		if p.recording && (len(word) <= 1 || IsStopWord(word)) {
+			p.tokens = append(p.tokens, Token{Word: word, Field: field})
+		}
 		if len(word) > 1 && !IsStopWord(word) {
 			stemmed := p.stemmer.Stem(word)
 
 			term := p.terms[stemmed]
 			term.Fields |= field
 			term.Frequency++
+			term.FieldFreqs[fieldIndex(field)]++
 			p.terms[stemmed] = term
+
+			if p.forms[stemmed] == nil {
+				p.forms[stemmed] = make(map[string]int)
+			}
+			p.forms[stemmed][word]++
+			if p.recording {
+				p.tokens = append(p.tokens, Token{Word: word, Term: stemmed, Field: field})
+			}
 		}
 	}
 }
@@ -118,3 +118,66 @@ func TestWikiTextParser_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestWikiTextParser_FieldFrequencies(t *testing.T) {
+	doc := &Document{
+		ID:       "1",
+		Title:    "Apple",
+		Content:  "Apple pie. An apple a day. [[Category:Apple cultivars]]",
+		Metadata: make(map[string]string),
+	}
+
+	terms := NewWikiTextParser(doc).Parse()
+	posting := terms["appl"]
+	assert.Equal(t, TITLE|BODY|CATEGORY|LINKS, posting.Fields)
+	assert.Equal(t, 1, posting.FieldFrequency(TITLE))
+	assert.Equal(t, 2, posting.FieldFrequency(BODY))
+	assert.Equal(t, 1, posting.FieldFrequency(CATEGORY))
+	assert.Equal(t, 1, posting.FieldFrequency(LINKS))
+	assert.Equal(t, 0, posting.FieldFrequency(INFOBOX))
+	assert.Equal(t, 5, posting.Frequency)
+}
+
+func TestParseAnchors(t *testing.T) {
+	terms, forms := ParseAnchors([]string{"Apple pie", "the apples"})
+	assert.Equal(t, ANCHOR, terms["appl"].Fields)
+	assert.Equal(t, 2, terms["appl"].FieldFrequency(ANCHOR))
+	assert.Equal(t, 1, terms["pie"].Frequency)
+	assert.NotContains(t, terms, "the")
+	assert.Equal(t, "apple", forms["appl"])
+}
+
+func TestWikiTextParser_Forms(t *testing.T) {
+	doc := &Document{
+		ID:       "1",
+		Title:    "Apples",
+		Content:  "An apple, two apples and more apples. Running runs.",
+		Metadata: make(map[string]string),
+	}
+
+	parser := NewWikiTextParser(doc)
+	parser.Parse()
+	forms := parser.Forms()
+	assert.Equal(t, "apples", forms["appl"])
+	assert.Equal(t, "running", forms["run"])
+}
+
+func TestWikiTextParser_Analyze(t *testing.T) {
+	doc := &Document{
+		Title:    "Paris",
+		Content:  "The capital of [[France]].\n[[Category:Cities]]",
+		Metadata: make(map[string]string),
+	}
+
+	tokens := NewWikiTextParser(doc).Analyze()
+	assert.Equal(t, []Token{
+		{Word: "paris", Term: "pari", Field: TITLE},
+		{Word: "cities", Term: "citi", Field: CATEGORY},
+		{Word: "france", Term: "franc", Field: LINKS},
+		{Word: "category", Term: "categori", Field: LINKS},
+		{Word: "cities", Term: "citi", Field: LINKS},
+		{Word: "the", Field: BODY},
+		{Word: "capital", Term: "capit", Field: BODY},
+		{Word: "of", Field: BODY},
+	}, tokens)
+}
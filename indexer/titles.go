@@ -0,0 +1,404 @@
+package indexer
+
+import (
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TitlesFile lists the titles of a segment's documents, normalized with
+// TitleKey, for title autocompletion. It is a table file (see writeTable)
+// with one entry per titled document, in key order:
+//
+//	entry: uvarint len(key), key, uvarint doc ordinal, uvarint popularity
+//
+// SuggestFile holds, for every key prefix of up to suggestPrefixLen bytes,
+// the positions in TitlesFile of the suggestTopK most popular titles that
+// start with it, one per article and best first, so that short prefixes do
+// not scan the long runs of titles they match:
+//
+//	entry: uvarint len(prefix), prefix, uvarint count, uvarint positions
+const (
+	TitlesFile  = "titles.dat"
+	SuggestFile = "suggest.dat"
+)
+
+const (
+	suggestPrefixLen = 3
+	suggestTopK      = 16
+)
+
+var (
+	titlesMagic  = []byte("WFTI")
+	suggestMagic = []byte("WFSG")
+)
+
+// TitleKey normalizes a title for prefix matching: it is lower cased, and
+// runs of spaces and underscores become single spaces.
+func TitleKey(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return r == '_' || unicode.IsSpace(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// TitleMatch is a document whose title completes a prefix. The popularity
+// of a redirect is that of the article it leads to.
+type TitleMatch struct {
+	Ordinal    uint32
+	Popularity int
+}
+
+// titleEntry is an entry of the titles file with the key of the article
+// it leads to.
+type titleEntry struct {
+	key        string
+	ord        uint32
+	popularity int
+	article    string
+}
+
+// betterTitle orders title entries by popularity, then by key.
+func betterTitle(a, b *titleEntry) bool {
+	if a.popularity != b.popularity {
+		return a.popularity > b.popularity
+	}
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.ord < b.ord
+}
+
+// articleKey returns the key of the article a document's title leads to.
+func articleKey(info DocInfo) string {
+	if info.Redirect != "" {
+		return TitleKey(info.Redirect)
+	}
+	return TitleKey(info.Title)
+}
+
+// writeTitlesFiles writes the title table and suggestion lists of the
+// documents of index, in ordinal order. A document is as popular as it is
+// long, and a redirect as popular as its article, which external gives
+// the length of when it is in another segment.
+func writeTitlesFiles(dir string, index *InvertedIndex, docIDs []string, external map[string]int) error {
+	index.mutex.RLock()
+	lengths := make(map[string]int)
+	entries := make([]titleEntry, 0, len(docIDs))
+	var redirects []int
+	for ord, docID := range docIDs {
+		info := index.Docs[docID]
+		key := TitleKey(info.Title)
+		if key == "" {
+			continue
+		}
+		if info.Redirect != "" {
+			redirects = append(redirects, len(entries))
+		} else {
+			lengths[key] = max(lengths[key], info.Length)
+		}
+		entries = append(entries, titleEntry{key: key, ord: uint32(ord), popularity: info.Length, article: articleKey(info)})
+	}
+	for _, i := range redirects {
+		length, ok := lengths[entries[i].article]
+		if !ok {
+			length = external[entries[i].article]
+		}
+		entries[i].popularity = length
+	}
+	index.mutex.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		return entries[i].ord < entries[j].ord
+	})
+	err := writeTable(filepath.Join(dir, TitlesFile), titlesMagic, len(entries), func(i int, buf []byte) []byte {
+		buf = binary.AppendUvarint(buf, uint64(len(entries[i].key)))
+		buf = append(buf, entries[i].key...)
+		buf = binary.AppendUvarint(buf, uint64(entries[i].ord))
+		return binary.AppendUvarint(buf, uint64(entries[i].popularity))
+	})
+	if err != nil {
+		return err
+	}
+
+	ranked := make([]int, len(entries))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return betterTitle(&entries[ranked[i]], &entries[ranked[j]])
+	})
+
+	top := make(map[string][]uint32)
+	for _, pos := range ranked {
+		entry := &entries[pos]
+		for n := 1; n <= min(len(entry.key), suggestPrefixLen); n++ {
+			prefix := entry.key[:n]
+			if list := top[prefix]; len(list) < suggestTopK && !hasArticle(entries, list, entry.article) {
+				top[prefix] = append(list, uint32(pos))
+			}
+		}
+	}
+
+	prefixes := sortedKeys(top)
+	return writeTable(filepath.Join(dir, SuggestFile), suggestMagic, len(prefixes), func(i int, buf []byte) []byte {
+		buf = binary.AppendUvarint(buf, uint64(len(prefixes[i])))
+		buf = append(buf, prefixes[i]...)
+		list := top[prefixes[i]]
+		buf = binary.AppendUvarint(buf, uint64(len(list)))
+		for _, pos := range list {
+			buf = binary.AppendUvarint(buf, uint64(pos))
+		}
+		return buf
+	})
+}
+
+// missingArticles returns the keys of the articles the redirects among
+// docIDs lead to that are not among docIDs themselves.
+func missingArticles(index *InvertedIndex, docIDs []string) []string {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	articles := make(map[string]bool)
+	for _, docID := range docIDs {
+		if info := index.Docs[docID]; info.Redirect == "" {
+			articles[TitleKey(info.Title)] = true
+		}
+	}
+	var missing []string
+	for _, docID := range docIDs {
+		if info := index.Docs[docID]; info.Redirect != "" {
+			if key := TitleKey(info.Redirect); !articles[key] {
+				articles[key] = true
+				missing = append(missing, key)
+			}
+		}
+	}
+	return missing
+}
+
+// articleLengths looks up the live articles with the given keys in the
+// committed segments and returns their lengths, leaving out those not
+// found. A redirect added after its article, or a merged segment with
+// redirects to articles outside it, ranks by them.
+func (w *IndexWriter) articleLengths(keys []string) (map[string]int, error) {
+	lengths := make(map[string]int, len(keys))
+	if len(keys) == 0 {
+		return lengths, nil
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	commit, err := readCommitOrEmpty(w.indexPath)
+	if err != nil || len(commit.Segments) == 0 {
+		return lengths, err
+	}
+	r, err := OpenIndexReader(w.indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	for _, key := range keys {
+		for i := len(r.Segments) - 1; i >= 0; i-- {
+			segment := r.Segments[i]
+			ord, found, err := segment.LookupTitle(key, func(ord uint32) bool { return r.IsDead(i, ord) })
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				lengths[key] = segment.Doc(ord).Length
+				break
+			}
+		}
+	}
+	return lengths, nil
+}
+
+// hasArticle reports whether one of the entries at positions leads to
+// article.
+func hasArticle(entries []titleEntry, positions []uint32, article string) bool {
+	for _, pos := range positions {
+		if entries[pos].article == article {
+			return true
+		}
+	}
+	return false
+}
+
+// titleAt decodes the i-th entry of the title table.
+func (r *SegmentReader) titleAt(i int) (titleEntry, error) {
+	raw, err := r.titles.entry(i)
+	if err != nil {
+		return titleEntry{}, err
+	}
+	key, rest, ok := termBytes(raw)
+	if !ok {
+		return titleEntry{}, NewCorruptIndexError(r.titles.path, "malformed entry")
+	}
+	ord, k := binary.Uvarint(rest)
+	if k <= 0 || ord >= uint64(len(r.docIDs)) {
+		return titleEntry{}, NewCorruptIndexError(r.titles.path, "malformed entry")
+	}
+	popularity, m := binary.Uvarint(rest[k:])
+	if m <= 0 || popularity > math.MaxInt {
+		return titleEntry{}, NewCorruptIndexError(r.titles.path, "malformed entry")
+	}
+	return titleEntry{
+		key:        string(key),
+		ord:        uint32(ord),
+		popularity: int(popularity),
+		article:    articleKey(r.docs[ord]),
+	}, nil
+}
+
+// seekTitle returns the position of the first title key not less than key.
+func (r *SegmentReader) seekTitle(key string) (int, error) {
+	return r.titles.search([]byte(key), func(entry []byte) ([]byte, bool) {
+		k, _, ok := termBytes(entry)
+		return k, ok
+	})
+}
+
+// topTitles returns the positions stored in the suggestion list of prefix,
+// which must be at most suggestPrefixLen bytes long.
+func (r *SegmentReader) topTitles(prefix string) ([]uint32, error) {
+	i, err := r.suggest.search([]byte(prefix), func(entry []byte) ([]byte, bool) {
+		k, _, ok := termBytes(entry)
+		return k, ok
+	})
+	if err != nil || i == r.suggest.numEntries {
+		return nil, err
+	}
+	raw, err := r.suggest.entry(i)
+	if err != nil {
+		return nil, err
+	}
+	key, rest, ok := termBytes(raw)
+	if !ok {
+		return nil, NewCorruptIndexError(r.suggest.path, "malformed entry")
+	}
+	if string(key) != prefix {
+		return nil, nil
+	}
+
+	count, k := binary.Uvarint(rest)
+	if k <= 0 || count > suggestTopK {
+		return nil, NewCorruptIndexError(r.suggest.path, "malformed entry")
+	}
+	rest = rest[k:]
+	positions := make([]uint32, count)
+	for j := range positions {
+		pos, k := binary.Uvarint(rest)
+		if k <= 0 || pos >= uint64(r.titles.numEntries) {
+			return nil, NewCorruptIndexError(r.suggest.path, "malformed entry")
+		}
+		positions[j] = uint32(pos)
+		rest = rest[k:]
+	}
+	return positions, nil
+}
+
+// SuggestTitles returns up to n titles of the segment starting with the
+// key prefix, most popular first and one per article, leaving out the
+// documents for which skip returns true. Prefixes of up to three bytes are
+// answered from the stored suggestion lists when they hold enough live
+// titles; longer ones scan the titles they match.
+func (r *SegmentReader) SuggestTitles(prefix string, n int, skip func(ord uint32) bool) ([]TitleMatch, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	var candidates []titleEntry
+	if len(prefix) > 0 && len(prefix) <= suggestPrefixLen {
+		positions, err := r.topTitles(prefix)
+		if err != nil {
+			return nil, err
+		}
+		skipped := false
+		for _, pos := range positions {
+			entry, err := r.titleAt(int(pos))
+			if err != nil {
+				return nil, err
+			}
+			if skip(entry.ord) {
+				skipped = true
+				continue
+			}
+			candidates = append(candidates, entry)
+		}
+		// A short list holds every article with the prefix, unless one of
+		// its titles was skipped in favour of another for the same article.
+		if len(candidates) >= n || (len(positions) < suggestTopK && !skipped) {
+			return rankTitles(candidates, n), nil
+		}
+		candidates = candidates[:0]
+	}
+
+	start, err := r.seekTitle(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := start; i < r.titles.numEntries; i++ {
+		entry, err := r.titleAt(i)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(entry.key, prefix) {
+			break
+		}
+		if !skip(entry.ord) {
+			candidates = append(candidates, entry)
+		}
+	}
+	return rankTitles(candidates, n), nil
+}
+
+// rankTitles returns the n best entries, keeping the best of each article.
+func rankTitles(entries []titleEntry, n int) []TitleMatch {
+	sort.Slice(entries, func(i, j int) bool {
+		return betterTitle(&entries[i], &entries[j])
+	})
+	seen := make(map[string]bool)
+	var matches []TitleMatch
+	for _, entry := range entries {
+		if len(matches) == n {
+			break
+		}
+		if !seen[entry.article] {
+			seen[entry.article] = true
+			matches = append(matches, TitleMatch{Ordinal: entry.ord, Popularity: entry.popularity})
+		}
+	}
+	return matches
+}
+
+// LookupTitle finds the article titled title, ignoring redirects and the
+// documents for which skip returns true.
+func (r *SegmentReader) LookupTitle(title string, skip func(ord uint32) bool) (uint32, bool, error) {
+	key := TitleKey(title)
+	start, err := r.seekTitle(key)
+	if err != nil {
+		return 0, false, err
+	}
+	for i := start; i < r.titles.numEntries; i++ {
+		entry, err := r.titleAt(i)
+		if err != nil {
+			return 0, false, err
+		}
+		if entry.key != key {
+			break
+		}
+		if r.docs[entry.ord].Redirect == "" && !skip(entry.ord) {
+			return entry.ord, true, nil
+		}
+	}
+	return 0, false, nil
+}
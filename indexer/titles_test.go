@@ -0,0 +1,120 @@
+package indexer
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTitleKey(t *testing.T) {
+	assert.Equal(t, "albert einstein", TitleKey("  Albert_Einstein "))
+	assert.Equal(t, "new york city", TitleKey("New  York\tCity"))
+	assert.Equal(t, "", TitleKey(" _ "))
+}
+
+func TestSegmentReader_SuggestTitles(t *testing.T) {
+	idx := NewInvertedIndex()
+	addDoc := func(docID, title string, length int) {
+		idx.AddDocument(docID, title)
+		if length > 0 {
+			idx.Add("word", docID, NewPosting(BODY, length))
+		}
+	}
+	addDoc("1", "Albert Einstein", 50)
+	addDoc("2", "Einstein", 1)
+	idx.SetRedirect("2", "Albert Einstein")
+	addDoc("7", "Einstein, Albert", 1)
+	idx.SetRedirect("7", "Albert Einstein")
+	addDoc("3", "Alberta", 30)
+	addDoc("4", "Albert II", 10)
+	addDoc("5", "Einsteinium", 20)
+	addDoc("6", "Einstein (disambiguation)", 5)
+	// More titles with "alb" than a suggestion list holds.
+	for i := 0; i < suggestTopK+4; i++ {
+		addDoc(fmt.Sprintf("x%02d", i), fmt.Sprintf("Album %02d", i), 40-i)
+	}
+
+	r, err := OpenSegmentReader(writeTestSegment(t, idx))
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	titles := func(prefix string, n int, dead ...string) []string {
+		skip := func(ord uint32) bool {
+			for _, docID := range dead {
+				if r.DocID(ord) == docID {
+					return true
+				}
+			}
+			return false
+		}
+		matches, err := r.SuggestTitles(prefix, n, skip)
+		require.NoError(t, err)
+		var titles []string
+		for _, m := range matches {
+			titles = append(titles, r.Doc(m.Ordinal).Title)
+		}
+		return titles
+	}
+
+	// The redirect is as popular as its article, which is suggested once.
+	assert.Equal(t, []string{"Einstein", "Einsteinium", "Einstein (disambiguation)"}, titles("ein", 5))
+	assert.Equal(t, []string{"Albert Einstein", "Alberta"}, titles("albert", 2))
+	assert.Equal(t, []string{"Einstein, Albert", "Einsteinium"}, titles("ein", 5, "2", "6"))
+	assert.Equal(t, []string{"Albert Einstein", "Album 00", "Album 01"}, titles("al", 3))
+	assert.Equal(t, []string{"Albert Einstein", "Alberta", "Albert II"}, titles("albert", 5))
+	assert.Equal(t, []string{"Albert Einstein", "Albert II"}, titles("albert ", 5))
+	assert.Equal(t, []string{"Album 18", "Album 19"}, titles("album 1", 10)[8:])
+	assert.Empty(t, titles("zz", 5))
+	assert.Empty(t, titles("ein", 0))
+
+	// The list for "alb" is full, so when its titles are dead the rest is
+	// scanned.
+	var dead []string
+	for i := 0; i < suggestTopK; i++ {
+		dead = append(dead, fmt.Sprintf("x%02d", i))
+	}
+	assert.Equal(t, []string{"Albert Einstein", "Alberta", "Album 16", "Album 17", "Album 18", "Album 19"},
+		titles("alb", 6, dead...))
+
+	ord, found, err := r.LookupTitle("albert_einstein", func(uint32) bool { return false })
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "1", r.DocID(ord))
+	_, found, err = r.LookupTitle("Einstein", func(uint32) bool { return false })
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestIndexWriter_RedirectToOlderSegment(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	writer := NewIndexWriter(indexPath)
+
+	first := NewInvertedIndex()
+	first.AddDocument("1", "Albert Einstein")
+	first.Add("word", "1", NewPosting(BODY, 50))
+	require.NoError(t, writer.WriteIndex(first))
+
+	second := NewInvertedIndex()
+	second.AddDocument("2", "Einstein")
+	second.SetRedirect("2", "Albert Einstein")
+	// More titles with "ein" than a suggestion list holds.
+	for i := 0; i < suggestTopK+4; i++ {
+		docID := fmt.Sprintf("x%02d", i)
+		second.AddDocument(docID, fmt.Sprintf("Ein %02d", i))
+		second.Add("word", docID, NewPosting(BODY, 1+i))
+	}
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+
+	r, err := OpenSegmentReader(SegmentPath(indexPath, "_1"))
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+	matches, err := r.SuggestTitles("ein", 1, func(uint32) bool { return false })
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "Einstein", r.Doc(matches[0].Ordinal).Title)
+	assert.Equal(t, 50, matches[0].Popularity, "the redirect is as popular as its article in the older segment")
+}
@@ -19,28 +19,54 @@ type TextProcessor interface {
 }
 
 type Document struct {
-	ID       string
+	ID      string
+	Title   string
+	Content string
+	// Redirect is the title of the page a redirect page leads to.
+	Redirect string
+	// Timestamp is when the revision indexed was made, in RFC 3339 format.
+	Timestamp string
+	Metadata  map[string]string
+}
+
+// DocInfo is what a segment stores about a document besides its postings.
+// Redirect is the title of the article a redirect page leads to.
+type DocInfo struct {
 	Title    string
-	Content  string
-	Metadata map[string]string
+	Length   int
+	Redirect string
 }
 
+// Posting records the occurrences of a term in one document. Frequency is
+// the total over all fields; FieldFreqs breaks it down by field, indexed by
+// the field's bit position, and is non-zero exactly for the fields in
+// Fields.
 type Posting struct {
-	Fields    FieldMask
-	Frequency int
+	Fields     FieldMask
+	Frequency  int
+	FieldFreqs [NumFields]uint32
 }
 
 type xmlPage struct {
-	ID    string `xml:"id"`
-	Title string `xml:"title"`
-	Text  string `xml:"revision>text"`
+	ID       string `xml:"id"`
+	Title    string `xml:"title"`
+	Redirect struct {
+		Title string `xml:"title,attr"`
+	} `xml:"redirect"`
+	Text      string `xml:"revision>text"`
+	Timestamp string `xml:"revision>timestamp"`
 }
 
 type FieldMask byte
 
+// NumFields is the number of fields a FieldMask can hold.
+const NumFields = 8
+
 type WikiXMLParser struct {
-	indexPath string
-	index     *InvertedIndex
+	indexPath  string
+	sourceName string
+	appendMode bool
+	index      *InvertedIndex
 }
 
 const (
@@ -50,4 +76,6 @@ const (
 	BODY     FieldMask = 1 << 3 // 8
 	LINKS    FieldMask = 1 << 2 // 4
 	INFOBOX  FieldMask = 1 << 1 // 2
+	// ANCHOR holds the texts of the links to a page from other pages.
+	ANCHOR FieldMask = 1 << 6 // 64
 )
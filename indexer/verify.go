@@ -0,0 +1,157 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// VerifyReport is what Verify found in an index.
+type VerifyReport struct {
+	Generation int
+	Segments   int
+	Documents  int
+	Terms      int
+	Postings   int
+	// Problems lists the corruption found, empty if the index is sound.
+	Problems []*WikiError
+}
+
+// Verify reads the whole of the latest commit of the index at indexPath
+// and checks the file checksums recorded in the segment manifests, the
+// posting block checksums and the invariants the readers rely on: doc IDs
+// and terms in strictly ascending order, and dictionary statistics that
+// agree with the posting lists. Corruption is reported in the report;
+// the error is for an index that cannot be read at all.
+func Verify(indexPath string) (*VerifyReport, error) {
+	commit, err := ReadCommit(indexPath)
+	var wikiErr *WikiError
+	if errors.As(err, &wikiErr) && wikiErr.Type == ErrCorruptIndex {
+		return &VerifyReport{Problems: []*WikiError{wikiErr}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	report := &VerifyReport{Generation: commit.Generation}
+	for _, info := range commit.Segments {
+		report.Segments++
+		report.verifySegment(indexPath, info)
+	}
+	if _, err := ReadRanks(indexPath, commit); err != nil {
+		report.addErr(filepath.Join(indexPath, ranksFileName(commit.RanksGen)), err)
+	}
+	return report, nil
+}
+
+func (report *VerifyReport) add(path, format string, args ...any) {
+	report.Problems = append(report.Problems, NewCorruptIndexError(path, fmt.Sprintf(format, args...)))
+}
+
+// addErr records err, which describes the file at path unless it is a
+// WikiError of its own.
+func (report *VerifyReport) addErr(path string, err error) {
+	var wikiErr *WikiError
+	if errors.As(err, &wikiErr) {
+		report.Problems = append(report.Problems, wikiErr)
+		return
+	}
+	report.add(path, "%v", err)
+}
+
+func (report *VerifyReport) verifySegment(indexPath string, info SegmentInfo) {
+	dir := SegmentPath(indexPath, info.Name)
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		report.addErr(filepath.Join(dir, ManifestFile), err)
+		return
+	}
+	if len(manifest.Files) == 0 {
+		report.add(filepath.Join(dir, ManifestFile), "no file checksums")
+	}
+	if problems := manifest.VerifyFiles(dir); len(problems) > 0 {
+		// Reading files that fail their checksums would only report the
+		// same corruption again, or worse.
+		report.Problems = append(report.Problems, problems...)
+		return
+	}
+
+	segment, err := OpenSegmentReader(dir)
+	if err != nil {
+		report.addErr(dir, err)
+		return
+	}
+	defer func() { _ = segment.Close() }()
+
+	docsPath := filepath.Join(dir, DocsFile)
+	report.Documents += segment.DocCount()
+	if segment.DocCount() != info.DocCount {
+		report.add(docsPath, "%d documents, the commit records %d", segment.DocCount(), info.DocCount)
+	}
+	for ord := 1; ord < segment.DocCount(); ord++ {
+		if prev, docID := segment.DocID(uint32(ord-1)), segment.DocID(uint32(ord)); prev >= docID {
+			report.add(docsPath, "doc ID %q at ordinal %d does not sort after %q", docID, ord, prev)
+		}
+	}
+
+	deletes, err := ReadSegmentDeletes(indexPath, info)
+	if err != nil {
+		report.addErr(filepath.Join(indexPath, deletesFileName(info.Name, info.DelGen)), err)
+	} else if len(deletes) != info.DelCount {
+		report.add(filepath.Join(indexPath, deletesFileName(info.Name, info.DelGen)),
+			"%d tombstones, the commit records %d", len(deletes), info.DelCount)
+	}
+
+	termsPath := filepath.Join(dir, TermsFile)
+	prev := ""
+	for t := range segment.NumTerms() {
+		term, err := segment.TermAt(t)
+		if err != nil {
+			report.addErr(termsPath, err)
+			return
+		}
+		report.Terms++
+		if t > 0 && term.Term <= prev {
+			report.add(termsPath, "term %q at position %d does not sort after %q", term.Term, t, prev)
+		}
+		prev = term.Term
+		report.verifyPostings(dir, segment, term)
+	}
+}
+
+// verifyPostings decodes the posting list of term and checks it against
+// the statistics in the dictionary.
+func (report *VerifyReport) verifyPostings(dir string, segment *SegmentReader, term TermInfo) {
+	path := filepath.Join(dir, PostingsFile)
+	it, err := segment.Postings(term)
+	if err != nil {
+		report.addErr(path, err)
+		return
+	}
+
+	count, maxFreq := 0, 0
+	var fields FieldMask
+	var maxFieldFreqs [NumFields]uint32
+	for it.Next() {
+		count++
+		report.Postings++
+		posting := it.Posting()
+		maxFreq = max(maxFreq, posting.Frequency)
+		fields |= posting.Fields
+		for i, freq := range posting.FieldFreqs {
+			maxFieldFreqs[i] = max(maxFieldFreqs[i], freq)
+		}
+	}
+	if err := it.Err(); err != nil {
+		report.add(path, "posting list of %q: %v", term.Term, err)
+		return
+	}
+
+	switch {
+	case count != term.DocFreq:
+		report.add(path, "posting list of %q has %d documents, the dictionary records %d", term.Term, count, term.DocFreq)
+	case maxFreq != term.MaxFrequency:
+		report.add(path, "posting list of %q has max frequency %d, the dictionary records %d", term.Term, maxFreq, term.MaxFrequency)
+	case fields != term.Fields || maxFieldFreqs != term.MaxFieldFreqs:
+		report.add(path, "posting list of %q disagrees with the field statistics in the dictionary", term.Term)
+	}
+}
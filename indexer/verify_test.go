@@ -0,0 +1,182 @@
+package indexer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeVerifyIndex(t *testing.T) string {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := NewInvertedIndex()
+	idx.AddDocument("1", "Paris")
+	idx.Add("pari", "1", NewPosting(TITLE, 1))
+	idx.Add("franc", "1", NewPosting(BODY, 2))
+	idx.AddDocument("2", "Lyon")
+	idx.Add("franc", "2", NewPosting(BODY, 1))
+	writer := NewIndexWriter(indexPath)
+	require.NoError(t, writer.WriteIndex(idx))
+	_, _, err := writer.Delete("2")
+	require.NoError(t, err)
+	return indexPath
+}
+
+func TestVerify(t *testing.T) {
+	indexPath := writeVerifyIndex(t)
+
+	report, err := Verify(indexPath)
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+	assert.Equal(t, 1, report.Segments)
+	assert.Equal(t, 2, report.Documents)
+	assert.Equal(t, 2, report.Terms)
+	assert.Equal(t, 3, report.Postings)
+
+	manifest, err := ReadManifest(SegmentPath(indexPath, "_0"))
+	require.NoError(t, err)
+	var names []string
+	for _, file := range manifest.Files {
+		names = append(names, file.Name)
+	}
+	assert.Contains(t, names, PostingsFile)
+	assert.Contains(t, names, DocsFile)
+	assert.NotContains(t, names, ManifestFile)
+
+	_, err = Verify(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestVerify_Corrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, dir string)
+		want    string
+	}{
+		{
+			name: "flipped byte",
+			corrupt: func(t *testing.T, dir string) {
+				path := filepath.Join(dir, PostingsFile)
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				data[len(data)/2] ^= 0xff
+				require.NoError(t, os.WriteFile(path, data, 0644))
+			},
+			want: "checksum",
+		},
+		{
+			name: "truncated file",
+			corrupt: func(t *testing.T, dir string) {
+				require.NoError(t, os.Truncate(filepath.Join(dir, TermsFile), 10))
+			},
+			want: "size 10",
+		},
+		{
+			name: "missing file",
+			corrupt: func(t *testing.T, dir string) {
+				require.NoError(t, os.Remove(filepath.Join(dir, DocsFile)))
+			},
+			want: "missing file",
+		},
+		{
+			name: "tombstones",
+			corrupt: func(t *testing.T, dir string) {
+				require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(dir), deletesFileName("_0", 1)), []byte("1\n"), 0644))
+			},
+			want: "checksum",
+		},
+		{
+			name: "commit point",
+			corrupt: func(t *testing.T, dir string) {
+				path := filepath.Join(filepath.Dir(dir), commitName(2))
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, bytes.Replace(data, []byte(`"del_count": 1`), []byte(`"del_count": 2`), 1), 0644))
+			},
+			want: "checksum mismatch",
+		},
+		{
+			name: "extra file",
+			corrupt: func(t *testing.T, dir string) {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "stray.dat"), nil, 0644))
+			},
+			want: "not listed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexPath := writeVerifyIndex(t)
+			tt.corrupt(t, SegmentPath(indexPath, "_0"))
+
+			report, err := Verify(indexPath)
+			require.NoError(t, err)
+			require.NotEmpty(t, report.Problems)
+			assert.Equal(t, ErrCorruptIndex, report.Problems[0].Type)
+			assert.Contains(t, report.Problems[0].Error(), tt.want)
+		})
+	}
+}
+
+func TestVerify_Structure(t *testing.T) {
+	indexPath := writeVerifyIndex(t)
+	dir := SegmentPath(indexPath, "_0")
+
+	// Swap the documents and fix up the checksums, so only the structural
+	// checks can tell.
+	path := filepath.Join(dir, DocsFile)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 2)
+	lines[1] += "\n"
+	require.NoError(t, os.WriteFile(path, []byte(lines[1]+lines[0]), 0644))
+	manifest, err := ReadManifest(dir)
+	require.NoError(t, err)
+	manifest.Files, err = checksumFiles(dir)
+	require.NoError(t, err)
+	require.NoError(t, WriteManifest(dir, manifest))
+
+	report, err := Verify(indexPath)
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 1)
+	assert.Contains(t, report.Problems[0].Error(), `doc ID "1" at ordinal 1 does not sort after "2"`)
+}
+
+func TestOpenIndexReader_Truncated(t *testing.T) {
+	indexPath := writeVerifyIndex(t)
+	require.NoError(t, os.Truncate(filepath.Join(SegmentPath(indexPath, "_0"), PostingsFile), 10))
+
+	_, err := OpenIndexReader(indexPath)
+	var wikiErr *WikiError
+	require.ErrorAs(t, err, &wikiErr)
+	assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+}
+
+func TestOpenIndexReader_Checksums(t *testing.T) {
+	// Small files are checked whole when the index is opened.
+	indexPath := writeVerifyIndex(t)
+	path := filepath.Join(SegmentPath(indexPath, "_0"), TitlesFile)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)/2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	_, err = OpenIndexReader(indexPath)
+	var wikiErr *WikiError
+	require.ErrorAs(t, err, &wikiErr)
+	assert.Equal(t, ErrCorruptIndex, wikiErr.Type)
+	assert.Contains(t, wikiErr.Error(), "checksum")
+
+	// Table footers carry a checksum of their own for larger files.
+	data[len(data)/2] ^= 0xff
+	data[len(data)-tableFooterSize] ^= 0x01
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	_, err = openTable(path, titlesMagic)
+	require.ErrorAs(t, err, &wikiErr)
+	assert.Contains(t, wikiErr.Error(), "footer checksum mismatch")
+}
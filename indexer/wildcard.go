@@ -0,0 +1,194 @@
+package indexer
+
+import (
+	"encoding/binary"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// KGramsFile maps each 3-gram of the segment's terms, with "$" marking the
+// start and end of a term, to the positions of the terms in the
+// dictionary. It lets wildcard patterns that do not start with a literal
+// prefix be expanded without scanning the whole dictionary. It is a table
+// file (see writeTable) with one entry per gram, in gram order:
+//
+//	entry: gram, uvarint count, uvarint term position deltas
+const KGramsFile = "kgrams.dat"
+
+const (
+	kgramSize    = 3
+	termBoundary = "$"
+)
+
+var kgramsMagic = []byte("WFKG")
+
+// IsWildcard reports whether s is a wildcard pattern: "*" matches any run
+// of letters, including none, and "?" exactly one.
+func IsWildcard(s string) bool {
+	return strings.ContainsAny(s, "*?")
+}
+
+// termGrams returns the k-grams of term with its boundaries marked.
+func termGrams(term string) []string {
+	marked := termBoundary + term + termBoundary
+	grams := make([]string, 0, len(marked))
+	for i := 0; i+kgramSize <= len(marked); i++ {
+		grams = append(grams, marked[i:i+kgramSize])
+	}
+	return grams
+}
+
+// patternGrams returns the k-grams every term matching pattern contains.
+func patternGrams(pattern string) []string {
+	var grams []string
+	pieces := strings.FieldsFunc(termBoundary+pattern+termBoundary, func(r rune) bool {
+		return r == '*' || r == '?'
+	})
+	for _, piece := range pieces {
+		for i := 0; i+kgramSize <= len(piece); i++ {
+			grams = append(grams, piece[i:i+kgramSize])
+		}
+	}
+	return grams
+}
+
+// writeKGramsFile writes the k-gram index of the terms in infos, which
+// must be sorted by term.
+func writeKGramsFile(dir string, infos []TermInfo) error {
+	positions := make(map[string][]uint32)
+	for i, info := range infos {
+		for _, gram := range termGrams(info.Term) {
+			list := positions[gram]
+			if len(list) == 0 || list[len(list)-1] != uint32(i) {
+				positions[gram] = append(list, uint32(i))
+			}
+		}
+	}
+
+	grams := sortedKeys(positions)
+	return writeTable(filepath.Join(dir, KGramsFile), kgramsMagic, len(grams), func(i int, buf []byte) []byte {
+		buf = append(buf, grams[i]...)
+		list := positions[grams[i]]
+		buf = binary.AppendUvarint(buf, uint64(len(list)))
+		prev := uint32(0)
+		for _, pos := range list {
+			buf = binary.AppendUvarint(buf, uint64(pos-prev))
+			prev = pos
+		}
+		return buf
+	})
+}
+
+// gramTerms returns the dictionary positions of the terms containing gram.
+func (r *SegmentReader) gramTerms(gram string) ([]uint32, error) {
+	i, err := r.kgrams.search([]byte(gram), func(entry []byte) ([]byte, bool) {
+		return entry[:min(len(entry), kgramSize)], len(entry) >= kgramSize
+	})
+	if err != nil || i == r.kgrams.numEntries {
+		return nil, err
+	}
+	entry, err := r.kgrams.entry(i)
+	if err != nil || string(entry[:kgramSize]) != gram {
+		return nil, err
+	}
+
+	data := entry[kgramSize:]
+	count, k := binary.Uvarint(data)
+	if k <= 0 || count > uint64(len(data)) {
+		return nil, NewCorruptIndexError(r.kgrams.path, "malformed entry")
+	}
+	data = data[k:]
+	list := make([]uint32, count)
+	pos := uint64(0)
+	for j := range list {
+		delta, k := binary.Uvarint(data)
+		if k <= 0 {
+			return nil, NewCorruptIndexError(r.kgrams.path, "malformed entry")
+		}
+		pos += delta
+		if pos >= uint64(r.NumTerms()) {
+			return nil, NewCorruptIndexError(r.kgrams.path, "term position out of range")
+		}
+		list[j] = uint32(pos)
+		data = data[k:]
+	}
+	return list, nil
+}
+
+// intersectSorted returns the values in both ascending lists a and b.
+func intersectSorted(a, b []uint32) []uint32 {
+	var out []uint32
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case a[0] > b[0]:
+			b = b[1:]
+		default:
+			out = append(out, a[0])
+			a, b = a[1:], b[1:]
+		}
+	}
+	return out
+}
+
+// MatchTerms calls fn with each term of the segment that matches the
+// wildcard pattern, in dictionary order, until fn returns false. Patterns
+// ending in their only "*" walk the dictionary range of their prefix;
+// others intersect the terms of their k-grams and check the candidates.
+// Only patterns without a literal k-gram or prefix scan the dictionary.
+func (r *SegmentReader) MatchTerms(pattern string, fn func(TermInfo) bool) error {
+	prefix := pattern
+	if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+		prefix = pattern[:i]
+	}
+
+	grams := patternGrams(pattern)
+	if pattern == prefix+"*" || len(grams) == 0 {
+		start, err := r.Seek(prefix)
+		if err != nil {
+			return err
+		}
+		for i := start; i < r.NumTerms(); i++ {
+			info, err := r.TermAt(i)
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(info.Term, prefix) {
+				return nil
+			}
+			if matched, _ := path.Match(pattern, info.Term); matched && !fn(info) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	lists := make([][]uint32, len(grams))
+	for i, gram := range grams {
+		var err error
+		if lists[i], err = r.gramTerms(gram); err != nil {
+			return err
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+	candidates := lists[0]
+	for _, list := range lists[1:] {
+		candidates = intersectSorted(candidates, list)
+	}
+
+	for _, pos := range candidates {
+		info, err := r.TermAt(int(pos))
+		if err != nil {
+			return err
+		}
+		if matched, _ := path.Match(pattern, info.Term); matched && !fn(info) {
+			return nil
+		}
+	}
+	return nil
+}
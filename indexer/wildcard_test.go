@@ -0,0 +1,67 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternGrams(t *testing.T) {
+	assert.Equal(t, []string{"$ap", "app", "ppl"}, patternGrams("appl*"))
+	assert.Equal(t, []string{"olo", "log", "ogy", "gy$"}, patternGrams("*ology"))
+	assert.Equal(t, []string{"$te"}, patternGrams("te?t"))
+	assert.Empty(t, patternGrams("*a*"))
+}
+
+func TestSegmentReader_MatchTerms(t *testing.T) {
+	idx := NewInvertedIndex()
+	terms := []string{
+		"apple", "applied", "application", "banana", "biology", "geology",
+		"einstein", "tent", "test", "text", "toast", "tt",
+	}
+	for _, term := range terms {
+		idx.Add(term, "doc1", NewPosting(BODY, 1))
+	}
+	idx.AddDocument("doc1", "Doc")
+
+	r, err := OpenSegmentReader(writeTestSegment(t, idx))
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"appl*", []string{"apple", "application", "applied"}},
+		{"*ology", []string{"biology", "geology"}},
+		{"te?t", []string{"tent", "test", "text"}},
+		{"?e?t", []string{"tent", "test", "text"}},
+		{"t*t", []string{"tent", "test", "text", "toast", "tt"}},
+		{"*ein*", []string{"einstein"}},
+		{"a*e", []string{"apple"}},
+		{"*an*", []string{"banana"}},
+		{"*", terms},
+		{"zz*", nil},
+		{"*xyz", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			var matched []string
+			require.NoError(t, r.MatchTerms(tt.pattern, func(info TermInfo) bool {
+				assert.Equal(t, 1, info.DocFreq)
+				matched = append(matched, info.Term)
+				return true
+			}))
+			assert.ElementsMatch(t, tt.expected, matched)
+		})
+	}
+
+	var first []string
+	require.NoError(t, r.MatchTerms("*", func(info TermInfo) bool {
+		first = append(first, info.Term)
+		return len(first) < 2
+	}))
+	assert.Equal(t, []string{"apple", "application"}, first)
+}
@@ -2,10 +2,13 @@ package indexer
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 )
 
 func NewWikiXMLParser(indexPath string) *WikiXMLParser {
@@ -22,11 +25,21 @@ func (parser *WikiXMLParser) Parse(ctx context.Context, xmlPath string) error {
 	}
 	defer func() { _ = file.Close() }()
 
+	parser.sourceName = filepath.Base(xmlPath)
 	return parser.ParseReader(ctx, file)
 }
 
+// Update parses xmlPath into a new segment of the existing index instead of
+// rebuilding it. Pages already in the index are superseded by their new
+// version.
+func (parser *WikiXMLParser) Update(ctx context.Context, xmlPath string) error {
+	parser.appendMode = true
+	return parser.Parse(ctx, xmlPath)
+}
+
 func (parser *WikiXMLParser) ParseReader(ctx context.Context, r io.Reader) error {
-	decoder := xml.NewDecoder(r)
+	hash := sha256.New()
+	decoder := xml.NewDecoder(io.TeeReader(r, hash))
 	pageCount := 0
 
 	for {
@@ -51,10 +64,12 @@ func (parser *WikiXMLParser) ParseReader(ctx context.Context, r io.Reader) error
 			}
 
 			doc := &Document{
-				ID:       xmlPage.ID,
-				Title:    xmlPage.Title,
-				Content:  xmlPage.Text,
-				Metadata: make(map[string]string),
+				ID:        xmlPage.ID,
+				Title:     xmlPage.Title,
+				Content:   xmlPage.Text,
+				Redirect:  xmlPage.Redirect.Title,
+				Timestamp: xmlPage.Timestamp,
+				Metadata:  make(map[string]string),
 			}
 
 			if err := parser.processDocument(ctx, doc); err != nil {
@@ -69,10 +84,40 @@ func (parser *WikiXMLParser) ParseReader(ctx context.Context, r io.Reader) error
 	}
 
 	writer := NewIndexWriter(parser.indexPath)
-	return writer.WriteIndex(parser.index)
+	writer.SetSource(SourceInfo{
+		Name:     parser.sourceName,
+		Checksum: "sha256:" + hex.EncodeToString(hash.Sum(nil)),
+	})
+
+	if !parser.appendMode {
+		if err := writer.WriteIndex(parser.index); err != nil {
+			return err
+		}
+	} else {
+		if err := writer.AppendIndex(parser.index); err != nil {
+			return err
+		}
+		if err := writer.WaitForMerges(); err != nil {
+			return err
+		}
+	}
+	return writer.UpdateRanks()
 }
 
 func (parser *WikiXMLParser) processDocument(ctx context.Context, doc *Document) error {
+	parser.index.AddDocument(doc.ID, doc.Title)
+	parser.index.SetFacets(doc.ID, PageFacets(doc))
+	if doc.Redirect != "" {
+		parser.index.SetRedirect(doc.ID, doc.Redirect)
+	} else {
+		text := PlainText(doc.Content)
+		parser.index.SetAbstract(doc.ID, TruncateText(text, AbstractLength))
+		parser.index.SetText(doc.ID, TruncateText(text, TextLength))
+		parser.index.SetLinks(doc.ID, LinkTargets(doc.Content))
+		parser.index.SetCategories(doc.ID, CategoryNames(doc.Content))
+		parser.index.SetAnchors(doc.ID, LinkAnchors(doc.Content))
+	}
+
 	textParser := NewWikiTextParser(doc)
 	terms := textParser.Parse()
 
@@ -84,5 +129,8 @@ func (parser *WikiXMLParser) processDocument(ctx context.Context, doc *Document)
 		}
 		parser.index.Add(term, doc.ID, posting)
 	}
+	for term, form := range textParser.Forms() {
+		parser.index.AddForm(term, form, 1)
+	}
 	return nil
 }
@@ -2,9 +2,12 @@ package indexer
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -19,4 +22,123 @@ func TestWikiXMLParser_Parse(t *testing.T) {
 	ctx := context.Background()
 	err := parser.Parse(ctx, xmlFile)
 	require.NoError(t, err)
+
+	commit, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	require.Len(t, commit.Segments, 1)
+
+	manifest, err := ReadManifest(SegmentPath(indexPath, commit.Segments[0].Name))
+	require.NoError(t, err)
+	assert.Equal(t, "test_data.xml", manifest.Source.Name)
+	assert.True(t, strings.HasPrefix(manifest.Source.Checksum, "sha256:"))
+}
+
+func TestWikiXMLParser_Update(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	ctx := context.Background()
+
+	const page = `<mediawiki><page><title>%s</title><id>%s</id><revision><text>%s</text></revision></page></mediawiki>`
+
+	parser := NewWikiXMLParser(indexPath)
+	require.NoError(t, parser.ParseReader(ctx, strings.NewReader(fmt.Sprintf(page, "Paris", "1", "capital of france"))))
+
+	updater := NewWikiXMLParser(indexPath)
+	updater.appendMode = true
+	require.NoError(t, updater.ParseReader(ctx, strings.NewReader(fmt.Sprintf(page, "Rome", "2", "capital of italy"))))
+
+	commit, err := ReadCommit(indexPath)
+	require.NoError(t, err)
+	require.Len(t, commit.Segments, 2)
+	assert.Equal(t, 1, commit.Segments[0].DocCount)
+	assert.Equal(t, 1, commit.Segments[1].DocCount)
+}
+
+func TestWikiXMLParser_Redirect(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	const dump = `<mediawiki>
+<page><title>Albert Einstein</title><id>1</id><revision><text>physicist</text></revision></page>
+<page><title>Einstein</title><id>2</id><redirect title="Albert Einstein" /><revision><text>#REDIRECT [[Albert Einstein]]</text></revision></page>
+</mediawiki>`
+	require.NoError(t, NewWikiXMLParser(indexPath).ParseReader(context.Background(), strings.NewReader(dump)))
+
+	docs, err := ReadSegmentDocs(SegmentPath(indexPath, "_0"))
+	require.NoError(t, err)
+	assert.Equal(t, "", docs["1"].Redirect)
+	assert.Equal(t, "Einstein", docs["2"].Title)
+	assert.Equal(t, "Albert Einstein", docs["2"].Redirect)
+}
+
+func TestWikiXMLParser_Anchors(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	const dump = `<mediawiki>
+<page><title>Albert Einstein</title><id>1</id><revision><text>physicist, see [[Albert Einstein|himself]]</text></revision></page>
+<page><title>Einstein</title><id>2</id><redirect title="Albert Einstein" /><revision><text>#REDIRECT [[Albert Einstein]]</text></revision></page>
+<page><title>Relativity</title><id>3</id><revision><text>theory of [[Einstein|the famous physicist]] and [[Nowhere|lost]]</text></revision></page>
+</mediawiki>`
+	parser := NewWikiXMLParser(indexPath)
+	require.NoError(t, parser.ParseReader(context.Background(), strings.NewReader(dump)))
+
+	// The anchor text lands on the article, through the redirect.
+	posting := parser.index.Index["famou"]["1"]
+	assert.Equal(t, ANCHOR, posting.Fields)
+	assert.Equal(t, 1, posting.FieldFrequency(ANCHOR))
+	assert.Equal(t, BODY|ANCHOR, parser.index.Index["physicist"]["1"].Fields)
+	assert.Equal(t, BODY, parser.index.Index["physicist"]["3"].Fields)
+	// Links from a page to itself and to missing pages add nothing.
+	assert.Equal(t, BODY, parser.index.Index["himself"]["1"].Fields)
+	assert.Equal(t, map[string]Posting{"3": NewPosting(BODY, 1)}, parser.index.Index["lost"])
+	// Anchor text does not count towards the length of the page.
+	assert.Equal(t, 7, parser.index.Docs["1"].Length)
+}
+
+func TestWikiXMLParser_UpdateAnchors(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	ctx := context.Background()
+
+	const page = `<page><title>%s</title><id>%s</id><revision><text>%s</text></revision></page>`
+	update := func(pages ...string) {
+		parser := NewWikiXMLParser(indexPath)
+		parser.appendMode = true
+		require.NoError(t, parser.ParseReader(ctx, strings.NewReader("<mediawiki>"+strings.Join(pages, "")+"</mediawiki>")))
+	}
+	// anchors loads the segment at position seg of the commit, counting
+	// from the end if negative, and returns the fields of the anchor words
+	// for the article with ID 1.
+	anchors := func(seg int, words ...string) []FieldMask {
+		commit, err := ReadCommit(indexPath)
+		require.NoError(t, err)
+		if seg < 0 {
+			seg += len(commit.Segments)
+		}
+		idx, err := loadSegment(SegmentPath(indexPath, commit.Segments[seg].Name), nil)
+		require.NoError(t, err)
+		var fields []FieldMask
+		for _, word := range words {
+			terms, _ := ParseAnchors([]string{word})
+			for term := range terms {
+				fields = append(fields, idx.Index[term]["1"].Fields&ANCHOR)
+			}
+		}
+		return fields
+	}
+
+	update(fmt.Sprintf(page, "Paris", "1", "capital"), fmt.Sprintf(page, "Seine", "2", "river in [[Paris|lutetia]]"))
+	assert.Equal(t, []FieldMask{ANCHOR}, anchors(-1, "lutetia"))
+
+	// A page of a newer segment gives anchor text to an article of an
+	// older one only once a merge rewrites both, and its new version
+	// replaces the anchors of the old one.
+	update(fmt.Sprintf(page, "Louvre", "3", "museum in [[paris|the city of light]]"), fmt.Sprintf(page, "Seine", "2", "river"))
+	assert.Equal(t, []FieldMask{0, ANCHOR}, anchors(0, "light", "lutetia"))
+	require.NoError(t, NewIndexWriter(indexPath).Compact())
+	assert.Equal(t, []FieldMask{ANCHOR, 0}, anchors(-1, "light", "lutetia"))
+
+	// A re-indexed article loses the anchors of the pages of other
+	// segments until they are merged again.
+	update(fmt.Sprintf(page, "Paris", "1", "capital of france"))
+	assert.Equal(t, []FieldMask{0}, anchors(-1, "light"))
+	require.NoError(t, NewIndexWriter(indexPath).Compact())
+	assert.Equal(t, []FieldMask{ANCHOR, 0}, anchors(-1, "light", "lutetia"))
 }
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"context"
+	"sort"
+)
+
+// intersect finds the documents containing every required term. The
+// rarest required list leads and the others are advanced to its candidates
+// with skip pointers, so the cost follows the rarest list rather than the
+// most common one. Optional terms only add to the scores of the matches.
+func (seg *segmentReader) intersect(ctx context.Context, cursors []*cursor, sc scoring, hidden []bool, top *topK) error {
+	var required, optional []*cursor
+	bound := seg.maxStaticScore(sc)
+	for _, c := range cursors {
+		if c.required {
+			required = append(required, c)
+		} else {
+			optional = append(optional, c)
+		}
+		bound += c.bound
+	}
+	sort.Slice(required, func(i, j int) bool {
+		return required[i].docFreq < required[j].docFreq
+	})
+
+	matched := make([]*cursor, 0, len(cursors))
+	lead := required[0]
+	for steps := 1; ; steps++ {
+		if steps%checkInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if bound*(1+boundSlack) < top.threshold() {
+			top.pruned = true
+			return nil
+		}
+
+		doc := lead.doc
+		for _, c := range required[1:] {
+			ok, err := c.advance(doc)
+			if !ok {
+				return err
+			}
+			if c.doc > doc {
+				doc = c.doc
+				break
+			}
+		}
+
+		if doc != lead.doc {
+			// Some list has no posting for the candidate; restart from
+			// the first document it does have.
+			ok, err := lead.advance(doc)
+			if !ok {
+				return err
+			}
+			continue
+		}
+
+		for _, c := range optional {
+			if _, err := c.advance(doc); err != nil {
+				return err
+			}
+		}
+		if !hidden[doc] {
+			top.offer(SearchResult{DocID: seg.reader.DocID(doc), Score: seg.score(sc, cursors, doc, matched)})
+		}
+
+		ok, err := lead.next()
+		if !ok {
+			return err
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+// ErrDocNotFound is returned when explaining the score of a document that
+// is not in the index.
+var ErrDocNotFound = errors.New("document not found")
+
+// Explanation breaks a score down into the values it is computed from, as
+// a tree whose root is the score itself.
+type Explanation struct {
+	Value       float64       `json:"value"`
+	Description string        `json:"description"`
+	Details     []Explanation `json:"details,omitempty"`
+}
+
+// String formats the explanation as an indented tree, one value per line.
+func (e Explanation) String() string {
+	var b strings.Builder
+	e.write(&b, 0)
+	return b.String()
+}
+
+func (e Explanation) write(b *strings.Builder, depth int) {
+	value := strconv.FormatFloat(e.Value, 'f', 4, 64)
+	if e.Value == math.Trunc(e.Value) && math.Abs(e.Value) < 1e15 {
+		value = strconv.FormatFloat(e.Value, 'f', 0, 64)
+	}
+	fmt.Fprintf(b, "%s%s = %s\n", strings.Repeat("  ", depth), value, e.Description)
+	for _, d := range e.Details {
+		d.write(b, depth+1)
+	}
+}
+
+// Explainer is implemented by scorers that can break their scores down.
+type Explainer interface {
+	// Explain returns the score of posting as Score computes it, with the
+	// values it is made of. docFreq is the one weight was computed from.
+	Explain(weight float64, docFreq int, posting indexer.Posting, docLength int, stats CollectionStats) Explanation
+}
+
+// fieldName returns the name of the field of bit i of a FieldMask.
+func fieldName(i int) string {
+	if names := indexer.FieldMask(1 << i).Names(); len(names) > 0 {
+		return names[0]
+	}
+	return "field " + strconv.Itoa(i)
+}
+
+func explainCount(value int, description string) Explanation {
+	return Explanation{Value: float64(value), Description: description}
+}
+
+func (s TFIDF) Explain(weight float64, docFreq int, posting indexer.Posting, docLength int, stats CollectionStats) Explanation {
+	freq := explainFreq(s.Boosts, posting)
+	return Explanation{
+		Value:       s.Score(weight, posting, docLength, stats),
+		Description: "tf * idf",
+		Details: []Explanation{
+			{
+				Value:       logTF(freq.Value),
+				Description: "tf = 1 + log10(freq)",
+				Details:     []Explanation{freq},
+			},
+			{
+				Value:       weight,
+				Description: "idf = log10(1 + N / df)",
+				Details:     []Explanation{explainCount(stats.DocCount, "N, documents"), explainCount(docFreq, "df, documents with the term")},
+			},
+		},
+	}
+}
+
+// explainFreq breaks down the frequency of a posting weighed by boosts.
+func explainFreq(boosts FieldBoosts, posting indexer.Posting) Explanation {
+	freq := Explanation{
+		Value:       boosts.weightedFreq(posting.Fields, &posting.FieldFreqs),
+		Description: "freq = sum of boost * freq over fields",
+	}
+	for i := range indexer.NumFields {
+		field := indexer.FieldMask(1 << i)
+		if posting.Fields&field == 0 {
+			continue
+		}
+		fieldFreq := posting.FieldFreqs[i]
+		freq.Details = append(freq.Details, Explanation{
+			Value:       boosts.boost(field) * float64(fieldFreq),
+			Description: fieldName(i),
+			Details: []Explanation{
+				{Value: boosts.boost(field), Description: "boost"},
+				explainCount(int(fieldFreq), "freq"),
+			},
+		})
+	}
+	return freq
+}
+
+func (s *BM25F) Explain(weight float64, docFreq int, posting indexer.Posting, docLength int, stats CollectionStats) Explanation {
+	tf := explainFreq(s.Boosts, posting)
+	tf.Description = "tf = sum of boost * freq over fields"
+
+	norm := Explanation{
+		Value:       1 - s.B,
+		Description: "norm = 1 - b + b * dl / avgdl",
+		Details: []Explanation{
+			{Value: s.B, Description: "b"},
+			explainCount(docLength, "dl, document length"),
+			{Value: stats.AvgDocLength, Description: "avgdl, average document length"},
+		},
+	}
+	if stats.AvgDocLength > 0 {
+		norm.Value += s.B * float64(docLength) / stats.AvgDocLength
+	}
+
+	return Explanation{
+		Value:       s.Score(weight, posting, docLength, stats),
+		Description: "idf * tf * (k1 + 1) / (tf + k1 * norm)",
+		Details: []Explanation{
+			{
+				Value:       weight,
+				Description: "idf = ln(1 + (N - df + 0.5) / (df + 0.5))",
+				Details:     []Explanation{explainCount(stats.DocCount, "N, documents"), explainCount(docFreq, "df, documents with the term")},
+			},
+			tf,
+			norm,
+			{Value: s.K1, Description: "k1"},
+		},
+	}
+}
+
+// Explain returns how the score of a document for query is computed, with
+// the default scorer and in every field. A document that does not match
+// has a score of 0 and the explanation says why.
+func (se *SearchEngine) Explain(query, docID string) (*Explanation, error) {
+	return se.ExplainRequest(SearchRequest{Query: query}, docID)
+}
+
+// ExplainRequest is Explain for the query, scorer and fields of req.
+func (se *SearchEngine) ExplainRequest(req SearchRequest, docID string) (*Explanation, error) {
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+
+	if se.closed {
+		return nil, ErrClosed
+	}
+	p, err := se.plan(req)
+	if err != nil {
+		return nil, err
+	}
+	return se.explain(p, docID)
+}
+
+// explain scores a document for p term by term, summing the terms in the
+// same order as a search so that the value is the score it ranks with. The
+// caller must hold the read lock.
+func (se *SearchEngine) explain(p *queryPlan, docID string) (*Explanation, error) {
+	i, ord, ok := se.locate(docID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDocNotFound, docID)
+	}
+	seg := se.segments[i]
+	length := seg.reader.Doc(ord).Length
+	sc := p.sc
+
+	for _, f := range p.filters {
+		ok, err := se.passes(seg, ord, f)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return &Explanation{Description: fmt.Sprintf("no match: document %s does not have %s", docID, f)}, nil
+		}
+	}
+
+	root := &Explanation{Description: fmt.Sprintf("score of document %s with %s, sum of", docID, sc.scorer.Name())}
+	if sc.fields != 0 {
+		root.Description = fmt.Sprintf("score of document %s with %s in %s, sum of",
+			docID, sc.scorer.Name(), strings.Join(sc.fields.Names(), ", "))
+	}
+	clauses := make([]Explanation, len(p.clauses))
+	for j, c := range p.clauses {
+		clauses[j].Description = "clause " + c.String()
+	}
+
+	for j, t := range p.terms {
+		if p.infos == nil {
+			break
+		}
+		info := restrictInfo(p.infos[i][j], sc.fields)
+		if info.DocFreq == 0 || info.Fields == 0 {
+			continue
+		}
+		it, err := seg.reader.Postings(info)
+		if err != nil {
+			return nil, err
+		}
+		if !it.Advance(ord) || it.Doc() != ord {
+			if err := it.Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		posting := it.Posting()
+		if sc.fields != 0 && posting.Fields&sc.fields == 0 {
+			continue
+		}
+		posting = restrictPosting(posting, sc.fields)
+
+		score := sc.scorer.Score(t.weight, posting, length, sc.stats)
+		term := Explanation{Value: score, Description: sc.scorer.Name() + " score"}
+		if explainer, ok := sc.scorer.(Explainer); ok {
+			term = explainer.Explain(t.weight, p.docFreqs[j], posting, length, sc.stats)
+		}
+		term.Description = fmt.Sprintf("term %q in %s: %s", t.term, strings.Join(posting.Fields.Names(), ", "), term.Description)
+
+		clause := &clauses[t.clause]
+		clause.Value += score
+		clause.Details = append(clause.Details, term)
+		root.Value += score
+	}
+
+	for j, c := range p.clauses {
+		if c.required && len(clauses[j].Details) == 0 {
+			return &Explanation{
+				Description: fmt.Sprintf("no match: document %s lacks required clause %s", docID, c.String()),
+			}, nil
+		}
+		if len(clauses[j].Details) > 0 {
+			root.Details = append(root.Details, clauses[j])
+		}
+	}
+	if len(root.Details) == 0 && len(p.clauses) > 0 {
+		return &Explanation{Description: fmt.Sprintf("no match: document %s contains no query term", docID)}, nil
+	}
+	if seg.prior != nil && sc.priorWeight > 0 {
+		static := se.explainPrior(sc, seg, docID, ord)
+		root.Value += static.Value
+		root.Details = append(root.Details, static)
+	}
+	return root, nil
+}
+
+// explainPrior explains the static prior of a document. The caller must
+// hold the read lock.
+func (se *SearchEngine) explainPrior(sc scoring, seg *segmentReader, docID string, ord uint32) Explanation {
+	rank := se.ranks[docID]
+	return Explanation{
+		Value:       seg.staticScore(sc, ord),
+		Description: "static prior = weight * ln(1 + pagerank) / ln(1 + max pagerank)",
+		Details: []Explanation{
+			{Value: sc.priorWeight, Description: "weight"},
+			{
+				Value:       rank.PageRank,
+				Description: "pagerank, 1 on average",
+				Details:     []Explanation{explainCount(rank.Inlinks, "inlinks, articles linking to the document")},
+			},
+			{Value: se.maxRank, Description: "max pagerank"},
+		},
+	}
+}
+
+// String formats the clause as it would be typed in a query.
+func (c clause) String() string {
+	s := c.term
+	if c.fuzzy > 0 {
+		s += "~" + strconv.Itoa(c.fuzzy)
+	}
+	if c.required {
+		s = "+" + s
+	}
+	return s
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeExplainIndex(t *testing.T) *SearchEngine {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	idx.AddDocument("1", "Paris")
+	idx.Add("pari", "1", indexer.NewPosting(indexer.TITLE, 1))
+	idx.Add("pari", "1", indexer.NewPosting(indexer.BODY, 5))
+	idx.Add("capit", "1", indexer.NewPosting(indexer.BODY, 2))
+	idx.AddDocument("2", "France")
+	idx.Add("pari", "2", indexer.NewPosting(indexer.BODY, 1))
+	idx.Add("franc", "2", indexer.NewPosting(indexer.TITLE, 1))
+	idx.Add("capit", "2", indexer.NewPosting(indexer.INFOBOX, 1))
+	idx.AddDocument("3", "Rome")
+	idx.Add("rome", "3", indexer.NewPosting(indexer.TITLE, 1))
+	writeTestIndex(t, indexPath, idx)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	t.Cleanup(se.Close)
+	return se
+}
+
+func TestSearchEngine_Explain(t *testing.T) {
+	se := writeExplainIndex(t)
+
+	requests := []SearchRequest{
+		{Query: "paris capital"},
+		{Query: "paris capital", Scorer: NewBM25F()},
+		{Query: "+paris capit*", Scorer: NewBM25F()},
+		{Query: "paris capital", Fields: indexer.BODY},
+		{Query: "pari~1 capital", Scorer: NewBM25F(), Fields: indexer.TITLE | indexer.INFOBOX},
+	}
+	for _, req := range requests {
+		req.Limit, req.Explain = 10, true
+		resp, err := se.Execute(context.Background(), req)
+		require.NoError(t, err)
+		require.NotEmpty(t, resp.Results, req.Query)
+		for _, result := range resp.Results {
+			require.NotNil(t, result.Explanation)
+			assert.InDelta(t, result.Score, result.Explanation.Value, 1e-12, "%s: %s", req.Query, result.DocID)
+
+			explanation, err := se.ExplainRequest(req, result.DocID)
+			require.NoError(t, err)
+			assert.Equal(t, result.Explanation, explanation)
+		}
+	}
+
+	explanation, err := se.Explain("paris", "1")
+	require.NoError(t, err)
+	require.Len(t, explanation.Details, 1)
+	term := explanation.Details[0].Details[0]
+	assert.Equal(t, `term "pari" in body, title: tf * idf`, term.Description)
+	require.Len(t, term.Details, 2)
+	freq := term.Details[0].Details[0]
+	assert.Equal(t, 8.0, freq.Value, "body freq plus three times the title freq")
+	require.Len(t, freq.Details, 2)
+	assert.Equal(t, 3.0, freq.Details[1].Details[0].Value, "title boost")
+	assert.Equal(t, 2.0, term.Details[1].Details[1].Value, "df")
+}
+
+func TestSearchEngine_ExplainNoMatch(t *testing.T) {
+	se := writeExplainIndex(t)
+
+	explanation, err := se.Explain("+france +capital", "1")
+	require.NoError(t, err)
+	assert.Zero(t, explanation.Value)
+	assert.Equal(t, "no match: document 1 lacks required clause +franc", explanation.Description)
+
+	explanation, err = se.Explain("rome", "1")
+	require.NoError(t, err)
+	assert.Zero(t, explanation.Value)
+	assert.Contains(t, explanation.Description, "no match")
+
+	explanation, err = se.ExplainRequest(SearchRequest{Query: "capital", Fields: indexer.TITLE}, "2")
+	require.NoError(t, err)
+	assert.Zero(t, explanation.Value)
+
+	_, err = se.Explain("paris", "4")
+	assert.ErrorIs(t, err, ErrDocNotFound)
+	_, err = se.Explain("the", "1")
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestExplanation_String(t *testing.T) {
+	e := Explanation{Value: 1.5, Description: "sum of", Details: []Explanation{
+		{Value: 1, Description: "one"},
+		{Value: 0.5, Description: "half", Details: []Explanation{{Value: 2, Description: "two"}}},
+	}}
+	assert.Equal(t, "1.5000 = sum of\n  1 = one\n  0.5000 = half\n    2 = two\n", e.String())
+}
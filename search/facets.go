@@ -0,0 +1,349 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+// MaxCategoryDepth is how many levels of subcategories a category filter
+// can take in.
+const MaxCategoryDepth = 5
+
+// filterRegex matches the filters of a query, such as
+// incategory:"Nobel laureates in Physics"~2, infobox:person,
+// namespace:Category or year:2024.
+var filterRegex = regexp.MustCompile(`(?i)\b(incategory|infobox|namespace|year):("[^"]*"?|[^\s"~]+)(~[0-9]*)?`)
+
+// filterFacets maps the operators of filters to the facets they filter on.
+var filterFacets = map[string]string{
+	"incategory": indexer.FacetCategory,
+	"infobox":    indexer.FacetInfobox,
+	"namespace":  indexer.FacetNamespace,
+	"year":       indexer.FacetYear,
+}
+
+// filter restricts the results to the documents with a value of a facet.
+// A category filter can also take in the subcategories of the category,
+// up to depth levels down.
+type filter struct {
+	facet string
+	value string
+	depth int
+}
+
+// splitFilters takes the filters out of query. A category filter followed
+// by "~N" also takes in the subcategories N levels down, or
+// MaxCategoryDepth levels for a "~" alone. An infobox filter names the
+// type with or without its "infobox" prefix.
+func splitFilters(query string) (string, []filter) {
+	var filters []filter
+	rest := filterRegex.ReplaceAllStringFunc(query, func(match string) string {
+		m := filterRegex.FindStringSubmatch(match)
+		f := filter{facet: filterFacets[strings.ToLower(m[1])], value: strings.Trim(m[2], `"`)}
+		if depth, ok := strings.CutPrefix(m[3], "~"); ok && f.facet == indexer.FacetCategory {
+			f.depth = MaxCategoryDepth
+			if n, err := strconv.Atoi(depth); err == nil {
+				f.depth = min(n, MaxCategoryDepth)
+			}
+		}
+		if key := indexer.TitleKey(f.value); f.facet == indexer.FacetInfobox && key != "" && !strings.HasPrefix(key, "infobox") {
+			f.value = "infobox " + f.value
+		}
+		if indexer.TitleKey(f.value) != "" {
+			filters = append(filters, f)
+		}
+		return " "
+	})
+	return rest, filters
+}
+
+// values returns the values of the facet of f a document may have to pass
+// it. The caller must hold the read lock.
+func (se *SearchEngine) values(f filter) []string {
+	if f.facet == indexer.FacetCategory {
+		return se.categories.Subcategories(f.value, f.depth)
+	}
+	return []string{f.value}
+}
+
+// hidden returns the documents of the segment a query with filters must
+// not return: the dead ones and those failing any filter. The caller must
+// hold the read lock.
+func (se *SearchEngine) hidden(seg *segmentReader, filters []filter) ([]bool, error) {
+	if len(filters) == 0 {
+		return seg.dead, nil
+	}
+	hidden := make([]bool, len(seg.dead))
+	copy(hidden, seg.dead)
+	in := make([]bool, len(seg.dead))
+	for _, f := range filters {
+		clear(in)
+		for _, value := range se.values(f) {
+			members, err := seg.reader.FacetMembers(f.facet, value)
+			if err != nil {
+				return nil, err
+			}
+			for _, ord := range members {
+				in[ord] = true
+			}
+		}
+		for ord := range hidden {
+			hidden[ord] = hidden[ord] || !in[ord]
+		}
+	}
+	return hidden, nil
+}
+
+// passes reports whether the document with ordinal ord in seg passes
+// filter f. The caller must hold the read lock.
+func (se *SearchEngine) passes(seg *segmentReader, ord uint32, f filter) (bool, error) {
+	values, err := seg.reader.Facets(ord)
+	if err != nil {
+		return false, err
+	}
+	keys := make(map[string]bool)
+	for _, value := range se.values(f) {
+		keys[indexer.TitleKey(value)] = true
+	}
+	for _, v := range values {
+		if v.Facet == f.facet && keys[indexer.TitleKey(v.Value)] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// String describes the filter.
+func (f filter) String() string {
+	s := fmt.Sprintf("%s %q", f.facet, f.value)
+	if f.depth > 0 {
+		s += fmt.Sprintf(" or its subcategories down to depth %d", f.depth)
+	}
+	return s
+}
+
+// browse adds the documents of the segment that are not hidden to top,
+// scored by their static prior alone, for a query made only of filters.
+func (seg *segmentReader) browse(ctx context.Context, sc scoring, hidden []bool, top *topK) error {
+	for ord, h := range hidden {
+		if ord%checkInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !h {
+			top.offer(SearchResult{DocID: seg.reader.DocID(uint32(ord)), Score: seg.staticScore(sc, uint32(ord))})
+		}
+	}
+	return nil
+}
+
+// Facet is a facet of the matches of a query with its most frequent
+// values, most frequent first.
+type Facet struct {
+	Name   string       `json:"name"`
+	Values []FacetCount `json:"values"`
+}
+
+// FacetCount is a value of a facet and the number of matches having it.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// facetCounts counts the facet values of the matches of a query in every
+// segment, by their positions in the dictionary of the segment, so that
+// the values are only decoded once per segment.
+type facetCounts struct {
+	// counts holds, for the segment at each position, the count of every
+	// value of its dictionary.
+	counts    [][]uint32
+	positions []uint32
+}
+
+func newFacetCounts(segments []*segmentReader) *facetCounts {
+	fc := &facetCounts{counts: make([][]uint32, len(segments))}
+	for i, seg := range segments {
+		fc.counts[i] = make([]uint32, seg.reader.NumFacetValues())
+	}
+	return fc
+}
+
+// add counts the values of the document with ordinal doc in the i-th
+// segment.
+func (fc *facetCounts) add(i int, seg *segmentReader, doc uint32) error {
+	var err error
+	if fc.positions, err = seg.reader.DocFacets(doc, fc.positions[:0]); err != nil {
+		return err
+	}
+	counts := fc.counts[i]
+	for _, pos := range fc.positions {
+		counts[pos]++
+	}
+	return nil
+}
+
+// countFacets counts the facet values of the matches of p in the i-th
+// segment that are not hidden. The postings are walked again apart from
+// the search, without scoring, so that the search still skips the
+// documents that cannot reach the page.
+func (seg *segmentReader) countFacets(ctx context.Context, p *queryPlan, i int, hidden []bool, fc *facetCounts) error {
+	visit := func(doc uint32) error {
+		if hidden[doc] {
+			return nil
+		}
+		return fc.add(i, seg, doc)
+	}
+	if len(p.clauses) == 0 {
+		for ord := range hidden {
+			if ord%checkInterval == 0 && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := visit(uint32(ord)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cursors, conjunctive, err := seg.cursors(p.terms, p.infos[i], p.sc)
+	if err != nil || cursors == nil {
+		return err
+	}
+	if conjunctive {
+		return matchAll(ctx, cursors, visit)
+	}
+	return matchAny(ctx, cursors, visit)
+}
+
+// matchAll calls visit with the documents containing every required
+// clause of cursors, in order, leapfrogging from the rarest list like
+// intersect.
+func matchAll(ctx context.Context, cursors []*cursor, visit func(doc uint32) error) error {
+	var required []*cursor
+	for _, c := range cursors {
+		if c.required {
+			required = append(required, c)
+		}
+	}
+	sort.Slice(required, func(i, j int) bool {
+		return required[i].docFreq < required[j].docFreq
+	})
+
+	lead := required[0]
+	for steps := 1; ; steps++ {
+		if steps%checkInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		doc := lead.doc
+		for _, c := range required[1:] {
+			ok, err := c.advance(doc)
+			if !ok {
+				return err
+			}
+			if c.doc > doc {
+				doc = c.doc
+				break
+			}
+		}
+		if doc != lead.doc {
+			ok, err := lead.advance(doc)
+			if !ok {
+				return err
+			}
+			continue
+		}
+
+		if err := visit(doc); err != nil {
+			return err
+		}
+		ok, err := lead.next()
+		if !ok {
+			return err
+		}
+	}
+}
+
+// matchAny calls visit with the documents containing any clause of
+// cursors, in order.
+func matchAny(ctx context.Context, cursors []*cursor, visit func(doc uint32) error) error {
+	for steps := 1; len(cursors) > 0; steps++ {
+		if steps%checkInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		doc := uint32(noMoreDocs)
+		for _, c := range cursors {
+			doc = min(doc, c.doc)
+		}
+		if err := visit(doc); err != nil {
+			return err
+		}
+		for _, c := range cursors {
+			if c.doc == doc {
+				if _, err := c.next(); err != nil {
+					return err
+				}
+			}
+		}
+		cursors = liveCursors(cursors)
+	}
+	return nil
+}
+
+// facets sums the counts of segments, in order, and returns the limit
+// most frequent values of every facet. A value is shown as the oldest
+// segment having it writes it.
+func (fc *facetCounts) facets(segments []*segmentReader, limit int) ([]Facet, error) {
+	type entry struct {
+		key string
+		FacetCount
+	}
+	totals := make(map[string]map[string]*entry)
+	for i, seg := range segments {
+		for pos, count := range fc.counts[i] {
+			if count == 0 {
+				continue
+			}
+			v, err := seg.reader.FacetValue(uint32(pos))
+			if err != nil {
+				return nil, err
+			}
+			if totals[v.Facet] == nil {
+				totals[v.Facet] = make(map[string]*entry)
+			}
+			key := indexer.TitleKey(v.Value)
+			e := totals[v.Facet][key]
+			if e == nil {
+				e = &entry{key: key, FacetCount: FacetCount{Value: v.Value}}
+				totals[v.Facet][key] = e
+			}
+			e.Count += int(count)
+		}
+	}
+
+	var facets []Facet
+	for _, name := range indexer.FacetNames() {
+		entries := make([]*entry, 0, len(totals[name]))
+		for _, e := range totals[name] {
+			entries = append(entries, e)
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Count != entries[j].Count {
+				return entries[i].Count > entries[j].Count
+			}
+			return entries[i].key < entries[j].key
+		})
+		facet := Facet{Name: name, Values: []FacetCount{}}
+		for _, e := range entries[:min(limit, len(entries))] {
+			facet.Values = append(facet.Values, e.FacetCount)
+		}
+		facets = append(facets, facet)
+	}
+	return facets, nil
+}
@@ -0,0 +1,195 @@
+package search
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitFilters(t *testing.T) {
+	category := func(name string, depth int) filter {
+		return filter{facet: indexer.FacetCategory, value: name, depth: depth}
+	}
+	tests := []struct {
+		query    string
+		rest     string
+		expected []filter
+	}{
+		{"einstein", "einstein", nil},
+		{`relativity incategory:"Nobel laureates in Physics"`, "relativity  ", []filter{category("Nobel laureates in Physics", 0)}},
+		{"incategory:Physicists~2 theory", "  theory", []filter{category("Physicists", 2)}},
+		{"InCategory:Physicists~ incategory:Swiss_people~9", "   ", []filter{
+			category("Physicists", MaxCategoryDepth),
+			category("Swiss_people", MaxCategoryDepth),
+		}},
+		{`incategory:"" incategory:"open`, "   ", []filter{category("open", 0)}},
+		{"physicist infobox:person", "physicist  ", []filter{{facet: indexer.FacetInfobox, value: "infobox person"}}},
+		{`infobox:"Infobox scientist"~2`, " ", []filter{{facet: indexer.FacetInfobox, value: "Infobox scientist"}}},
+		{"Namespace:Category year:2024 atom", "    atom", []filter{
+			{facet: indexer.FacetNamespace, value: "Category"},
+			{facet: indexer.FacetYear, value: "2024"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rest, filters := splitFilters(tt.query)
+			assert.Equal(t, tt.rest, rest)
+			assert.Equal(t, tt.expected, filters)
+		})
+	}
+}
+
+func TestSearchEngine_CategoryFilter(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	addPage := func(docID, title string, categories ...string) {
+		idx.AddDocument(docID, title)
+		idx.Add("theori", docID, indexer.NewPosting(indexer.BODY, len(docID)))
+		idx.SetCategories(docID, categories)
+	}
+	addPage("1", "Albert Einstein", "Nobel laureates in Physics", "Swiss people")
+	addPage("2", "Marie Curie", "Chemists", "Nobel laureates in Physics")
+	addPage("3", "Max Planck", "Physicists")
+	addPage("4", "Category:Nobel laureates in Physics", "Physicists")
+	addPage("5", "Category:Physicists", "Scientists")
+	addPage("6", "Category:Chemists", "Scientists")
+	writer := indexer.NewIndexWriter(indexPath)
+	require.NoError(t, writer.WriteIndex(idx))
+
+	// Categories survive merges.
+	extra := indexer.NewInvertedIndex()
+	extra.AddDocument("7", "Niels Bohr")
+	extra.Add("atom", "7", indexer.NewPosting(indexer.BODY, 1))
+	extra.SetCategories("7", []string{"Nobel laureates in Physics"})
+	require.NoError(t, writer.AppendIndex(extra))
+	require.NoError(t, writer.Compact())
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	ids := func(query string) []string {
+		t.Helper()
+		results, err := se.Search(query, 10)
+		require.NoError(t, err)
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.DocID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"1", "2"}, ids(`theory incategory:"Nobel laureates in Physics"`))
+	assert.Equal(t, []string{"1", "2"}, ids(`theory incategory:nobel_laureates_in_physics~0`))
+	assert.Equal(t, []string{"1", "2", "3", "4"}, ids("theory incategory:Physicists~1"), "category pages are members too")
+	assert.Equal(t, []string{"1"}, ids(`theory incategory:Physicists~ incategory:"Swiss people"`), "filters are combined")
+	assert.Equal(t, []string{"2"}, ids("+theory incategory:Chemists"))
+	assert.Empty(t, ids("theory incategory:Astronomers~"))
+
+	// A query of filters alone lists the members of the categories.
+	assert.ElementsMatch(t, []string{"1", "2", "7"}, ids(`incategory:"Nobel laureates in Physics"`))
+	resp, err := se.Execute(t.Context(), SearchRequest{Query: "incategory:Scientists~2", Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 7, resp.TotalHits)
+	assert.Len(t, resp.Results, 2)
+
+	explanation, err := se.Explain("theory incategory:Physicists~1", "2")
+	require.NoError(t, err)
+	assert.Greater(t, explanation.Value, 0.0)
+	explanation, err = se.Explain("theory incategory:Physicists", "2")
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, explanation.Value)
+	assert.Contains(t, explanation.Description, `does not have category "Physicists"`)
+
+	suggestion, ok, err := se.DidYouMean("theory incategory:Physicsts")
+	require.NoError(t, err)
+	assert.False(t, ok, "category names are not corrected: %s", suggestion)
+
+	categories, found, err := se.Categories("7")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"Nobel laureates in Physics"}, categories)
+}
+
+func TestSearchEngine_Facets(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	const dump = `<mediawiki>
+<page><title>Albert Einstein</title><id>1</id><revision><timestamp>2024-03-01T10:00:00Z</timestamp><text>{{Infobox scientist | name = Albert Einstein}} a theory of relativity [[Category:Physicists]] [[Category:Nobel laureates in Physics]]</text></revision></page>
+<page><title>Marie Curie</title><id>2</id><revision><timestamp>2023-05-01T10:00:00Z</timestamp><text>{{infobox_scientist}} a theory of radioactivity [[Category:Chemists]] [[Category:Nobel laureates in Physics]]</text></revision></page>
+<page><title>Max Planck</title><id>3</id><revision><timestamp>2024-07-01T10:00:00Z</timestamp><text>{{Infobox person}} the quantum theory [[Category:Physicists]]</text></revision></page>
+<page><title>Category:Physicists</title><id>4</id><revision><timestamp>2024-01-01T10:00:00Z</timestamp><text>physicists with a theory [[Category:Scientists]]</text></revision></page>
+</mediawiki>`
+	require.NoError(t, indexer.NewWikiXMLParser(indexPath).ParseReader(t.Context(), strings.NewReader(dump)))
+
+	// Counts add up across segments.
+	extra := indexer.NewInvertedIndex()
+	extra.AddDocument("5", "Niels Bohr")
+	extra.Add("theori", "5", indexer.NewPosting(indexer.BODY, 1))
+	extra.SetCategories("5", []string{"physicists"})
+	extra.SetFacets("5", []indexer.FacetValue{{Facet: indexer.FacetNamespace, Value: indexer.MainNamespace}})
+	require.NoError(t, indexer.NewIndexWriter(indexPath).AppendIndex(extra))
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	resp, err := se.Execute(t.Context(), SearchRequest{Query: "theory", Limit: 1, FacetLimit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 5, resp.TotalHits)
+	assert.False(t, resp.TotalIsLowerBound)
+	assert.Equal(t, []Facet{
+		{Name: indexer.FacetCategory, Values: []FacetCount{{"Physicists", 3}, {"Nobel laureates in Physics", 2}}},
+		{Name: indexer.FacetInfobox, Values: []FacetCount{{"infobox scientist", 2}, {"infobox person", 1}}},
+		{Name: indexer.FacetNamespace, Values: []FacetCount{{"Main", 4}, {"Category", 1}}},
+		{Name: indexer.FacetYear, Values: []FacetCount{{"2024", 3}, {"2023", 1}}},
+	}, resp.Facets)
+
+	// Drilling down filters the matches and their counts.
+	ids := func(query string) []string {
+		t.Helper()
+		results, err := se.Search(query, 10)
+		require.NoError(t, err)
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.DocID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"3"}, ids("theory infobox:person"))
+	assert.ElementsMatch(t, []string{"1", "2"}, ids(`theory infobox:"Infobox_scientist"`))
+	assert.Equal(t, []string{"4"}, ids("theory namespace:category"))
+	assert.ElementsMatch(t, []string{"1", "3", "4"}, ids("year:2024"))
+	assert.Empty(t, ids("theory year:1999"))
+
+	resp, err = se.Execute(t.Context(), SearchRequest{Query: "theory year:2024", Limit: 10, FacetLimit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.TotalHits)
+	assert.Equal(t, []FacetCount{{"2024", 3}}, resp.Facets[3].Values)
+	assert.Equal(t, []FacetCount{{"Physicists", 2}}, resp.Facets[0].Values)
+
+	// Facets count every match even where the search skips documents
+	// that cannot reach the page.
+	resp, err = se.Execute(t.Context(), SearchRequest{Query: "theory quantum", Limit: 1, TotalHitsThreshold: 1, FacetLimit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3"}, []string{resp.Results[0].DocID})
+	assert.True(t, resp.TotalIsLowerBound)
+	assert.Equal(t, []FacetCount{{"Main", 4}, {"Category", 1}}, resp.Facets[2].Values)
+	resp, err = se.Execute(t.Context(), SearchRequest{Query: "+theory +nobel", Limit: 1, TotalHitsThreshold: 1, FacetLimit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []FacetCount{{"Nobel laureates in Physics", 2}, {"Chemists", 1}}, resp.Facets[0].Values)
+
+	resp, err = se.Execute(t.Context(), SearchRequest{Query: "theory", Limit: 10})
+	require.NoError(t, err)
+	assert.Nil(t, resp.Facets, "facets are only counted on request")
+
+	_, err = se.Execute(t.Context(), SearchRequest{Query: "theory", FacetLimit: -1})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	explanation, err := se.Explain("theory infobox:person", "1")
+	require.NoError(t, err)
+	assert.Contains(t, explanation.Description, `does not have infobox "infobox person"`)
+}
@@ -0,0 +1,167 @@
+package search
+
+import (
+	"path"
+	"strings"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+// SnippetLength is the number of characters of a snippet, besides the
+// marks showing that the text goes on.
+const SnippetLength = indexer.AbstractLength
+
+// snippetLead is how many characters a snippet shows before its first
+// match.
+const snippetLead = 40
+
+// Span is a range of a text, from Start up to but excluding End, counted
+// in characters (Unicode code points) rather than bytes so that it can be
+// applied to the text in any encoding.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Highlight returns the spans of the words of text that match a term of
+// query, in order. Words are analyzed as the indexer does, so "Running"
+// matches the query "runs"; wildcard and fuzzy words match the words their
+// pattern or edit distance admits.
+func (se *SearchEngine) Highlight(query, text string) []Span {
+	clauses := se.parseClauses(query)
+	if len(clauses) == 0 {
+		return nil
+	}
+
+	stemmer := indexer.NewStemmer()
+	defer stemmer.Release()
+
+	matches := func(word string) bool {
+		word = strings.ToLower(word)
+		if indexer.IsStopWord(word) {
+			return false
+		}
+		term := stemmer.Stem(word)
+		for _, c := range clauses {
+			switch {
+			case c.wildcard:
+				if matched, _ := path.Match(c.term, term); matched {
+					return true
+				}
+			case c.fuzzy > 0:
+				if indexer.WithinEdits(c.term, term, c.fuzzy) {
+					return true
+				}
+			case c.term == term:
+				return true
+			}
+		}
+		return false
+	}
+
+	var spans []Span
+	wordStart, start, chars := -1, 0, 0
+	for i, r := range text + " " {
+		if lower := r | 0x20; 'a' <= lower && lower <= 'z' {
+			if wordStart < 0 {
+				wordStart, start = i, chars
+			}
+		} else if wordStart >= 0 {
+			if matches(text[wordStart:i]) {
+				spans = append(spans, Span{Start: start, End: chars})
+			}
+			wordStart = -1
+		}
+		chars++
+	}
+	return spans
+}
+
+// Snippet returns the passage of the stored text of the live version of a
+// document holding the most distinct words that match query, with the
+// spans of those words in it. A passage is cut at word boundaries and
+// marked with "…" where the text goes on. It is the opening of the page
+// when no word matches, and the abstract for pages without stored text.
+func (se *SearchEngine) Snippet(query, docID string) (string, []Span, bool, error) {
+	se.mutex.RLock()
+	i, ord, ok := se.locate(docID)
+	var text string
+	var err error
+	if ok {
+		if text, err = se.segments[i].reader.Text(ord); err == nil && text == "" {
+			text, err = se.segments[i].reader.Abstract(ord)
+		}
+	}
+	se.mutex.RUnlock()
+	if !ok || err != nil {
+		return "", nil, false, err
+	}
+
+	snippet, spans := passage([]rune(text), se.Highlight(query, text))
+	return snippet, spans, true, nil
+}
+
+// passage picks the SnippetLength characters of text around the spans
+// that hold the most distinct words, then the most words, and returns
+// them with the spans they hold, moved to their place in the passage.
+func passage(text []rune, spans []Span) (string, []Span) {
+	if len(text) <= SnippetLength {
+		return string(text), spans
+	}
+
+	windowStart := func(first Span) int {
+		return max(first.Start-snippetLead, 0)
+	}
+	best, bestWords, bestCount := -1, 0, 0
+	for i, first := range spans {
+		end := windowStart(first) + SnippetLength
+		words := make(map[string]bool)
+		count := 0
+		for _, s := range spans[i:] {
+			if s.End > end {
+				break
+			}
+			words[strings.ToLower(string(text[s.Start:s.End]))] = true
+			count++
+		}
+		if len(words) > bestWords || (len(words) == bestWords && count > bestCount) {
+			best, bestWords, bestCount = i, len(words), count
+		}
+	}
+
+	start := 0
+	if best >= 0 {
+		// Start at a word, but not after the first match.
+		start = windowStart(spans[best])
+		for start > 0 && start < spans[best].Start && text[start-1] != ' ' {
+			start++
+		}
+	}
+	end := min(start+SnippetLength, len(text))
+	if end < len(text) {
+		for cut := end; cut > start; cut-- {
+			if text[cut] == ' ' {
+				end = cut
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	offset := 0
+	if start > 0 {
+		b.WriteString("… ")
+		offset = 2
+	}
+	b.WriteString(string(text[start:end]))
+	if end < len(text) {
+		b.WriteString(" …")
+	}
+	var moved []Span
+	for _, s := range spans {
+		if s.Start >= start && s.End <= end {
+			moved = append(moved, Span{Start: s.Start - start + offset, End: s.End - start + offset})
+		}
+	}
+	return b.String(), moved
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEngine_Highlight(t *testing.T) {
+	se := NewSearchEngine(t.TempDir())
+
+	tests := []struct {
+		query    string
+		text     string
+		expected []Span
+	}{
+		{"running", "Runs and RUNNING, ran.", []Span{{0, 4}, {9, 16}}},
+		{"the capital", "Paris is the capital of France.", []Span{{13, 20}}},
+		{"+paris AND france", "Paris, France", []Span{{0, 5}, {7, 13}}},
+		{"einst*", "Albert Einstein's einsteinium", []Span{{7, 15}, {18, 29}}},
+		{"einstien~", "Einstein and Eisenstein", []Span{{0, 8}}},
+		{"zürich", "Café Zürich", []Span{{7, 11}}},
+		{"cafe", "Café Zürich", nil},
+		{"the", "The end", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.expected, se.Highlight(tt.query, tt.text))
+		})
+	}
+}
+
+func TestPassage(t *testing.T) {
+	short := []rune("Paris is the capital of France.")
+	snippet, spans := passage(short, []Span{{0, 5}})
+	assert.Equal(t, string(short), snippet)
+	assert.Equal(t, []Span{{0, 5}}, spans)
+
+	filler := strings.Repeat("word ", 100)
+	text := []rune(filler + "Seine here, " + filler + "Paris on the Seine. " + filler)
+	seine := strings.Index(string(text), "Seine")
+	paris := strings.Index(string(text), "Paris")
+	second := strings.LastIndex(string(text), "Seine")
+	snippet, spans = passage(text, []Span{{seine, seine + 5}, {paris, paris + 5}, {second, second + 5}})
+
+	assert.True(t, strings.HasPrefix(snippet, "… word "), snippet)
+	assert.True(t, strings.HasSuffix(snippet, " word …"), snippet)
+	assert.LessOrEqual(t, utf8.RuneCountInString(snippet), SnippetLength+4)
+	require.Len(t, spans, 2, "the window holding both words wins")
+	chars := []rune(snippet)
+	assert.Equal(t, "Paris", string(chars[spans[0].Start:spans[0].End]))
+	assert.Equal(t, "Seine", string(chars[spans[1].Start:spans[1].End]))
+
+	snippet, spans = passage(text, nil)
+	assert.True(t, strings.HasPrefix(snippet, "word word"), snippet)
+	assert.True(t, strings.HasSuffix(snippet, " …"), snippet)
+	assert.Empty(t, spans)
+}
+
+func TestSearchEngine_Snippet(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	idx.AddDocument("1", "Paris")
+	idx.SetAbstract("1", "Paris is the capital of France.")
+	idx.SetText("1", "Paris is the capital of France. "+strings.Repeat("It is large. ", 50)+"The Seine flows through Paris.")
+	idx.AddDocument("2", "Lyon")
+	idx.SetAbstract("2", "Lyon is a city.")
+	writeTestIndex(t, indexPath, idx)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	snippet, spans, ok, err := se.Snippet("seine", "1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.True(t, strings.HasSuffix(snippet, "The Seine flows through Paris."), snippet)
+	require.Len(t, spans, 1)
+	assert.Equal(t, "Seine", string([]rune(snippet)[spans[0].Start:spans[0].End]))
+
+	snippet, spans, ok, err = se.Snippet("river", "1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(snippet, "Paris is the capital"), snippet)
+	assert.Empty(t, spans)
+
+	snippet, spans, ok, err = se.Snippet("city", "2")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Lyon is a city.", snippet, "the abstract stands in for pages without text")
+	assert.Equal(t, []Span{{10, 14}}, spans)
+
+	_, _, ok, err = se.Snippet("city", "3")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -1,6 +1,138 @@
 package search
 
+import (
+	"time"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+// SearchRequest asks for a page of the results of Query: Limit results
+// after skipping the Offset best ones.
+type SearchRequest struct {
+	Query  string
+	Offset int
+	Limit  int
+	// Scorer ranks the results instead of the engine's scorer if set.
+	Scorer Scorer
+	// Fields restricts matching and scoring to the occurrences of the
+	// query terms in these fields if set. Term weights still count the
+	// documents containing a term in any field.
+	Fields indexer.FieldMask
+	// TotalHitsThreshold is how many matches are counted exactly before
+	// documents that cannot reach the page are skipped, after which the
+	// total is a lower bound if any was. Zero means
+	// DefaultTotalHitsThreshold, and math.MaxInt counts every match.
+	TotalHitsThreshold int
+	// Explain attaches to each result the breakdown of its score.
+	Explain bool
+	// FacetLimit, if set, asks for the FacetLimit most frequent values of
+	// every facet among all the matches. They are counted in a pass of
+	// their own, which visits every match without scoring it.
+	FacetLimit int
+}
+
+// SearchResponse is a page of results. TotalHits is the number of
+// matching documents, or a lower bound on it if TotalIsLowerBound is set.
+type SearchResponse struct {
+	Results           []SearchResult
+	TotalHits         int
+	TotalIsLowerBound bool
+	// Facets counts the values of every facet among the matches, in the
+	// order of indexer.FacetNames, if the request asked for them.
+	Facets []Facet
+	Took   time.Duration
+}
+
 type SearchResult struct {
 	DocID string
 	Score float64
+	// Explanation breaks the score down if the request asked for it.
+	Explanation *Explanation
+}
+
+// Suggestion is a title that completes a prefix. Article is the title of
+// the page it leads to, which differs from Title when Title is a redirect,
+// and DocID identifies that page; it is empty if the page is not indexed.
+type Suggestion struct {
+	Title      string
+	Article    string
+	DocID      string
+	Popularity int
+}
+
+// JSONResponse is the JSON form of a page of results, shared by the
+// responses of wikifind serve and the JSON output of wikifind search.
+type JSONResponse struct {
+	Query             string       `json:"query"`
+	Offset            int          `json:"offset"`
+	Limit             int          `json:"limit"`
+	TotalHits         int          `json:"total_hits"`
+	TotalIsLowerBound bool         `json:"total_is_lower_bound"`
+	TookMillis        float64      `json:"took_ms"`
+	Results           []JSONResult `json:"results"`
+	// Facets counts the facet values of the matches when the request asks
+	// for them.
+	Facets []Facet `json:"facets,omitempty"`
+}
+
+// JSONResult is the JSON form of a result, with its title and snippet.
+type JSONResult struct {
+	DocID      string     `json:"doc_id"`
+	Title      string     `json:"title"`
+	Score      float64    `json:"score"`
+	Snippet    string     `json:"snippet"`
+	Highlights Highlights `json:"highlights"`
+	// Explanation breaks the score down when the request sets explain.
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// Highlights locates the query words in the title and snippet of a result
+// as [start, end) character offsets.
+type Highlights struct {
+	Title   [][2]int `json:"title"`
+	Snippet [][2]int `json:"snippet"`
+}
+
+// JSON returns the JSON form of resp, the response of the engine to req,
+// looking up the title and snippet of every result.
+func (se *SearchEngine) JSON(req SearchRequest, resp *SearchResponse) (*JSONResponse, error) {
+	out := &JSONResponse{
+		Query:             req.Query,
+		Offset:            req.Offset,
+		Limit:             req.Limit,
+		TotalHits:         resp.TotalHits,
+		TotalIsLowerBound: resp.TotalIsLowerBound,
+		TookMillis:        float64(resp.Took.Microseconds()) / 1000,
+		Results:           make([]JSONResult, 0, len(resp.Results)),
+		Facets:            resp.Facets,
+	}
+	for _, result := range resp.Results {
+		doc, _ := se.Doc(result.DocID)
+		snippet, spans, _, err := se.Snippet(req.Query, result.DocID)
+		if err != nil {
+			return nil, err
+		}
+		out.Results = append(out.Results, JSONResult{
+			DocID:   result.DocID,
+			Title:   doc.Title,
+			Score:   result.Score,
+			Snippet: snippet,
+			Highlights: Highlights{
+				Title:   pairs(se.Highlight(req.Query, doc.Title)),
+				Snippet: pairs(spans),
+			},
+			Explanation: result.Explanation,
+		})
+	}
+	return out, nil
+}
+
+// pairs converts spans to pairs, never returning nil so that they are
+// encoded as an empty list.
+func pairs(spans []Span) [][2]int {
+	out := make([][2]int, 0, len(spans))
+	for _, span := range spans {
+		out = append(out, [2]int{span.Start, span.End})
+	}
+	return out
 }
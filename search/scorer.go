@@ -0,0 +1,169 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+// CollectionStats describes the documents a query is scored against.
+type CollectionStats struct {
+	DocCount     int
+	AvgDocLength float64
+}
+
+// Scorer ranks the documents matching a query. A document's score is the
+// sum of the scores of its postings for the query terms.
+type Scorer interface {
+	// Name identifies the scorer, e.g. in the REPL.
+	Name() string
+	// Weight returns the weight of a term that docFreq documents contain,
+	// such as its inverse document frequency.
+	Weight(docFreq int, stats CollectionStats) float64
+	// Score returns what posting contributes to the score of a document
+	// of docLength tokens.
+	Score(weight float64, posting indexer.Posting, docLength int, stats CollectionStats) float64
+	// MaxScore bounds Score for every posting summarized by info, so that
+	// documents which cannot make the top results are skipped.
+	MaxScore(weight float64, info indexer.TermInfo, stats CollectionStats) float64
+}
+
+// NewScorer returns a new scorer of Scorers by name, ignoring case; "bm25"
+// is accepted for "bm25f".
+func NewScorer(name string) (Scorer, error) {
+	name = strings.ToLower(name)
+	if name == "bm25" {
+		name = "bm25f"
+	}
+	newScorer, ok := Scorers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scorer %q", name)
+	}
+	return newScorer(), nil
+}
+
+// FieldBoosts weighs the occurrences of a term by the field they are in,
+// for both scorers. Fields it does not list weigh 1.
+type FieldBoosts map[indexer.FieldMask]float64
+
+// DefaultBoosts returns boosts favouring the title, anchor text,
+// categories and infobox over the body.
+func DefaultBoosts() FieldBoosts {
+	return FieldBoosts{
+		indexer.TITLE:    3.0,
+		indexer.CATEGORY: 1.5,
+		indexer.INFOBOX:  1.5,
+		indexer.BODY:     1.0,
+		indexer.LINKS:    0.5,
+		indexer.GEOBOX:   0.5,
+		indexer.ANCHOR:   2.0,
+	}
+}
+
+// boost returns the weight of field.
+func (b FieldBoosts) boost(field indexer.FieldMask) float64 {
+	if boost, ok := b[field]; ok {
+		return boost
+	}
+	return 1.0
+}
+
+// weightedFreq sums the field frequencies times their boosts.
+func (b FieldBoosts) weightedFreq(fields indexer.FieldMask, freqs *[indexer.NumFields]uint32) float64 {
+	tf := 0.0
+	for i := range indexer.NumFields {
+		if field := indexer.FieldMask(1 << i); fields&field != 0 {
+			tf += b.boost(field) * float64(freqs[i])
+		}
+	}
+	return tf
+}
+
+// TFIDF scores a posting by its log-scaled term frequency, with each field
+// weighed by its boost, times the term's inverse document frequency.
+type TFIDF struct {
+	Boosts FieldBoosts
+}
+
+// NewTFIDF returns a TF-IDF scorer with the default boosts.
+func NewTFIDF() TFIDF {
+	return TFIDF{Boosts: DefaultBoosts()}
+}
+
+func (TFIDF) Name() string {
+	return "tfidf"
+}
+
+func (TFIDF) Weight(docFreq int, stats CollectionStats) float64 {
+	return math.Log10(1 + float64(stats.DocCount)/float64(docFreq))
+}
+
+// logTF scales a weighted term frequency, never below 0 for the rare
+// postings whose boosts add up to less than a tenth.
+func logTF(tf float64) float64 {
+	if tf <= 0 {
+		return 0
+	}
+	return max(1+math.Log10(tf), 0)
+}
+
+func (s TFIDF) Score(weight float64, posting indexer.Posting, _ int, _ CollectionStats) float64 {
+	return logTF(s.Boosts.weightedFreq(posting.Fields, &posting.FieldFreqs)) * weight
+}
+
+// MaxScore takes the highest frequency of every field at once.
+func (s TFIDF) MaxScore(weight float64, info indexer.TermInfo, _ CollectionStats) float64 {
+	return logTF(s.Boosts.weightedFreq(info.Fields, &info.MaxFieldFreqs)) * weight
+}
+
+// BM25F scores with BM25 over a term frequency that weighs each field by
+// its boost, so that a title hit can count for more than a body hit. The
+// frequency saturates with K1 and is normalized by document length with B.
+type BM25F struct {
+	K1     float64
+	B      float64
+	Boosts FieldBoosts
+}
+
+// NewBM25F returns a BM25F scorer with the usual parameters and the
+// default boosts.
+func NewBM25F() *BM25F {
+	return &BM25F{K1: 1.2, B: 0.75, Boosts: DefaultBoosts()}
+}
+
+func (s *BM25F) Name() string {
+	return "bm25f"
+}
+
+func (s *BM25F) Weight(docFreq int, stats CollectionStats) float64 {
+	// Document frequencies still count deleted documents, so keep the
+	// weight from turning negative when most of a term's documents are.
+	n, df := float64(max(stats.DocCount, docFreq)), float64(docFreq)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func (s *BM25F) saturate(weight, tf, norm float64) float64 {
+	return weight * tf * (s.K1 + 1) / (tf + s.K1*norm)
+}
+
+func (s *BM25F) Score(weight float64, posting indexer.Posting, docLength int, stats CollectionStats) float64 {
+	norm := 1 - s.B
+	if stats.AvgDocLength > 0 {
+		norm += s.B * float64(docLength) / stats.AvgDocLength
+	}
+	return s.saturate(weight, s.Boosts.weightedFreq(posting.Fields, &posting.FieldFreqs), norm)
+}
+
+// MaxScore takes the highest frequency of every field at once and the
+// length normalization of an empty document, which no posting exceeds.
+func (s *BM25F) MaxScore(weight float64, info indexer.TermInfo, _ CollectionStats) float64 {
+	return s.saturate(weight, s.Boosts.weightedFreq(info.Fields, &info.MaxFieldFreqs), 1-s.B)
+}
+
+// Scorers lists the available scorers by name.
+var Scorers = map[string]func() Scorer{
+	"tfidf": func() Scorer { return NewTFIDF() },
+	"bm25f": func() Scorer { return NewBM25F() },
+}
@@ -0,0 +1,111 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/stretchr/testify/assert"
+)
+
+// combine merges single field postings of the same term and document.
+func combine(postings ...indexer.Posting) indexer.Posting {
+	var p indexer.Posting
+	for _, q := range postings {
+		p.Fields |= q.Fields
+		p.Frequency += q.Frequency
+		for i, freq := range q.FieldFreqs {
+			p.FieldFreqs[i] += freq
+		}
+	}
+	return p
+}
+
+func TestBM25F_FieldWeights(t *testing.T) {
+	scorer := NewBM25F()
+	stats := CollectionStats{DocCount: 1000, AvgDocLength: 100}
+	weight := scorer.Weight(10, stats)
+
+	title := scorer.Score(weight, indexer.NewPosting(indexer.TITLE, 1), 100, stats)
+	body := scorer.Score(weight, indexer.NewPosting(indexer.BODY, 1), 100, stats)
+	assert.Greater(t, title, body)
+	anchor := scorer.Score(weight, indexer.NewPosting(indexer.ANCHOR, 1), 100, stats)
+	assert.Greater(t, anchor, body)
+
+	// Body hits saturate: fifty of them are not worth fifty times one.
+	many := scorer.Score(weight, indexer.NewPosting(indexer.BODY, 50), 100, stats)
+	assert.Less(t, many, 5*body)
+
+	// A title hit adds to body hits instead of being lost in the sum.
+	both := scorer.Score(weight, combine(indexer.NewPosting(indexer.TITLE, 1), indexer.NewPosting(indexer.BODY, 50)), 100, stats)
+	assert.Greater(t, both, many)
+
+	// Longer documents score lower for the same frequencies.
+	long := scorer.Score(weight, indexer.NewPosting(indexer.BODY, 1), 1000, stats)
+	assert.Less(t, long, body)
+
+	// Rarer terms weigh more, and the weight stays positive when deleted
+	// documents push the document frequency above the document count.
+	assert.Greater(t, scorer.Weight(1, stats), weight)
+	assert.GreaterOrEqual(t, scorer.Weight(2000, stats), 0.0)
+}
+
+func TestTFIDF_FieldWeights(t *testing.T) {
+	scorer := NewTFIDF()
+	stats := CollectionStats{DocCount: 1000}
+	weight := scorer.Weight(10, stats)
+
+	title := scorer.Score(weight, indexer.NewPosting(indexer.TITLE, 1), 0, stats)
+	anchor := scorer.Score(weight, indexer.NewPosting(indexer.ANCHOR, 1), 0, stats)
+	body := scorer.Score(weight, indexer.NewPosting(indexer.BODY, 1), 0, stats)
+	links := scorer.Score(weight, indexer.NewPosting(indexer.LINKS, 1), 0, stats)
+	assert.Greater(t, title, anchor)
+	assert.Greater(t, anchor, body)
+	assert.Greater(t, body, links)
+	assert.Positive(t, links)
+
+	// Without boosts every field weighs the same.
+	assert.Equal(t, TFIDF{}.Score(weight, indexer.NewPosting(indexer.ANCHOR, 1), 0, stats),
+		TFIDF{}.Score(weight, indexer.NewPosting(indexer.BODY, 1), 0, stats))
+	assert.Zero(t, TFIDF{Boosts: FieldBoosts{indexer.BODY: 0}}.Score(weight, indexer.NewPosting(indexer.BODY, 1), 0, stats))
+}
+
+func TestScorers_MaxScore(t *testing.T) {
+	postings := []indexer.Posting{
+		indexer.NewPosting(indexer.BODY, 7),
+		indexer.NewPosting(indexer.TITLE, 2),
+		combine(indexer.NewPosting(indexer.TITLE, 1), indexer.NewPosting(indexer.INFOBOX, 3)),
+	}
+	info := indexer.TermInfo{DocFreq: len(postings)}
+	for _, p := range postings {
+		info.Fields |= p.Fields
+		info.MaxFrequency = max(info.MaxFrequency, p.Frequency)
+		for i, freq := range p.FieldFreqs {
+			info.MaxFieldFreqs[i] = max(info.MaxFieldFreqs[i], freq)
+		}
+	}
+
+	stats := CollectionStats{DocCount: 100, AvgDocLength: 20}
+	for name, newScorer := range Scorers {
+		scorer := newScorer()
+		assert.Equal(t, name, scorer.Name())
+
+		weight := scorer.Weight(info.DocFreq, stats)
+		bound := scorer.MaxScore(weight, info, stats)
+		for _, p := range postings {
+			for _, length := range []int{0, 1, 20, 500} {
+				assert.LessOrEqual(t, scorer.Score(weight, p, length, stats), bound, "%s %v length %d", name, p, length)
+			}
+		}
+	}
+}
+
+func TestNewScorer(t *testing.T) {
+	for name, expected := range map[string]string{"tfidf": "tfidf", "BM25": "bm25f", "bm25f": "bm25f"} {
+		scorer, err := NewScorer(name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, expected, scorer.Name())
+		}
+	}
+	_, err := NewScorer("pagerank")
+	assert.Error(t, err)
+}
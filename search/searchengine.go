@@ -1,181 +1,678 @@
 package search
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"math"
-	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/PhantomInTheWire/wikifind/indexer"
 )
 
+// SearchEngine answers queries against an index. It is safe for
+// concurrent use: segments are read with positional reads or through
+// read-only mappings, and Close waits for in-flight searches to finish.
 type SearchEngine struct {
 	indexPath string
-	indexes   map[rune]*os.File
-	mutex     sync.RWMutex
+	// segments are ordered oldest first, as listed in the commit point.
+	segments []*segmentReader
+	stats    CollectionStats
+	scorer   Scorer
+	// ranks holds the standing of the articles in the link graph and
+	// maxRank the highest PageRank among the live ones.
+	ranks       map[string]indexer.DocRank
+	maxRank     float64
+	priorWeight float64
+	// categories links the categories of the index to their subcategories.
+	categories *indexer.CategoryGraph
+	// maxExpansions caps the number of terms a wildcard expands to.
+	maxExpansions int
+	// closed is set by Close until the next Initialize.
+	closed bool
+	mutex  sync.RWMutex
 }
 
+// DefaultMaxExpansions is the number of terms a wildcard expands to unless
+// changed with SetMaxExpansions.
+const DefaultMaxExpansions = 64
+
+// DefaultPriorWeight is the most the static prior adds to a score unless
+// changed with SetPriorWeight.
+const DefaultPriorWeight = 1.0
+
 func NewSearchEngine(indexPath string) *SearchEngine {
 	return &SearchEngine{
-		indexPath: indexPath,
-		indexes:   make(map[rune]*os.File),
+		indexPath:     indexPath,
+		scorer:        NewTFIDF(),
+		maxExpansions: DefaultMaxExpansions,
+		priorWeight:   DefaultPriorWeight,
 	}
 }
 
+// SetMaxExpansions caps the number of terms a wildcard expands to; the
+// terms found in most documents are kept.
+func (se *SearchEngine) SetMaxExpansions(n int) {
+	se.mutex.Lock()
+	defer se.mutex.Unlock()
+	se.maxExpansions = n
+}
+
+// SetPriorWeight changes how much the static prior of a document, taken
+// from its PageRank in the link graph, adds to its score: weight for the
+// article with the highest PageRank and less, on a log scale, for the
+// others. A weight of 0, or less, ranks by the query alone.
+func (se *SearchEngine) SetPriorWeight(weight float64) {
+	se.mutex.Lock()
+	defer se.mutex.Unlock()
+	se.priorWeight = max(weight, 0)
+}
+
+// SetScorer changes how later searches rank documents.
+func (se *SearchEngine) SetScorer(scorer Scorer) {
+	se.mutex.Lock()
+	defer se.mutex.Unlock()
+	se.scorer = scorer
+}
+
+// Scorer returns the scorer searches rank documents with.
+func (se *SearchEngine) Scorer() Scorer {
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+	return se.scorer
+}
+
 func (se *SearchEngine) Initialize() error {
-	for char := 'a'; char <= 'z'; char++ {
-		filename := filepath.Join(se.indexPath, fmt.Sprintf("index%c.idx", char))
-		file, err := os.Open(filename)
+	commit, err := indexer.ReadCommit(se.indexPath)
+	if err != nil {
+		return err
+	}
+
+	var segments []*segmentReader
+	for _, info := range commit.Segments {
+		seg, err := openSegment(se.indexPath, info)
 		if err != nil {
+			closeSegments(segments)
 			return err
 		}
-		se.indexes[char] = file
+		segments = append(segments, seg)
+	}
+
+	// A document counts once, and only if its newest version is not
+	// deleted; older versions are hidden like deleted documents.
+	docCount, totalLength := 0, 0
+	seen := make(map[string]bool)
+	for i := len(segments) - 1; i >= 0; i-- {
+		seg := segments[i]
+		for ord := range seg.dead {
+			docID := seg.reader.DocID(uint32(ord))
+			if seen[docID] {
+				seg.dead[ord] = true
+				continue
+			}
+			seen[docID] = true
+			if !seg.dead[ord] {
+				docCount++
+				totalLength += seg.reader.Doc(uint32(ord)).Length
+			}
+		}
+	}
+
+	stats := CollectionStats{DocCount: docCount}
+	if docCount > 0 {
+		stats.AvgDocLength = float64(totalLength) / float64(docCount)
+	}
+
+	ranks, err := indexer.ReadRanks(se.indexPath, commit)
+	if err != nil {
+		closeSegments(segments)
+		return err
 	}
+	maxRank := setPriors(segments, ranks)
+
+	categories := indexer.NewCategoryGraph()
+	for _, seg := range segments {
+		if err := seg.reader.AddCategoryPages(categories, seg.isDead); err != nil {
+			closeSegments(segments)
+			return err
+		}
+	}
+
+	se.mutex.Lock()
+	old := se.segments
+	se.segments, se.stats = segments, stats
+	se.ranks, se.maxRank = ranks, maxRank
+	se.categories = categories
+	se.closed = false
+	se.mutex.Unlock()
+	closeSegments(old)
 	return nil
 }
 
+// setPriors gives the live documents of segments their static priors and
+// returns the highest PageRank among them.
+func setPriors(segments []*segmentReader, ranks map[string]indexer.DocRank) float64 {
+	maxRank := 0.0
+	for _, seg := range segments {
+		for ord, dead := range seg.dead {
+			if !dead {
+				maxRank = max(maxRank, ranks[seg.reader.DocID(uint32(ord))].PageRank)
+			}
+		}
+	}
+	if maxRank == 0 {
+		return 0
+	}
+	for _, seg := range segments {
+		seg.prior = make([]float64, len(seg.dead))
+		for ord, dead := range seg.dead {
+			if !dead {
+				seg.prior[ord] = prior(ranks[seg.reader.DocID(uint32(ord))].PageRank, maxRank)
+				seg.maxPrior = max(seg.maxPrior, seg.prior[ord])
+			}
+		}
+	}
+	return maxRank
+}
+
+// prior maps a PageRank to [0, 1] on a log scale, so that the articles
+// most linked to stand out without drowning out the query.
+func prior(pageRank, maxRank float64) float64 {
+	return math.Log1p(pageRank) / math.Log1p(maxRank)
+}
+
+// Close releases the index files. It waits for searches in progress, and
+// later searches fail with ErrClosed until the engine is initialized
+// again.
 func (se *SearchEngine) Close() {
-	for _, file := range se.indexes {
-		_ = file.Close()
+	se.mutex.Lock()
+	defer se.mutex.Unlock()
+
+	closeSegments(se.segments)
+	se.segments, se.stats = nil, CollectionStats{}
+	se.ranks, se.maxRank = nil, 0
+	se.categories = nil
+	se.closed = true
+}
+
+func closeSegments(segments []*segmentReader) {
+	for _, seg := range segments {
+		seg.close()
 	}
 }
 
+// DocCount returns the number of distinct documents in the index.
+func (se *SearchEngine) DocCount() int {
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+	return se.stats.DocCount
+}
+
+// ErrInvalidRequest is wrapped by the errors of requests that cannot be
+// run, such as queries without a searchable term.
+var ErrInvalidRequest = errors.New("invalid search request")
+
+// ErrClosed is returned by searches on an engine that was closed.
+var ErrClosed = errors.New("search engine is closed")
+
+// DefaultTotalHitsThreshold is how many matches a search counts exactly
+// unless its request sets another threshold.
+const DefaultTotalHitsThreshold = 1000
+
+// Search returns the limit best matching documents for query.
 func (se *SearchEngine) Search(query string, limit int) ([]SearchResult, error) {
-	terms := se.parseQuery(query)
-	if len(terms) == 0 {
-		return nil, fmt.Errorf("no valid terms in query")
+	resp, err := se.Execute(context.Background(), SearchRequest{Query: query, Limit: max(limit, 0)})
+	if err != nil {
+		return nil, err
 	}
+	return resp.Results, nil
+}
 
-	docScores := make(map[string]float64)
-
-	for _, term := range terms {
-		postings, err := se.getPostings(term)
-		if err != nil {
-			continue
-		}
+// Execute returns the page of results req asks for. Rather than scoring
+// every posting it keeps the best Offset+Limit results in a bounded heap
+// and, once enough matches are counted, skips documents that cannot beat
+// the worst of them, using the score bounds stored with each term. Only
+// the requested page is sorted, so deep pages cost little more than the
+// heap they are taken from.
+func (se *SearchEngine) Execute(ctx context.Context, req SearchRequest) (*SearchResponse, error) {
+	start := time.Now()
+	if req.Offset < 0 || req.Limit < 0 || req.TotalHitsThreshold < 0 || req.FacetLimit < 0 {
+		return nil, fmt.Errorf("%w: negative offset, limit, total hits threshold or facet limit", ErrInvalidRequest)
+	}
+	top := &topK{limit: req.Offset + req.Limit, countUpTo: req.TotalHitsThreshold}
+	if top.limit < 0 {
+		top.limit = math.MaxInt
+	}
+	if top.countUpTo == 0 {
+		top.countUpTo = DefaultTotalHitsThreshold
+	}
 
-		idf := math.Log10(14128976.0 / float64(len(postings)))
+	// Hold the read lock for the whole query so that Close cannot unmap
+	// the segments while their postings are being decoded.
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
 
-		for docID, termObj := range postings {
-			tf := 1.0 + math.Log10(float64(termObj.Frequency))
-			score := tf * idf
+	if se.closed {
+		return nil, ErrClosed
+	}
+	var facets *facetCounts
+	if req.FacetLimit > 0 {
+		facets = newFacetCounts(se.segments)
+	}
 
-			// Boost title matches
-			if termObj.Fields&indexer.TITLE != 0 {
-				score *= 2.0
+	p, err := se.plan(req)
+	if err != nil {
+		return nil, err
+	}
+	if !p.empty {
+		for i, seg := range se.segments {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			hidden, err := se.hidden(seg, p.filters)
+			if err != nil {
+				return nil, err
+			}
+			if len(p.clauses) == 0 {
+				err = seg.browse(ctx, p.sc, hidden, top)
+			} else {
+				err = seg.search(ctx, p.terms, p.infos[i], p.sc, hidden, top)
 			}
+			if err != nil {
+				return nil, err
+			}
+			if facets != nil {
+				if err := seg.countFacets(ctx, p, i, hidden, facets); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
 
-			docScores[docID] += score
+	resp := &SearchResponse{
+		Results:           top.page(req.Offset),
+		TotalHits:         top.total,
+		TotalIsLowerBound: !top.exact(),
+	}
+	if facets != nil {
+		if resp.Facets, err = facets.facets(se.segments, req.FacetLimit); err != nil {
+			return nil, err
+		}
+	}
+	if req.Explain {
+		for i := range resp.Results {
+			if resp.Results[i].Explanation, err = se.explain(p, resp.Results[i].DocID); err != nil {
+				return nil, err
+			}
 		}
 	}
+	resp.Took = time.Since(start)
+	return resp, nil
+}
 
-	type docScore struct {
-		docID string
-		score float64
+// queryPlan is a query ready to run: its clauses, the terms they expand to
+// with their weights across the index, the dictionary entries of the
+// terms in each segment and the filters of the results. A query
+// of filters alone has no clauses.
+type queryPlan struct {
+	clauses  []clause
+	filters  []filter
+	terms    []queryTerm
+	infos    [][]indexer.TermInfo
+	docFreqs []int
+	sc       scoring
+	// empty is set when a required clause matches no term, so that no
+	// document can match.
+	empty bool
+}
+
+// plan parses the query of req and looks up its terms. The caller must
+// hold the read lock.
+func (se *SearchEngine) plan(req SearchRequest) (*queryPlan, error) {
+	_, filters := splitFilters(req.Query)
+	clauses := se.parseClauses(req.Query)
+	if len(clauses) == 0 && len(filters) == 0 {
+		return nil, fmt.Errorf("%w: no valid terms in query", ErrInvalidRequest)
 	}
 
-	var results []docScore
-	for docID, score := range docScores {
-		results = append(results, docScore{docID, score})
+	scorer := se.scorer
+	if req.Scorer != nil {
+		scorer = req.Scorer
+	}
+	p := &queryPlan{
+		clauses: clauses,
+		filters: filters,
+		sc:      scoring{scorer: scorer, stats: se.stats, fields: req.Fields, priorWeight: se.priorWeight},
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].score > results[j].score
-	})
+	for i, clause := range clauses {
+		words := []string{clause.term}
+		if clause.wildcard || clause.fuzzy > 0 {
+			var err error
+			if words, err = se.expand(clause); err != nil {
+				return nil, err
+			}
+		}
+		for _, word := range words {
+			p.terms = append(p.terms, queryTerm{term: word, clause: i, required: clause.required})
+		}
+		if len(words) == 0 && clause.required {
+			p.empty = true
+			return p, nil
+		}
+	}
 
-	var searchResults []SearchResult
-	for i, result := range results {
-		if i >= limit {
-			break
+	// Like Lucene, document frequencies are taken from the dictionaries
+	// and still count deleted and superseded postings until they are
+	// merged away, which saves reading the posting lists to count them.
+	words := make([]string, len(p.terms))
+	for i, term := range p.terms {
+		words[i] = term.term
+	}
+	p.infos = make([][]indexer.TermInfo, len(se.segments))
+	p.docFreqs = make([]int, len(words))
+	for i, seg := range se.segments {
+		var err error
+		if p.infos[i], err = seg.lookup(words); err != nil {
+			return nil, err
+		}
+		for j, info := range p.infos[i] {
+			p.docFreqs[j] += info.DocFreq
 		}
-		searchResults = append(searchResults, SearchResult{
-			DocID: result.docID,
-			Score: result.score,
-		})
 	}
 
-	return searchResults, nil
+	for i := range p.terms {
+		if p.docFreqs[i] > 0 {
+			p.terms[i].weight = scorer.Weight(p.docFreqs[i], se.stats)
+		}
+	}
+	return p, nil
 }
 
 func (se *SearchEngine) parseQuery(query string) []string {
+	var terms []string
+	for _, clause := range se.parseClauses(query) {
+		terms = append(terms, clause.term)
+	}
+	return terms
+}
+
+// clause is a query term and whether matching documents must contain it.
+// A wildcard clause holds a pattern that matches any of several terms, and
+// a fuzzy clause matches the terms within fuzzy edits of its term.
+type clause struct {
+	term     string
+	required bool
+	wildcard bool
+	fuzzy    int
+}
+
+// parseClauses splits query into stemmed terms, leaving out its filters. A
+// term is required when it is prefixed with "+" or joined to a neighbour
+// with "AND"; a query with required terms only returns documents that
+// contain all of them. Words with "*" or "?" are wildcard patterns, matched
+// against the indexed terms as they are. A word followed by "~" also
+// matches terms up to two edits away, or "~1" one edit away.
+func (se *SearchEngine) parseClauses(query string) []clause {
 	stemmer := indexer.NewStemmer()
 	defer stemmer.Release()
 
-	wordRegex := regexp.MustCompile(`[a-z]+`)
-	words := wordRegex.FindAllString(strings.ToLower(query), -1)
+	wordRegex := regexp.MustCompile(`[a-z*?]+(~[0-9]*)?`)
 
-	var terms []string
-	for _, word := range words {
-		if len(word) > 1 && !indexer.IsStopWord(word) {
-			terms = append(terms, stemmer.Stem(word))
+	query, _ = splitFilters(query)
+	var clauses []clause
+	and := false
+	for _, token := range strings.Fields(query) {
+		if token == "AND" {
+			if n := len(clauses); n > 0 {
+				clauses[n-1].required = true
+			}
+			and = true
+			continue
+		}
+
+		required := and || strings.HasPrefix(token, "+")
+		and = false
+		for _, word := range wordRegex.FindAllString(strings.ToLower(token), -1) {
+			word, edits, fuzzy := strings.Cut(word, "~")
+			if indexer.IsWildcard(word) {
+				if strings.Trim(word, "*?") != "" {
+					clauses = append(clauses, clause{term: word, required: required, wildcard: true})
+				}
+				continue
+			}
+			if len(word) > 1 && !indexer.IsStopWord(word) {
+				c := clause{term: stemmer.Stem(word), required: required}
+				if fuzzy {
+					c.fuzzy = indexer.MaxEdits
+					if n, err := strconv.Atoi(edits); err == nil {
+						c.fuzzy = min(n, indexer.MaxEdits)
+					}
+				}
+				clauses = append(clauses, c)
+			}
 		}
 	}
 
-	return terms
+	return clauses
 }
 
-func (se *SearchEngine) getPostings(term string) (map[string]indexer.Posting, error) {
-	if len(term) == 0 {
-		return nil, fmt.Errorf("empty term")
+// termMatch is a term of the index matching a wildcard or fuzzy clause.
+type termMatch struct {
+	form     string
+	docFreq  int
+	distance int
+}
+
+// matchTerms finds the terms of the index matching a wildcard or fuzzy
+// clause, summing their document frequencies over the segments. The caller
+// must hold the read lock.
+func (se *SearchEngine) matchTerms(c clause) (map[string]*termMatch, error) {
+	matches := make(map[string]*termMatch)
+	add := func(info indexer.TermInfo, distance int) bool {
+		m := matches[info.Term]
+		if m == nil {
+			m = &termMatch{distance: distance}
+			matches[info.Term] = m
+		}
+		if info.DocFreq > m.docFreq {
+			m.form = info.Form
+		}
+		m.docFreq += info.DocFreq
+		return true
 	}
 
-	char := rune(term[0])
-	if char < 'a' || char > 'z' {
-		return nil, fmt.Errorf("invalid term")
+	for _, seg := range se.segments {
+		var err error
+		if c.fuzzy > 0 {
+			err = seg.reader.FuzzyTerms(c.term, c.fuzzy, add)
+		} else {
+			err = seg.reader.MatchTerms(c.term, func(info indexer.TermInfo) bool {
+				return add(info, 0)
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
+	return matches, nil
+}
 
-	se.mutex.RLock()
-	file := se.indexes[char]
-	se.mutex.RUnlock()
+// expand returns the terms of the index matching a wildcard or fuzzy
+// clause in term order. It keeps the maxExpansions closest to a fuzzy term
+// and, among those, the ones found in most documents. The caller must hold
+// the read lock.
+func (se *SearchEngine) expand(c clause) ([]string, error) {
+	matches, err := se.matchTerms(c)
+	if err != nil {
+		return nil, err
+	}
 
-	if file == nil {
-		return nil, fmt.Errorf("index file not found")
+	terms := make([]string, 0, len(matches))
+	for term := range matches {
+		terms = append(terms, term)
 	}
+	if len(terms) > se.maxExpansions {
+		sort.Slice(terms, func(i, j int) bool {
+			a, b := matches[terms[i]], matches[terms[j]]
+			if a.distance != b.distance {
+				return a.distance < b.distance
+			}
+			if a.docFreq != b.docFreq {
+				return a.docFreq > b.docFreq
+			}
+			return terms[i] < terms[j]
+		})
+		terms = terms[:max(se.maxExpansions, 0)]
+	}
+	sort.Strings(terms)
+	return terms, nil
+}
+
+// suggestRatio is how many times more documents a similar term must be in
+// for DidYouMean to prefer it to a query word the index does contain.
+const suggestRatio = 10
+
+// DidYouMean suggests a spelling correction for query. Each plain word
+// that is missing from the index, or much rarer than a term a typo or two
+// away from it, is replaced with the surface form of the most common of
+// the closest such terms. Words of up to five letters may be one edit
+// away, longer ones two, and words of one or two letters are kept. It
+// reports false if no word needs correcting.
+func (se *SearchEngine) DidYouMean(query string) (string, bool, error) {
+	stemmer := indexer.NewStemmer()
+	defer stemmer.Release()
 
-	_, _ = file.Seek(0, 0)
-	scanner := bufio.NewScanner(file)
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+
+	wordRegex := regexp.MustCompile(`[A-Za-z]+`)
+
+	filters := filterRegex.FindAllStringIndex(query, -1)
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, ":")
-		if len(parts) < 2 {
+	var b strings.Builder
+	changed := false
+	last := 0
+	for _, loc := range wordRegex.FindAllStringIndex(query, -1) {
+		word := strings.ToLower(query[loc[0]:loc[1]])
+		if len(word) <= 2 || indexer.IsStopWord(word) || !plainWord(query, loc) || inSpans(filters, loc) {
 			continue
 		}
+		edits := indexer.MaxEdits
+		if len(word) <= 5 {
+			edits = 1
+		}
 
-		if parts[0] == term {
-			postings := make(map[string]indexer.Posting)
-			for i := 1; i < len(parts); i++ {
-				posting := parts[i]
-				dollarIdx := strings.Index(posting, "$")
-				if dollarIdx == -1 {
-					continue
-				}
+		stem := stemmer.Stem(word)
+		matches, err := se.matchTerms(clause{term: stem, fuzzy: edits})
+		if err != nil {
+			return "", false, err
+		}
 
-				docID := posting[:dollarIdx]
-				termData := posting[dollarIdx+1:]
+		docFreq := 0
+		if m := matches[stem]; m != nil {
+			docFreq = m.docFreq
+		}
+		best := ""
+		for term, m := range matches {
+			if term == stem || m.docFreq < max(docFreq*suggestRatio, 1) {
+				continue
+			}
+			if best == "" || betterCorrection(term, m, best, matches[best]) {
+				best = term
+			}
+		}
+		if best == "" {
+			continue
+		}
 
-				termParts := strings.Split(termData, "$")
-				if len(termParts) != 2 {
-					continue
-				}
+		correction := matches[best].form
+		if r := query[loc[0]]; r >= 'A' && r <= 'Z' {
+			correction = strings.ToUpper(correction[:1]) + correction[1:]
+		}
+		b.WriteString(query[last:loc[0]])
+		b.WriteString(correction)
+		last = loc[1]
+		changed = true
+	}
+	if !changed {
+		return "", false, nil
+	}
+	b.WriteString(query[last:])
+	return b.String(), true, nil
+}
 
-				fields, _ := strconv.Atoi(termParts[0])
-				freq, _ := strconv.Atoi(termParts[1])
+// plainWord reports whether the word of query at loc is an ordinary query
+// word rather than the "AND" operator or part of a wildcard or fuzzy term.
+func plainWord(query string, loc []int) bool {
+	if query[loc[0]:loc[1]] == "AND" {
+		return false
+	}
+	if loc[0] > 0 && strings.ContainsAny(query[loc[0]-1:loc[0]], "*?") {
+		return false
+	}
+	return loc[1] == len(query) || !strings.ContainsAny(query[loc[1]:loc[1]+1], "*?~")
+}
 
-				postings[docID] = indexer.Posting{
-					Fields:    indexer.FieldMask(fields),
-					Frequency: freq,
-				}
-			}
-			return postings, nil
+// inSpans reports whether the word at loc lies in one of spans, such as
+// the filters of a query, which hold names rather than words.
+func inSpans(spans [][]int, loc []int) bool {
+	for _, span := range spans {
+		if loc[0] >= span[0] && loc[1] <= span[1] {
+			return true
 		}
 	}
+	return false
+}
+
+// betterCorrection reports whether term a is a better correction than b:
+// closer to the query word, or as close and in more documents.
+func betterCorrection(a string, am *termMatch, b string, bm *termMatch) bool {
+	if am.distance != bm.distance {
+		return am.distance < bm.distance
+	}
+	if am.docFreq != bm.docFreq {
+		return am.docFreq > bm.docFreq
+	}
+	return a < b
+}
+
+func (se *SearchEngine) getPostings(term string) (map[string]indexer.Posting, error) {
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+	return se.lookupPostings(term)
+}
 
-	return make(map[string]indexer.Posting), nil
+// lookupPostings merges the live postings of term across segments. The
+// caller must hold the read lock.
+func (se *SearchEngine) lookupPostings(term string) (map[string]indexer.Posting, error) {
+	if len(term) == 0 {
+		return nil, fmt.Errorf("empty term")
+	}
+
+	char := rune(term[0])
+	if char < 'a' || char > 'z' {
+		return nil, fmt.Errorf("invalid term")
+	}
+
+	if len(se.segments) == 0 {
+		return nil, fmt.Errorf("index not initialized")
+	}
+
+	postings := make(map[string]indexer.Posting)
+	for _, seg := range se.segments {
+		segPostings, err := seg.postings(term)
+		if err != nil {
+			return nil, err
+		}
+		for docID, posting := range segPostings {
+			postings[docID] = posting
+		}
+	}
+	return postings, nil
 }
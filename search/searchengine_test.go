@@ -1,9 +1,16 @@
 package search
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
 
 	"github.com/PhantomInTheWire/wikifind/indexer"
@@ -33,31 +40,120 @@ func TestSearchEngine_parseQuery(t *testing.T) {
 	}
 }
 
+func TestSearchEngine_parseClauses(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected []clause
+	}{
+		{"optional", "apple red", []clause{{term: "appl"}, {term: "red"}}},
+		{"plus", "+apple red", []clause{{term: "appl", required: true}, {term: "red"}}},
+		{"and", "apple AND red pie", []clause{{term: "appl", required: true}, {term: "red", required: true}, {term: "pie"}}},
+		{"chained and", "apple AND red AND pie", []clause{{term: "appl", required: true}, {term: "red", required: true}, {term: "pie", required: true}}},
+		{"lowercase and is a stop word", "apple and red", []clause{{term: "appl"}, {term: "red"}}},
+		{"leading and", "AND apple", []clause{{term: "appl", required: true}}},
+		{"plus stop word", "+the apple", []clause{{term: "appl"}}},
+		{"prefix", "einst* red", []clause{{term: "einst*", wildcard: true}, {term: "red"}}},
+		{"suffix and single", "+*ology te?t", []clause{{term: "*ology", required: true, wildcard: true}, {term: "te?t", wildcard: true}}},
+		{"wildcards only", "* ?? apple", []clause{{term: "appl"}}},
+		{"fuzzy", "einstien~ +red~1", []clause{{term: "einstien", fuzzy: 2}, {term: "red", required: true, fuzzy: 1}}},
+		{"fuzzy capped", "apple~5 pie~0", []clause{{term: "appl", fuzzy: 2}, {term: "pie"}}},
+		{"fuzzy stop word", "the~ apple", []clause{{term: "appl"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			se := &SearchEngine{}
+			assert.Equal(t, tt.expected, se.parseClauses(tt.query))
+		})
+	}
+}
+
+func writeTestIndex(t *testing.T, indexPath string, idx *indexer.InvertedIndex) string {
+	require.NoError(t, indexer.NewIndexWriter(indexPath).WriteIndex(idx))
+	commit, err := indexer.ReadCommit(indexPath)
+	require.NoError(t, err)
+	require.Len(t, commit.Segments, 1)
+	return indexer.SegmentPath(indexPath, commit.Segments[0].Name)
+}
+
+func rewriteManifest(t *testing.T, indexPath string, modify func(*indexer.Manifest)) {
+	genPath := writeTestIndex(t, indexPath, indexer.NewInvertedIndex())
+	manifest := indexer.NewManifest(indexer.SourceInfo{})
+	modify(manifest)
+	require.NoError(t, indexer.WriteManifest(genPath, manifest))
+}
+
 func TestSearchEngine_Initialize(t *testing.T) {
 	tests := []struct {
-		name      string
-		setupFunc func(string)
-		expectErr bool
+		name         string
+		setupFunc    func(string)
+		expectErr    bool
+		incompatible bool
+		corrupt      bool
 	}{
 		{
 			name: "successful initialize",
 			setupFunc: func(indexPath string) {
-				require.NoError(t, os.MkdirAll(indexPath, 0755))
-				for char := 'a'; char <= 'z'; char++ {
-					filename := filepath.Join(indexPath, fmt.Sprintf("index%c.idx", char))
-					require.NoError(t, os.WriteFile(filename, []byte{}, 0644))
-				}
+				writeTestIndex(t, indexPath, indexer.NewInvertedIndex())
 			},
 			expectErr: false,
 		},
 		{
-			name: "missing index files",
+			name: "missing index",
 			setupFunc: func(indexPath string) {
 				require.NoError(t, os.MkdirAll(indexPath, 0755))
-				// Don't create files
 			},
 			expectErr: true,
 		},
+		{
+			name: "missing index files",
+			setupFunc: func(indexPath string) {
+				genPath := writeTestIndex(t, indexPath, indexer.NewInvertedIndex())
+				require.NoError(t, os.Remove(filepath.Join(genPath, indexer.PostingsFile)))
+			},
+			expectErr: true,
+		},
+		{
+			name: "truncated postings",
+			setupFunc: func(indexPath string) {
+				idx := indexer.NewInvertedIndex()
+				idx.AddDocument("1", "Paris")
+				idx.Add("pari", "1", indexer.NewPosting(indexer.TITLE, 1))
+				genPath := writeTestIndex(t, indexPath, idx)
+				path := filepath.Join(genPath, indexer.PostingsFile)
+				info, err := os.Stat(path)
+				require.NoError(t, err)
+				require.NoError(t, os.Truncate(path, info.Size()-1))
+			},
+			expectErr: true,
+			corrupt:   true,
+		},
+		{
+			name: "missing manifest",
+			setupFunc: func(indexPath string) {
+				genPath := writeTestIndex(t, indexPath, indexer.NewInvertedIndex())
+				require.NoError(t, os.Remove(filepath.Join(genPath, indexer.ManifestFile)))
+			},
+			expectErr:    true,
+			incompatible: true,
+		},
+		{
+			name: "different stemmer",
+			setupFunc: func(indexPath string) {
+				rewriteManifest(t, indexPath, func(m *indexer.Manifest) { m.Analyzer.Stemmer = "snowball" })
+			},
+			expectErr:    true,
+			incompatible: true,
+		},
+		{
+			name: "newer format version",
+			setupFunc: func(indexPath string) {
+				rewriteManifest(t, indexPath, func(m *indexer.Manifest) { m.FormatVersion = indexer.FormatVersion + 1 })
+			},
+			expectErr:    true,
+			incompatible: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -70,6 +166,13 @@ func TestSearchEngine_Initialize(t *testing.T) {
 			err := se.Initialize()
 			if tt.expectErr {
 				assert.Error(t, err)
+				var wikiErr *indexer.WikiError
+				if tt.incompatible && assert.True(t, errors.As(err, &wikiErr)) {
+					assert.Equal(t, indexer.ErrIncompatibleIndex, wikiErr.Type)
+				}
+				if tt.corrupt && assert.True(t, errors.As(err, &wikiErr)) {
+					assert.Equal(t, indexer.ErrCorruptIndex, wikiErr.Type)
+				}
 			} else {
 				assert.NoError(t, err)
 				se.Close()
@@ -79,24 +182,13 @@ func TestSearchEngine_Initialize(t *testing.T) {
 }
 
 func TestSearchEngine_getPostings(t *testing.T) {
-	// Create temp index file
 	tempDir := t.TempDir()
 	indexPath := filepath.Join(tempDir, "index")
 
-	// Create index directory
-	require.NoError(t, os.MkdirAll(indexPath, 0755))
-
-	// Create dummy index files for a-z
-	for char := 'a'; char <= 'z'; char++ {
-		filename := filepath.Join(indexPath, fmt.Sprintf("index%c.idx", char))
-		file, err := os.Create(filename)
-		require.NoError(t, err)
-		if char == 't' {
-			_, err = file.WriteString("test:doc1$8$1:doc2$32$2\n")
-			require.NoError(t, err)
-		}
-		require.NoError(t, file.Close())
-	}
+	idx := indexer.NewInvertedIndex()
+	idx.Add("test", "doc1", indexer.NewPosting(indexer.BODY, 1))
+	idx.Add("test", "doc2", indexer.NewPosting(indexer.TITLE, 2))
+	writeTestIndex(t, indexPath, idx)
 
 	se := NewSearchEngine(indexPath)
 	require.NoError(t, se.Initialize())
@@ -107,8 +199,8 @@ func TestSearchEngine_getPostings(t *testing.T) {
 	require.NoError(t, err)
 
 	expectedPostings := map[string]indexer.Posting{
-		"doc1": {Fields: indexer.BODY, Frequency: 1},
-		"doc2": {Fields: indexer.TITLE, Frequency: 2},
+		"doc1": indexer.NewPosting(indexer.BODY, 1),
+		"doc2": indexer.NewPosting(indexer.TITLE, 2),
 	}
 	assert.Equal(t, expectedPostings, postings)
 
@@ -135,3 +227,575 @@ func TestSearchEngine_getPostings(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, results3)
 }
+
+func TestSearchEngine_Segments(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	first := indexer.NewInvertedIndex()
+	first.Add("paris", "1", indexer.NewPosting(indexer.TITLE, 1))
+	first.Add("paris", "2", indexer.NewPosting(indexer.BODY, 3))
+	first.Add("london", "3", indexer.NewPosting(indexer.TITLE, 1))
+	writeTestIndex(t, indexPath, first)
+
+	// Document 2 is updated and no longer mentions paris; document 4 is new.
+	second := indexer.NewInvertedIndex()
+	second.Add("rome", "2", indexer.NewPosting(indexer.BODY, 1))
+	second.Add("paris", "4", indexer.NewPosting(indexer.BODY, 2))
+	writer := indexer.NewIndexWriter(indexPath)
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	assert.Len(t, se.segments, 2)
+	assert.Equal(t, 4, se.DocCount())
+
+	postings, err := se.getPostings("paris")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]indexer.Posting{
+		"1": indexer.NewPosting(indexer.TITLE, 1),
+		"4": indexer.NewPosting(indexer.BODY, 2),
+	}, postings)
+
+	results, err := se.Search("rome", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "2", results[0].DocID)
+}
+
+func TestSearchEngine_Deletes(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	idx := indexer.NewInvertedIndex()
+	idx.Add("pari", "1", indexer.NewPosting(indexer.TITLE, 1))
+	idx.AddDocument("1", "Paris")
+	idx.Add("pari", "2", indexer.NewPosting(indexer.TITLE, 1))
+	idx.AddDocument("2", "Paris Hilton")
+	writeTestIndex(t, indexPath, idx)
+
+	deleted, _, err := indexer.NewIndexWriter(indexPath).Delete("Paris Hilton")
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	assert.Equal(t, 1, se.DocCount())
+
+	results, err := se.Search("paris", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "1", results[0].DocID)
+}
+
+func writeConcurrencyIndex(t *testing.T, indexPath string) []string {
+	words := []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "theta", "kappa"}
+	writer := indexer.NewIndexWriter(indexPath)
+	for seg := 0; seg < 3; seg++ {
+		idx := indexer.NewInvertedIndex()
+		for i := 0; i < 300; i++ {
+			docID := fmt.Sprintf("%d", seg*200+i)
+			idx.AddDocument(docID, "Doc "+docID)
+			for j, word := range words {
+				if (i+seg)%(j+2) == 0 {
+					idx.Add(word, docID, indexer.NewPosting(indexer.BODY, i%(j+3)+1))
+					if i%7 == j {
+						idx.Add(word, docID, indexer.NewPosting(indexer.TITLE, 1))
+					}
+				}
+			}
+		}
+		require.NoError(t, writer.AppendIndex(idx))
+	}
+	require.NoError(t, writer.WaitForMerges())
+	_, _, err := writer.Delete("5", "250", "Doc 600")
+	require.NoError(t, err)
+
+	return []string{
+		"alpha", "beta gamma", "delta epsilon zeta", "theta", "kappa alpha",
+		"gamma", "zeta theta kappa", "alpha beta gamma delta", "epsilon", "missing beta",
+	}
+}
+
+func TestSearchEngine_ConcurrentSearch(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	queries := writeConcurrencyIndex(t, indexPath)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	expected := make([][]SearchResult, len(queries))
+	for i, query := range queries {
+		results, err := se.Search(query, 20)
+		require.NoError(t, err)
+		require.NotEmpty(t, results, query)
+		expected[i] = results
+	}
+
+	const workers = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				i := (w + n) % len(queries)
+				results, err := se.Search(queries[i], 20)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !reflect.DeepEqual(expected[i], results) {
+					errs <- fmt.Errorf("query %q: got %v, want %v", queries[i], results, expected[i])
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestSearchEngine_CloseDuringSearch(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	queries := writeConcurrencyIndex(t, indexPath)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				_, _ = se.Search(queries[(w+n)%len(queries)], 10)
+				_ = se.DocCount()
+			}
+		}(w)
+	}
+	require.NoError(t, se.Initialize())
+	se.Close()
+	wg.Wait()
+
+	_, err := se.Search("alpha", 10)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Equal(t, 0, se.DocCount())
+
+	require.NoError(t, se.Initialize())
+	results, err := se.Search("alpha", 10)
+	require.NoError(t, err)
+	assert.NotEmpty(t, results)
+	se.Close()
+}
+
+// searchExhaustive scores every posting of query, restricted to fields if
+// set, adds the static priors and returns all matches best first.
+func searchExhaustive(t *testing.T, se *SearchEngine, query string, fields indexer.FieldMask) []SearchResult {
+	lengths := make(map[string]int)
+	for _, seg := range se.segments {
+		for ord, dead := range seg.dead {
+			if !dead {
+				lengths[seg.reader.DocID(uint32(ord))] = seg.reader.Doc(uint32(ord)).Length
+			}
+		}
+	}
+
+	scores := make(map[string]float64)
+	matches := make(map[string]int)
+	required := 0
+	for _, clause := range se.parseClauses(query) {
+		if clause.required {
+			required++
+		}
+		terms := []string{clause.term}
+		if clause.wildcard || clause.fuzzy > 0 {
+			var err error
+			terms, err = se.expand(clause)
+			require.NoError(t, err)
+		}
+
+		matched := make(map[string]bool)
+		for _, term := range terms {
+			docFreq := 0
+			for _, seg := range se.segments {
+				info, _, err := seg.reader.Lookup(term)
+				require.NoError(t, err)
+				docFreq += info.DocFreq
+			}
+			postings, err := se.getPostings(term)
+			require.NoError(t, err)
+			if docFreq == 0 {
+				continue
+			}
+			weight := se.scorer.Weight(docFreq, se.stats)
+			for docID, posting := range postings {
+				posting = restrictPosting(posting, fields)
+				if posting.Fields == 0 {
+					continue
+				}
+				scores[docID] += se.scorer.Score(weight, posting, lengths[docID], se.stats)
+				matched[docID] = true
+			}
+		}
+		if clause.required {
+			for docID := range matched {
+				matches[docID]++
+			}
+		}
+	}
+
+	var results []SearchResult
+	for docID, score := range scores {
+		if se.maxRank > 0 {
+			score += se.priorWeight * prior(se.ranks[docID].PageRank, se.maxRank)
+		}
+		if matches[docID] == required {
+			results = append(results, SearchResult{DocID: docID, Score: score})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return worse(results[j], results[i])
+	})
+	return results
+}
+
+func TestSearchEngine_SearchMatchesExhaustive(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	writer := indexer.NewIndexWriter(indexPath)
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	words := []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta"}
+	for seg := 0; seg < 3; seg++ {
+		idx := indexer.NewInvertedIndex()
+		for i := 0; i < 400; i++ {
+			// Later segments rewrite some documents of earlier ones.
+			docID := fmt.Sprintf("%d", seg*300+i)
+			idx.AddDocument(docID, "Doc "+docID)
+			// Links favour the documents with low IDs.
+			var links []string
+			for range rng.IntN(4) {
+				links = append(links, fmt.Sprintf("doc %d", rng.IntN(1+rng.IntN(1200))))
+			}
+			idx.SetLinks(docID, links)
+			for j, word := range words {
+				// Rarer words get fewer, more varied postings.
+				if rng.IntN(j+2) != 0 {
+					continue
+				}
+				idx.Add(word, docID, indexer.NewPosting(indexer.BODY, 1+rng.IntN(4*j+1)))
+				if rng.IntN(10) == 0 {
+					idx.Add(word, docID, indexer.NewPosting(indexer.TITLE, 1+rng.IntN(2)))
+				}
+			}
+		}
+		require.NoError(t, writer.AppendIndex(idx))
+	}
+	require.NoError(t, writer.WaitForMerges())
+	require.NoError(t, writer.UpdateRanks())
+	_, _, err := writer.Delete("3", "301", "Doc 900", "1000")
+	require.NoError(t, err)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+	require.Positive(t, se.maxRank)
+
+	queries := []string{
+		"alpha", "zeta", "alpha beta", "gamma delta epsilon", "zeta epsilon",
+		"alpha alpha", "beta missing", "alpha beta gamma delta epsilon zeta",
+		"alpha AND beta", "+zeta +epsilon", "+alpha beta gamma", "gamma AND zeta delta",
+		"+alpha +alpha", "+alpha +missing", "alpha AND beta AND gamma AND delta",
+		"*eta", "?eta alpha", "+*ta gamma", "e*", "*l*", "+zz* alpha", "*a AND b*",
+	}
+	pages := []struct{ offset, limit int }{
+		{0, 1}, {0, 3}, {0, 10}, {5, 10}, {0, 100}, {90, 20}, {0, 2000}, {3000, 10}, {10, 0},
+	}
+	for name, newScorer := range Scorers {
+		se.SetScorer(newScorer())
+		for _, weight := range []float64{0, DefaultPriorWeight, 5} {
+			se.SetPriorWeight(weight)
+			for _, query := range queries {
+				for _, fields := range []indexer.FieldMask{0, indexer.TITLE} {
+					all := searchExhaustive(t, se, query, fields)
+					// A threshold of 1 prunes from the first match on.
+					for _, threshold := range []int{1, math.MaxInt} {
+						for _, page := range pages {
+							msg := fmt.Sprintf("%s prior %g: %q fields %d offset %d limit %d threshold %d", name, weight, query, fields, page.offset, page.limit, threshold)
+							resp, err := se.Execute(context.Background(), SearchRequest{
+								Query:              query,
+								Offset:             page.offset,
+								Limit:              page.limit,
+								Fields:             fields,
+								TotalHitsThreshold: threshold,
+							})
+							require.NoError(t, err)
+
+							end := min(page.offset+page.limit, len(all))
+							var expected []SearchResult
+							if page.offset < end {
+								expected = all[page.offset:end]
+							}
+							assert.Equal(t, expected, resp.Results, msg)
+
+							if resp.TotalIsLowerBound {
+								assert.Equal(t, 1, threshold, msg)
+								assert.LessOrEqual(t, resp.TotalHits, len(all), msg)
+								assert.GreaterOrEqual(t, resp.TotalHits, end, msg)
+							} else {
+								assert.Equal(t, len(all), resp.TotalHits, msg)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSearchEngine_Execute(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	for i := 0; i < 30; i++ {
+		docID := fmt.Sprintf("%02d", i)
+		idx.AddDocument(docID, "Doc "+docID)
+		idx.Add("common", docID, indexer.NewPosting(indexer.BODY, 1+i%7))
+	}
+	idx.Add("rare", "00", indexer.NewPosting(indexer.TITLE, 10))
+	writeTestIndex(t, indexPath, idx)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	ctx := context.Background()
+	first, err := se.Execute(ctx, SearchRequest{Query: "common", Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 30, first.TotalHits)
+	assert.False(t, first.TotalIsLowerBound)
+	assert.Len(t, first.Results, 10)
+
+	second, err := se.Execute(ctx, SearchRequest{Query: "common", Offset: 10, Limit: 10})
+	require.NoError(t, err)
+	all, err := se.Search("common", 20)
+	require.NoError(t, err)
+	assert.Equal(t, all, append(first.Results, second.Results...))
+
+	last, err := se.Execute(ctx, SearchRequest{Query: "common", Offset: 25, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, last.Results, 5)
+
+	// Past the threshold, no other document can beat the one with "rare".
+	bounded, err := se.Execute(ctx, SearchRequest{Query: "common rare", Limit: 1, TotalHitsThreshold: 5})
+	require.NoError(t, err)
+	assert.True(t, bounded.TotalIsLowerBound)
+	assert.GreaterOrEqual(t, bounded.TotalHits, 5)
+	assert.Less(t, bounded.TotalHits, 30)
+	assert.Equal(t, "00", bounded.Results[0].DocID)
+
+	// Counting stops at the threshold, but no match was left out.
+	exactly, err := se.Execute(ctx, SearchRequest{Query: "common", Limit: 1, TotalHitsThreshold: 30})
+	require.NoError(t, err)
+	assert.False(t, exactly.TotalIsLowerBound)
+	assert.Equal(t, 30, exactly.TotalHits)
+
+	bm25f, err := se.Execute(ctx, SearchRequest{Query: "common", Limit: 1, Scorer: NewBM25F()})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Results[0].Score, bm25f.Results[0].Score)
+	assert.Equal(t, "tfidf", se.Scorer().Name())
+
+	_, err = se.Execute(ctx, SearchRequest{Query: "common", Offset: -1, Limit: 10})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = se.Execute(canceled, SearchRequest{Query: "common", Limit: 10})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTopK(t *testing.T) {
+	top := &topK{limit: 3}
+	for _, r := range []SearchResult{
+		{DocID: "a", Score: 1}, {DocID: "b", Score: 5}, {DocID: "c", Score: 3},
+		{DocID: "d", Score: 3}, {DocID: "e", Score: 0.5}, {DocID: "f", Score: 5},
+	} {
+		top.offer(r)
+	}
+	assert.Equal(t, []SearchResult{
+		{DocID: "b", Score: 5}, {DocID: "f", Score: 5}, {DocID: "c", Score: 3},
+	}, top.page(0))
+}
+
+func TestSearchEngine_Wildcard(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	for docID, terms := range map[string][]string{
+		"1": {"einstein", "physic"},
+		"2": {"einstein", "relat"},
+		"3": {"einsteinium", "element"},
+		"4": {"eindhoven", "citi"},
+		"5": {"volkswagen", "car"},
+	} {
+		idx.AddDocument(docID, "Doc "+docID)
+		for _, term := range terms {
+			idx.Add(term, docID, indexer.NewPosting(indexer.BODY, 1))
+		}
+	}
+	writeTestIndex(t, indexPath, idx)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	docIDs := func(query string) []string {
+		results, err := se.Search(query, 10)
+		require.NoError(t, err)
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.DocID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	assert.Equal(t, []string{"1", "2", "3"}, docIDs("einst*"))
+	assert.Equal(t, []string{"5"}, docIDs("?olkswagen"))
+	assert.Equal(t, []string{"1", "2"}, docIDs("*stein"))
+	assert.Equal(t, []string{"1", "2", "3", "4"}, docIDs("+ein* rel?t"))
+	assert.Equal(t, []string{"2"}, docIDs("ein* AND rel?t"))
+	assert.Empty(t, docIDs("+xyz* einstein"))
+
+	se.SetMaxExpansions(2)
+	se.mutex.RLock()
+	terms, err := se.expand(clause{term: "ein*", wildcard: true})
+	se.mutex.RUnlock()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eindhoven", "einstein"}, terms)
+	assert.Equal(t, []string{"1", "2", "4"}, docIDs("ein*"))
+}
+
+func TestSearchEngine_Fuzzy(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	stemmer := indexer.NewStemmer()
+	defer stemmer.Release()
+	idx := indexer.NewInvertedIndex()
+	for docID, words := range map[string][]string{
+		"1": {"einstein", "physics"},
+		"2": {"einstein", "relativity"},
+		"3": {"einstein", "letters"},
+		"4": {"eisenstein", "series"},
+		"5": {"weinstein", "films"},
+		"6": {"physic", "medicine"},
+	} {
+		idx.AddDocument(docID, "Doc "+docID)
+		for _, word := range words {
+			term := stemmer.Stem(word)
+			idx.Add(term, docID, indexer.NewPosting(indexer.BODY, 1))
+			idx.AddForm(term, word, 1)
+		}
+	}
+	writeTestIndex(t, indexPath, idx)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	docIDs := func(query string) []string {
+		results, err := se.Search(query, 10)
+		require.NoError(t, err)
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.DocID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	assert.Empty(t, docIDs("einstien"))
+	assert.Equal(t, []string{"1", "2", "3"}, docIDs("einstien~"))
+	assert.Empty(t, docIDs("einstien~1"))
+	assert.Equal(t, []string{"1", "2", "3", "5"}, docIDs("einstein~1"))
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, docIDs("einstein~2"))
+	assert.Equal(t, []string{"1", "2", "3"}, docIDs("+einstien~ relativity physics"))
+
+	tests := []struct {
+		query      string
+		suggestion string
+	}{
+		{"einstien", "einstein"},
+		{"Einstien lettrs", "Einstein letters"},
+		{"the einstien letters", "the einstein letters"},
+		{"einstein", ""},
+		{"eisenstein", ""},
+		{"einst* einstien~", ""},
+		{"xyzzy", ""},
+	}
+	for _, tt := range tests {
+		suggestion, ok, err := se.DidYouMean(tt.query)
+		require.NoError(t, err)
+		assert.Equal(t, tt.suggestion, suggestion, tt.query)
+		assert.Equal(t, tt.suggestion != "", ok, tt.query)
+	}
+}
+
+func TestSearchEngine_Prior(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	addPage := func(docID, title string, body int, links ...string) {
+		idx.AddDocument(docID, title)
+		idx.Add("pari", docID, indexer.NewPosting(indexer.TITLE, 1))
+		idx.Add("pari", docID, indexer.NewPosting(indexer.BODY, body))
+		idx.SetLinks(docID, links)
+	}
+	// The namesake mentions Paris more often, but the city is linked to.
+	addPage("1", "Paris", 2)
+	addPage("2", "Paris, Texas", 6)
+	for i := 3; i < 8; i++ {
+		addPage(fmt.Sprint(i), fmt.Sprintf("Page %d", i), 1, "paris")
+	}
+	writer := indexer.NewIndexWriter(indexPath)
+	require.NoError(t, writer.WriteIndex(idx))
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+	results, err := se.Search("paris", 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "2", results[0].DocID, "no ranks, no prior")
+
+	require.NoError(t, writer.UpdateRanks())
+	require.NoError(t, se.Initialize())
+	results, err = se.Search("paris", 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "1", results[0].DocID)
+
+	doc, ok := se.Doc("1")
+	require.True(t, ok)
+	assert.Equal(t, 5, doc.Inlinks)
+	assert.Greater(t, doc.PageRank, 1.0)
+
+	explanation, err := se.Explain("paris", "1")
+	require.NoError(t, err)
+	assert.InDelta(t, results[0].Score, explanation.Value, 1e-12)
+	static := explanation.Details[len(explanation.Details)-1]
+	assert.Contains(t, static.Description, "static prior")
+	assert.Equal(t, DefaultPriorWeight, static.Value, "the most linked article gets the full weight")
+
+	se.SetPriorWeight(0)
+	results, err = se.Search("paris", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "2", results[0].DocID)
+	explanation, err = se.Explain("paris", "1")
+	require.NoError(t, err)
+	assert.NotContains(t, explanation.String(), "static prior")
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+// segmentReader gives access to one immutable segment of the index.
+type segmentReader struct {
+	name     string
+	manifest *indexer.Manifest
+	reader   *indexer.SegmentReader
+	// dead marks the ordinals of documents that are deleted or superseded
+	// by a newer segment and must not be returned.
+	dead []bool
+	// prior holds the static prior of each document, nil if the index has
+	// no ranks, and maxPrior the highest of them.
+	prior    []float64
+	maxPrior float64
+}
+
+func openSegment(indexPath string, info indexer.SegmentInfo) (*segmentReader, error) {
+	dir := indexer.SegmentPath(indexPath, info.Name)
+
+	manifest, err := indexer.ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Only the checksums of small files are checked here; wikifind verify
+	// reads the rest.
+	if err := manifest.CheckFiles(dir); err != nil {
+		return nil, err
+	}
+
+	deletes, err := indexer.ReadSegmentDeletes(indexPath, info)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := indexer.OpenSegmentReader(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &segmentReader{
+		name:     info.Name,
+		manifest: manifest,
+		reader:   reader,
+		dead:     make([]bool, reader.DocCount()),
+	}
+	for docID := range deletes {
+		if ord, ok := reader.Ordinal(docID); ok {
+			seg.dead[ord] = true
+		}
+	}
+	return seg, nil
+}
+
+// isDead reports whether the document with ordinal ord must be hidden.
+func (seg *segmentReader) isDead(ord uint32) bool {
+	return seg.dead[ord]
+}
+
+func (seg *segmentReader) close() {
+	_ = seg.reader.Close()
+}
+
+// postings returns the live postings of term in this segment, or nil if
+// the segment does not contain it.
+func (seg *segmentReader) postings(term string) (map[string]indexer.Posting, error) {
+	info, found, err := seg.reader.Lookup(term)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	it, err := seg.reader.Postings(info)
+	if err != nil {
+		return nil, err
+	}
+
+	postings := make(map[string]indexer.Posting, info.DocFreq)
+	for it.Next() {
+		if !seg.dead[it.Doc()] {
+			postings[seg.reader.DocID(it.Doc())] = it.Posting()
+		}
+	}
+	return postings, it.Err()
+}
+
+// lookup returns the dictionary entries of terms in this segment, with a
+// zero entry for each term the segment does not contain.
+func (seg *segmentReader) lookup(terms []string) ([]indexer.TermInfo, error) {
+	infos := make([]indexer.TermInfo, len(terms))
+	for i, term := range terms {
+		info, found, err := seg.reader.Lookup(term)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			infos[i] = info
+		}
+	}
+	return infos, nil
+}
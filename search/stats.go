@@ -0,0 +1,103 @@
+package search
+
+import "github.com/PhantomInTheWire/wikifind/indexer"
+
+// Document is what the index stores about a page.
+type Document struct {
+	DocID    string
+	Title    string
+	Length   int
+	Redirect string
+	// Segment names the segment holding the live version of the page.
+	Segment string
+	// Inlinks and PageRank give the standing of an article in the link
+	// graph, both 0 for redirects and pages indexed since the ranks were
+	// last computed.
+	Inlinks  int
+	PageRank float64
+}
+
+// Doc returns the live version of the document with the given ID.
+func (se *SearchEngine) Doc(docID string) (Document, bool) {
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+
+	i, ord, ok := se.locate(docID)
+	if !ok {
+		return Document{}, false
+	}
+	seg := se.segments[i]
+	info := seg.reader.Doc(ord)
+	return Document{
+		DocID:    docID,
+		Title:    info.Title,
+		Length:   info.Length,
+		Redirect: info.Redirect,
+		Segment:  seg.name,
+		Inlinks:  se.ranks[docID].Inlinks,
+		PageRank: se.ranks[docID].PageRank,
+	}, true
+}
+
+// Abstract returns the opening text of the live version of a document.
+func (se *SearchEngine) Abstract(docID string) (string, bool, error) {
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+
+	i, ord, ok := se.locate(docID)
+	if !ok {
+		return "", false, nil
+	}
+	abstract, err := se.segments[i].reader.Abstract(ord)
+	return abstract, err == nil, err
+}
+
+// Categories returns the names of the categories of the live version of a
+// document.
+func (se *SearchEngine) Categories(docID string) ([]string, bool, error) {
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+
+	i, ord, ok := se.locate(docID)
+	if !ok {
+		return nil, false, nil
+	}
+	categories, err := se.segments[i].reader.Categories(ord)
+	return categories, err == nil, err
+}
+
+// locate finds the position in se.segments of the segment holding the live
+// version of a document, and its ordinal there. The caller must hold the
+// read lock.
+func (se *SearchEngine) locate(docID string) (int, uint32, bool) {
+	for i := len(se.segments) - 1; i >= 0; i-- {
+		seg := se.segments[i]
+		ord, ok := seg.reader.Ordinal(docID)
+		if !ok {
+			continue
+		}
+		// A deleted version hides any older one.
+		return i, ord, !seg.isDead(ord)
+	}
+	return 0, 0, false
+}
+
+// Stats summarizes the index an engine searches. Its segments are
+// described as by indexer.Summarize, which also reads the postings.
+type Stats struct {
+	CollectionStats
+	Scorer   string
+	Segments []indexer.SegmentSummary
+}
+
+// Stats returns the statistics of the index.
+func (se *SearchEngine) Stats() Stats {
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+
+	stats := Stats{CollectionStats: se.stats, Scorer: se.scorer.Name()}
+	for _, seg := range se.segments {
+		stats.Segments = append(stats.Segments, indexer.SummarizeSegment(seg.name, seg.reader, seg.manifest, seg.isDead))
+	}
+	return stats
+}
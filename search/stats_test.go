@@ -0,0 +1,73 @@
+package search
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEngine_DocAndStats(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	first := indexer.NewInvertedIndex()
+	first.AddDocument("1", "Paris")
+	first.SetAbstract("1", "Paris is the capital of France.")
+	first.Add("pari", "1", indexer.NewPosting(indexer.TITLE, 2))
+	first.AddDocument("2", "Rome")
+	first.Add("rome", "2", indexer.NewPosting(indexer.TITLE, 1))
+	first.AddDocument("3", "Londres")
+	first.SetRedirect("3", "London")
+	writeTestIndex(t, indexPath, first)
+
+	second := indexer.NewInvertedIndex()
+	second.AddDocument("2", "Rome, Italy")
+	second.SetAbstract("2", "Rome is the capital of Italy.")
+	second.Add("rome", "2", indexer.NewPosting(indexer.TITLE, 4))
+	writer := indexer.NewIndexWriter(indexPath)
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+	_, _, err := writer.Delete("1")
+	require.NoError(t, err)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	doc, found := se.Doc("2")
+	require.True(t, found)
+	assert.Equal(t, Document{DocID: "2", Title: "Rome, Italy", Length: 4, Segment: "_1"}, doc)
+
+	doc, found = se.Doc("3")
+	require.True(t, found)
+	assert.Equal(t, "London", doc.Redirect)
+
+	_, found = se.Doc("1")
+	assert.False(t, found)
+	_, found = se.Doc("4")
+	assert.False(t, found)
+
+	abstract, found, err := se.Abstract("2")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "Rome is the capital of Italy.", abstract)
+	_, found, err = se.Abstract("1")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	stats := se.Stats()
+	assert.Equal(t, 2, stats.DocCount)
+	assert.Equal(t, "tfidf", stats.Scorer)
+	require.Len(t, stats.Segments, 2)
+	seg := stats.Segments[0]
+	assert.Equal(t, "_0", seg.Name)
+	assert.Equal(t, 3, seg.Documents)
+	assert.Equal(t, 2, seg.Deleted)
+	assert.Equal(t, 2, seg.Terms)
+	assert.Positive(t, seg.Size)
+	assert.Equal(t, indexer.FormatVersion, seg.Manifest.FormatVersion)
+	assert.Equal(t, 1, stats.Segments[1].Documents)
+	assert.Equal(t, 0, stats.Segments[1].Deleted)
+}
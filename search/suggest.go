@@ -0,0 +1,107 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+// Suggest returns up to n article titles starting with prefix, ignoring
+// case, for autocompletion. Titles are ranked by the popularity of their
+// articles, and an article reached through several matching titles, such
+// as redirects, is suggested once.
+func (se *SearchEngine) Suggest(prefix string, n int) ([]Suggestion, error) {
+	key := indexer.TitleKey(prefix)
+	if key == "" || n <= 0 {
+		return nil, nil
+	}
+	// "albert " should not complete to "Alberta".
+	if trimmed := strings.TrimRightFunc(prefix, func(r rune) bool {
+		return r == '_' || unicode.IsSpace(r)
+	}); trimmed != prefix {
+		key += " "
+	}
+
+	se.mutex.RLock()
+	defer se.mutex.RUnlock()
+
+	best := make(map[string]Suggestion)
+	for _, seg := range se.segments {
+		matches, err := seg.reader.SuggestTitles(key, n, seg.isDead)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			doc := seg.reader.Doc(match.Ordinal)
+			s := Suggestion{
+				Title:      doc.Title,
+				Article:    doc.Title,
+				DocID:      seg.reader.DocID(match.Ordinal),
+				Popularity: match.Popularity,
+			}
+			if doc.Redirect != "" {
+				// The article may be in another segment than the redirect.
+				s.Article, s.DocID = doc.Redirect, ""
+				article, found, err := se.lookupTitle(doc.Redirect)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					s.DocID, s.Popularity = article.DocID, article.Popularity
+				}
+			}
+
+			articleKey := indexer.TitleKey(s.Article)
+			if old, ok := best[articleKey]; !ok || betterSuggestion(s, old) {
+				best[articleKey] = s
+			}
+		}
+	}
+
+	suggestions := make([]Suggestion, 0, len(best))
+	for _, s := range best {
+		suggestions = append(suggestions, s)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return betterSuggestion(suggestions[i], suggestions[j])
+	})
+	if len(suggestions) > n {
+		suggestions = suggestions[:n]
+	}
+	return suggestions, nil
+}
+
+// betterSuggestion orders suggestions by popularity, then by title.
+func betterSuggestion(a, b Suggestion) bool {
+	if a.Popularity != b.Popularity {
+		return a.Popularity > b.Popularity
+	}
+	if a.Title != b.Title {
+		return a.Title < b.Title
+	}
+	return a.DocID < b.DocID
+}
+
+// lookupTitle finds the live article titled title, newest segment first.
+// The caller must hold the read lock.
+func (se *SearchEngine) lookupTitle(title string) (Suggestion, bool, error) {
+	for i := len(se.segments) - 1; i >= 0; i-- {
+		seg := se.segments[i]
+		ord, found, err := seg.reader.LookupTitle(title, seg.isDead)
+		if err != nil {
+			return Suggestion{}, false, err
+		}
+		if found {
+			doc := seg.reader.Doc(ord)
+			return Suggestion{
+				Title:      doc.Title,
+				Article:    doc.Title,
+				DocID:      seg.reader.DocID(ord),
+				Popularity: doc.Length,
+			}, true, nil
+		}
+	}
+	return Suggestion{}, false, nil
+}
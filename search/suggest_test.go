@@ -0,0 +1,72 @@
+package search
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEngine_Suggest(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+
+	first := indexer.NewInvertedIndex()
+	for docID, doc := range map[string]struct {
+		title  string
+		length int
+	}{
+		"1": {"Albert Einstein", 50},
+		"2": {"Alberta", 30},
+		"3": {"Albert Camus", 40},
+		"4": {"Albertville", 5},
+	} {
+		first.AddDocument(docID, doc.title)
+		first.Add("word", docID, indexer.NewPosting(indexer.BODY, doc.length))
+	}
+	writeTestIndex(t, indexPath, first)
+
+	// The redirect is indexed apart from its article, and Albert Camus is
+	// updated with a shorter page.
+	second := indexer.NewInvertedIndex()
+	second.AddDocument("5", "Einstein")
+	second.SetRedirect("5", "Albert Einstein")
+	second.Add("redirect", "5", indexer.NewPosting(indexer.BODY, 1))
+	second.AddDocument("6", "Einsteinium")
+	second.Add("word", "6", indexer.NewPosting(indexer.BODY, 20))
+	second.AddDocument("3", "Albert Camus")
+	second.Add("word", "3", indexer.NewPosting(indexer.BODY, 3))
+	writer := indexer.NewIndexWriter(indexPath)
+	require.NoError(t, writer.AppendIndex(second))
+	require.NoError(t, writer.WaitForMerges())
+
+	_, _, err := writer.Delete("Albertville")
+	require.NoError(t, err)
+
+	se := NewSearchEngine(indexPath)
+	require.NoError(t, se.Initialize())
+	defer se.Close()
+
+	suggestions, err := se.Suggest("ein", 5)
+	require.NoError(t, err)
+	assert.Equal(t, []Suggestion{
+		{Title: "Einstein", Article: "Albert Einstein", DocID: "1", Popularity: 50},
+		{Title: "Einsteinium", Article: "Einsteinium", DocID: "6", Popularity: 20},
+	}, suggestions)
+
+	titles := func(prefix string, n int) []string {
+		suggestions, err := se.Suggest(prefix, n)
+		require.NoError(t, err)
+		var titles []string
+		for _, s := range suggestions {
+			titles = append(titles, s.Title)
+		}
+		return titles
+	}
+	assert.Equal(t, []string{"Albert Einstein", "Alberta", "Albert Camus"}, titles("ALBERT", 5))
+	assert.Equal(t, []string{"Albert Einstein", "Albert Camus"}, titles("albert_", 5))
+	assert.Equal(t, []string{"Albert Einstein"}, titles("al", 1))
+	assert.Empty(t, titles("albertv", 5))
+	assert.Empty(t, titles("  ", 5))
+}
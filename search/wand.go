@@ -0,0 +1,401 @@
+package search
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"sort"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+)
+
+// boundSlack absorbs the rounding of summing score bounds in a different
+// order than the scores themselves, so that pruning never drops a document
+// exhaustive scoring would keep.
+const boundSlack = 1e-9
+
+// queryTerm is one term of a query with its weight across the whole
+// index. A wildcard clause expands to several terms.
+type queryTerm struct {
+	term     string
+	clause   int // position of the clause in the query
+	required bool
+	weight   float64
+}
+
+// scoring is the scorer of a query, the statistics it scores with, the
+// fields it is restricted to, if any, and the weight of the static prior
+// added to the scores.
+type scoring struct {
+	scorer      Scorer
+	stats       CollectionStats
+	fields      indexer.FieldMask
+	priorWeight float64
+}
+
+// restrictPosting keeps the occurrences of a posting in fields, unless
+// fields is empty.
+func restrictPosting(posting indexer.Posting, fields indexer.FieldMask) indexer.Posting {
+	if fields == 0 {
+		return posting
+	}
+	restricted := indexer.Posting{Fields: posting.Fields & fields}
+	for i := range indexer.NumFields {
+		if restricted.Fields&(1<<i) != 0 {
+			restricted.FieldFreqs[i] = posting.FieldFreqs[i]
+			restricted.Frequency += int(posting.FieldFreqs[i])
+		}
+	}
+	return restricted
+}
+
+// restrictInfo narrows the bounds of a dictionary entry to fields, unless
+// fields is empty.
+func restrictInfo(info indexer.TermInfo, fields indexer.FieldMask) indexer.TermInfo {
+	if fields == 0 {
+		return info
+	}
+	info.Fields &= fields
+	total := 0
+	for i := range indexer.NumFields {
+		if info.Fields&(1<<i) == 0 {
+			info.MaxFieldFreqs[i] = 0
+		}
+		total += int(info.MaxFieldFreqs[i])
+	}
+	info.MaxFrequency = min(info.MaxFrequency, total)
+	return info
+}
+
+// topK keeps the best limit results seen so far in a min-heap, worst
+// result first. It counts every match offered, and pruning only starts
+// once countUpTo matches were counted. The total is exact unless some
+// document was actually skipped.
+type topK struct {
+	limit     int
+	hits      []SearchResult
+	total     int
+	countUpTo int
+	// pruned is set once a search skips documents below the threshold.
+	pruned bool
+}
+
+// worse orders results by ascending score, breaking ties by descending ID
+// so that the ranking is total.
+func worse(a, b SearchResult) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.DocID > b.DocID
+}
+
+func (h *topK) Len() int           { return len(h.hits) }
+func (h *topK) Less(i, j int) bool { return worse(h.hits[i], h.hits[j]) }
+func (h *topK) Swap(i, j int)      { h.hits[i], h.hits[j] = h.hits[j], h.hits[i] }
+func (h *topK) Push(x any)         { h.hits = append(h.hits, x.(SearchResult)) }
+
+func (h *topK) Pop() any {
+	last := h.hits[len(h.hits)-1]
+	h.hits = h.hits[:len(h.hits)-1]
+	return last
+}
+
+// threshold is the score a document must reach to enter the heap, or to
+// be counted while matches are still counted exactly.
+func (h *topK) threshold() float64 {
+	switch {
+	case len(h.hits) < h.limit || h.total < h.countUpTo:
+		return math.Inf(-1)
+	case h.limit == 0:
+		return math.Inf(1)
+	}
+	return h.hits[0].Score
+}
+
+// exact reports whether total counts every match.
+func (h *topK) exact() bool {
+	return !h.pruned
+}
+
+func (h *topK) offer(result SearchResult) {
+	h.total++
+	if h.limit == 0 {
+		return
+	}
+	if len(h.hits) < h.limit {
+		heap.Push(h, result)
+	} else if worse(h.hits[0], result) {
+		h.hits[0] = result
+		heap.Fix(h, 0)
+	}
+}
+
+// page returns the kept results from position offset on, best first. They
+// are popped off the heap worst first, so the results before offset are
+// never sorted.
+func (h *topK) page(offset int) []SearchResult {
+	n := max(len(h.hits)-offset, 0)
+	if n == 0 {
+		return nil
+	}
+	page := make([]SearchResult, n)
+	for i := n - 1; i >= 0; i-- {
+		page[i] = heap.Pop(h).(SearchResult)
+	}
+	return page
+}
+
+// noMoreDocs marks an exhausted cursor.
+const noMoreDocs = math.MaxUint32
+
+// checkInterval is how many candidate documents a segment search visits
+// between checks for cancellation.
+const checkInterval = 4096
+
+// termCursor walks the posting list of one term within a segment,
+// skipping the postings without occurrences in fields if it is set.
+type termCursor struct {
+	weight float64
+	fields indexer.FieldMask
+	it     *indexer.PostingIterator
+	doc    uint32
+}
+
+func (t *termCursor) inFields() bool {
+	return t.fields == 0 || t.it.Posting().Fields&t.fields != 0
+}
+
+func (t *termCursor) next() error {
+	for t.it.Next() {
+		if t.inFields() {
+			t.doc = t.it.Doc()
+			return nil
+		}
+	}
+	t.doc = noMoreDocs
+	return t.it.Err()
+}
+
+func (t *termCursor) advance(target uint32) error {
+	if t.doc >= target {
+		return nil
+	}
+	if t.it.Advance(target) {
+		if !t.inFields() {
+			return t.next()
+		}
+		t.doc = t.it.Doc()
+		return nil
+	}
+	t.doc = noMoreDocs
+	return t.it.Err()
+}
+
+// posting returns the current posting, restricted to the cursor's fields.
+func (t *termCursor) posting() indexer.Posting {
+	return restrictPosting(t.it.Posting(), t.fields)
+}
+
+// cursor walks the postings of one query clause within a segment: those
+// of its term, or the union of those of the terms a wildcard expands to.
+type cursor struct {
+	index    int // position of the clause in the query
+	required bool
+	docFreq  int
+	bound    float64
+	terms    []*termCursor
+	doc      uint32
+}
+
+// update moves the cursor to the first document of its terms and reports
+// whether there is one.
+func (c *cursor) update() bool {
+	c.doc = noMoreDocs
+	for _, t := range c.terms {
+		c.doc = min(c.doc, t.doc)
+	}
+	return c.doc != noMoreDocs
+}
+
+func (c *cursor) next() (bool, error) {
+	for _, t := range c.terms {
+		if t.doc == c.doc {
+			if err := t.next(); err != nil {
+				return false, err
+			}
+		}
+	}
+	return c.update(), nil
+}
+
+// advance moves the cursor to the first document not before target.
+func (c *cursor) advance(target uint32) (bool, error) {
+	if c.doc >= target {
+		return c.doc != noMoreDocs, nil
+	}
+	for _, t := range c.terms {
+		if err := t.advance(target); err != nil {
+			return false, err
+		}
+	}
+	return c.update(), nil
+}
+
+// score sums the contributions of the cursors positioned on doc, in query
+// order as exhaustive scoring would.
+func (seg *segmentReader) score(sc scoring, cursors []*cursor, doc uint32, matched []*cursor) float64 {
+	matched = matched[:0]
+	for _, c := range cursors {
+		if c.doc == doc {
+			matched = append(matched, c)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].index < matched[j].index
+	})
+
+	length := seg.reader.Doc(doc).Length
+	total := 0.0
+	for _, c := range matched {
+		for _, t := range c.terms {
+			if t.doc == doc {
+				total += sc.scorer.Score(t.weight, t.posting(), length, sc.stats)
+			}
+		}
+	}
+	return total + seg.staticScore(sc, doc)
+}
+
+// staticScore is the part of the score of doc that does not depend on the
+// query.
+func (seg *segmentReader) staticScore(sc scoring, doc uint32) float64 {
+	if seg.prior == nil {
+		return 0
+	}
+	return sc.priorWeight * seg.prior[doc]
+}
+
+// maxStaticScore bounds staticScore over the documents of the segment.
+func (seg *segmentReader) maxStaticScore(sc scoring) float64 {
+	return sc.priorWeight * seg.maxPrior
+}
+
+// search adds the documents of the segment that may rank among the top
+// results to top, leaving out the hidden ones. terms are ordered by clause
+// and infos holds their dictionary entries in this segment.
+func (seg *segmentReader) search(ctx context.Context, terms []queryTerm, infos []indexer.TermInfo, sc scoring, hidden []bool, top *topK) error {
+	cursors, conjunctive, err := seg.cursors(terms, infos, sc)
+	if err != nil || cursors == nil {
+		return err
+	}
+	if conjunctive {
+		return seg.intersect(ctx, cursors, sc, hidden, top)
+	}
+	return seg.wand(ctx, cursors, sc, hidden, top)
+}
+
+// cursors opens a cursor on the postings of every clause of terms that
+// occurs in the segment, and reports whether some clause is required. It
+// returns no cursors if a required clause does not occur.
+func (seg *segmentReader) cursors(terms []queryTerm, infos []indexer.TermInfo, sc scoring) ([]*cursor, bool, error) {
+	var cursors []*cursor
+	conjunctive := false
+	for i := 0; i < len(terms); {
+		c := &cursor{index: terms[i].clause, required: terms[i].required}
+		for ; i < len(terms) && terms[i].clause == c.index; i++ {
+			info := restrictInfo(infos[i], sc.fields)
+			if info.DocFreq == 0 || info.Fields == 0 {
+				continue
+			}
+			it, err := seg.reader.Postings(info)
+			if err != nil {
+				return nil, false, err
+			}
+			t := &termCursor{weight: terms[i].weight, fields: sc.fields, it: it}
+			if err := t.next(); err != nil {
+				return nil, false, err
+			}
+			c.terms = append(c.terms, t)
+			c.docFreq += info.DocFreq
+			c.bound += sc.scorer.MaxScore(terms[i].weight, info, sc.stats)
+		}
+
+		conjunctive = conjunctive || c.required
+		if c.update() {
+			cursors = append(cursors, c)
+		} else if c.required {
+			return nil, false, nil
+		}
+	}
+	return cursors, conjunctive, nil
+}
+
+// wand uses WAND to find the documents matching any term: cursors are kept
+// in document order, and documents before the first one whose summed score
+// bounds can reach the current threshold are skipped without being scored.
+func (seg *segmentReader) wand(ctx context.Context, cursors []*cursor, sc scoring, hidden []bool, top *topK) error {
+	matched := make([]*cursor, 0, len(cursors))
+	for steps := 1; len(cursors) > 0; steps++ {
+		if steps%checkInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		sort.Slice(cursors, func(i, j int) bool {
+			return cursors[i].doc < cursors[j].doc
+		})
+
+		threshold := top.threshold()
+		pivot := -1
+		bound := seg.maxStaticScore(sc)
+		for i, c := range cursors {
+			bound += c.bound
+			if bound*(1+boundSlack) >= threshold {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			top.pruned = true
+			return nil
+		}
+		pivotDoc := cursors[pivot].doc
+
+		if cursors[0].doc != pivotDoc {
+			// No document before the pivot can reach the threshold.
+			top.pruned = true
+			for _, c := range cursors[:pivot] {
+				if _, err := c.advance(pivotDoc); err != nil {
+					return err
+				}
+			}
+			cursors = liveCursors(cursors)
+			continue
+		}
+
+		if !hidden[pivotDoc] {
+			top.offer(SearchResult{DocID: seg.reader.DocID(pivotDoc), Score: seg.score(sc, cursors, pivotDoc, matched)})
+		}
+
+		for _, c := range cursors {
+			if c.doc != pivotDoc {
+				break
+			}
+			if _, err := c.next(); err != nil {
+				return err
+			}
+		}
+		cursors = liveCursors(cursors)
+	}
+	return nil
+}
+
+// liveCursors drops the exhausted cursors.
+func liveCursors(cursors []*cursor) []*cursor {
+	live := cursors[:0]
+	for _, c := range cursors {
+		if c.doc != noMoreDocs {
+			live = append(live, c)
+		}
+	}
+	return live
+}
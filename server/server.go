@@ -0,0 +1,355 @@
+// Package server serves a search engine over HTTP: a JSON API and a search
+// page built on it.
+package server
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/PhantomInTheWire/wikifind/search"
+)
+
+const (
+	// DefaultTimeout bounds how long a request may search unless changed
+	// with SetTimeout.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultLimit is the number of results or suggestions returned when a
+	// request does not ask for a number.
+	DefaultLimit = 10
+
+	// MaxLimit caps the number of results or suggestions of a request.
+	MaxLimit = 1000
+
+	// MaxResultWindow caps offset plus limit, since a search keeps every
+	// result up to the end of the page in a heap and prunes less the
+	// deeper the page is.
+	MaxResultWindow = 10000
+
+	// ShutdownTimeout is how long Serve waits for the requests in progress
+	// when it is stopped.
+	ShutdownTimeout = 30 * time.Second
+)
+
+// ui holds the search page, which needs nothing but the JSON API.
+//
+//go:embed ui
+var ui embed.FS
+
+// Server answers search requests against one engine. The engine is safe
+// for concurrent use, so requests are served in parallel.
+type Server struct {
+	engine  *search.SearchEngine
+	timeout time.Duration
+	mux     *http.ServeMux
+}
+
+// New returns a server for engine, which must be initialized.
+func New(engine *search.SearchEngine) *Server {
+	s := &Server{
+		engine:  engine,
+		timeout: DefaultTimeout,
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /search", s.handleSearch)
+	s.mux.HandleFunc("GET /suggest", s.handleSuggest)
+	s.mux.HandleFunc("GET /doc/{id}", s.handleDoc)
+	s.mux.HandleFunc("GET /stats", s.handleStats)
+	s.mux.HandleFunc("GET /explain", s.handleExplain)
+
+	static, _ := fs.Sub(ui, "ui")
+	s.mux.Handle("GET /ui/", http.StripPrefix("/ui/", http.FileServerFS(static)))
+	s.mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, static, "index.html")
+	})
+	return s
+}
+
+// SetTimeout changes how long a request may search.
+func (s *Server) SetTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve accepts connections on listener until ctx is done, then stops
+// accepting new ones and waits up to ShutdownTimeout for the requests in
+// progress to finish.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: s.timeout}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(listener) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// searchRequest parses the parameters of a search: the query q, offset,
+// limit, the fields to match in, whether to explain the scores and how
+// many values of each facet to count.
+func searchRequest(r *http.Request) (search.SearchRequest, error) {
+	query := r.URL.Query()
+	req := search.SearchRequest{Query: query.Get("q")}
+
+	var err error
+	if req.Offset, err = intParam(r, "offset", 0); err != nil {
+		return req, err
+	}
+	if req.Limit, err = limitParam(r); err != nil {
+		return req, err
+	}
+	if req.Offset > MaxResultWindow-req.Limit {
+		return req, fmt.Errorf("offset plus limit exceeds %d", MaxResultWindow)
+	}
+	if fields := query.Get("fields"); fields != "" {
+		if req.Fields, err = indexer.ParseFields(fields); err != nil {
+			return req, err
+		}
+	}
+	if explain := query.Get("explain"); explain != "" {
+		if req.Explain, err = strconv.ParseBool(explain); err != nil {
+			return req, fmt.Errorf("invalid explain %q", explain)
+		}
+	}
+	if req.FacetLimit, err = intParam(r, "facets", 0); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// searchError maps an error of the engine to the status of the response.
+func searchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, search.ErrInvalidRequest):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, search.ErrDocNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("search timed out"))
+	case errors.Is(err, search.ErrClosed):
+		writeError(w, http.StatusServiceUnavailable, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	req, err := searchRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	resp, err := s.engine.Execute(ctx, req)
+	if err != nil {
+		searchError(w, err)
+		return
+	}
+
+	body, err := s.engine.JSON(req, resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+type explainResponse struct {
+	Query       string              `json:"query"`
+	DocID       string              `json:"doc_id"`
+	Explanation *search.Explanation `json:"explanation"`
+}
+
+// handleExplain explains the score of document id for the query q,
+// matched in fields if given.
+func (s *Server) handleExplain(w http.ResponseWriter, r *http.Request) {
+	req, err := searchRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	docID := r.URL.Query().Get("id")
+	if docID == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing id"))
+		return
+	}
+
+	explanation, err := s.engine.ExplainRequest(req, docID)
+	if err != nil {
+		searchError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, explainResponse{Query: req.Query, DocID: docID, Explanation: explanation})
+}
+
+type suggestion struct {
+	Title      string `json:"title"`
+	Article    string `json:"article"`
+	DocID      string `json:"doc_id,omitempty"`
+	Popularity int    `json:"popularity"`
+}
+
+type suggestResponse struct {
+	Query       string       `json:"query"`
+	Suggestions []suggestion `json:"suggestions"`
+}
+
+func (s *Server) handleSuggest(w http.ResponseWriter, r *http.Request) {
+	limit, err := limitParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	prefix := r.URL.Query().Get("q")
+	suggestions, err := s.engine.Suggest(prefix, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body := suggestResponse{Query: prefix, Suggestions: make([]suggestion, 0, len(suggestions))}
+	for _, s := range suggestions {
+		body.Suggestions = append(body.Suggestions, suggestion{
+			Title:      s.Title,
+			Article:    s.Article,
+			DocID:      s.DocID,
+			Popularity: s.Popularity,
+		})
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+type document struct {
+	DocID      string   `json:"doc_id"`
+	Title      string   `json:"title"`
+	Length     int      `json:"length"`
+	Redirect   string   `json:"redirect,omitempty"`
+	Segment    string   `json:"segment"`
+	Inlinks    int      `json:"inlinks"`
+	PageRank   float64  `json:"pagerank"`
+	Categories []string `json:"categories"`
+}
+
+func (s *Server) handleDoc(w http.ResponseWriter, r *http.Request) {
+	doc, found := s.engine.Doc(r.PathValue("id"))
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("document %q not found", r.PathValue("id")))
+		return
+	}
+	categories, _, err := s.engine.Categories(doc.DocID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, document{
+		DocID:      doc.DocID,
+		Title:      doc.Title,
+		Length:     doc.Length,
+		Redirect:   doc.Redirect,
+		Segment:    doc.Segment,
+		Inlinks:    doc.Inlinks,
+		PageRank:   doc.PageRank,
+		Categories: append([]string{}, categories...),
+	})
+}
+
+type segmentStats struct {
+	Name      string    `json:"name"`
+	Documents int       `json:"documents"`
+	Deleted   int       `json:"deleted"`
+	Terms     int       `json:"terms"`
+	Source    string    `json:"source,omitempty"`
+	Checksum  string    `json:"checksum,omitempty"`
+	BuildTime time.Time `json:"build_time"`
+}
+
+type statsResponse struct {
+	Documents    int            `json:"documents"`
+	AvgDocLength float64        `json:"avg_doc_length"`
+	Scorer       string         `json:"scorer"`
+	Segments     []segmentStats `json:"segments"`
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, _ *http.Request) {
+	stats := s.engine.Stats()
+	body := statsResponse{
+		Documents:    stats.DocCount,
+		AvgDocLength: stats.AvgDocLength,
+		Scorer:       stats.Scorer,
+		Segments:     make([]segmentStats, 0, len(stats.Segments)),
+	}
+	for _, seg := range stats.Segments {
+		body.Segments = append(body.Segments, segmentStats{
+			Name:      seg.Name,
+			Documents: seg.Documents,
+			Deleted:   seg.Deleted,
+			Terms:     seg.Terms,
+			Source:    seg.Manifest.Source.Name,
+			Checksum:  seg.Manifest.Source.Checksum,
+			BuildTime: seg.Manifest.BuildTime,
+		})
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// intParam parses the non-negative integer parameter name, which defaults
+// to def.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+// limitParam parses the limit parameter, capped at MaxLimit.
+func limitParam(r *http.Request) (int, error) {
+	limit, err := intParam(r, "limit", DefaultLimit)
+	return min(limit, MaxLimit), err
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
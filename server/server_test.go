@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PhantomInTheWire/wikifind/indexer"
+	"github.com/PhantomInTheWire/wikifind/search"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *Server {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	idx := indexer.NewInvertedIndex()
+	for docID, title := range map[string]string{"1": "Paris", "2": "Paris Hilton", "3": "Rome"} {
+		idx.AddDocument(docID, title)
+	}
+	idx.Add("pari", "1", indexer.NewPosting(indexer.TITLE, 1))
+	idx.Add("pari", "1", indexer.NewPosting(indexer.BODY, 5))
+	idx.Add("pari", "2", indexer.NewPosting(indexer.TITLE, 1))
+	idx.Add("pari", "3", indexer.NewPosting(indexer.BODY, 1))
+	idx.Add("rome", "3", indexer.NewPosting(indexer.TITLE, 1))
+	idx.SetAbstract("1", "Paris is the capital of France.")
+	idx.SetCategories("3", []string{"Capitals in Europe"})
+	require.NoError(t, indexer.NewIndexWriter(indexPath).WriteIndex(idx))
+
+	engine := search.NewSearchEngine(indexPath)
+	require.NoError(t, engine.Initialize())
+	t.Cleanup(engine.Close)
+	return New(engine)
+}
+
+func get(t *testing.T, s *Server, url string, body any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), body))
+	return rec.Code
+}
+
+func TestServer_Search(t *testing.T) {
+	s := newTestServer(t)
+
+	var resp search.JSONResponse
+	require.Equal(t, http.StatusOK, get(t, s, "/search?q=paris", &resp))
+	assert.Equal(t, "paris", resp.Query)
+	assert.Equal(t, DefaultLimit, resp.Limit)
+	assert.Equal(t, 3, resp.TotalHits)
+	assert.False(t, resp.TotalIsLowerBound)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, search.JSONResult{
+		DocID:      "1",
+		Title:      "Paris",
+		Score:      resp.Results[0].Score,
+		Snippet:    "Paris is the capital of France.",
+		Highlights: search.Highlights{Title: [][2]int{{0, 5}}, Snippet: [][2]int{{0, 5}}},
+	}, resp.Results[0])
+	assert.Equal(t, search.Highlights{Title: [][2]int{{0, 5}}, Snippet: [][2]int{}}, resp.Results[1].Highlights)
+
+	resp = search.JSONResponse{}
+	require.Equal(t, http.StatusOK, get(t, s, "/search?q=paris&offset=9990&limit=10", &resp))
+	assert.Equal(t, 3, resp.TotalHits)
+	assert.Empty(t, resp.Results)
+
+	resp = search.JSONResponse{}
+	require.Equal(t, http.StatusOK, get(t, s, "/search?q=paris&offset=1&limit=1", &resp))
+	assert.Equal(t, 3, resp.TotalHits)
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "2", resp.Results[0].DocID)
+
+	resp = search.JSONResponse{}
+	require.Equal(t, http.StatusOK, get(t, s, "/search?q=paris&fields=title", &resp))
+	assert.Equal(t, 2, resp.TotalHits)
+
+	resp = search.JSONResponse{}
+	require.Equal(t, http.StatusOK, get(t, s, "/search?q=nowhere", &resp))
+	assert.Equal(t, 0, resp.TotalHits)
+	assert.NotNil(t, resp.Results)
+
+	for _, url := range []string{
+		"/search?q=the", "/search?q=paris&limit=-1", "/search?q=paris&offset=x", "/search?q=paris&fields=abstract",
+		"/search?q=paris&offset=100000000", "/search?q=paris&offset=9995&limit=10",
+	} {
+		var errResp errorResponse
+		assert.Equal(t, http.StatusBadRequest, get(t, s, url, &errResp), url)
+		assert.NotEmpty(t, errResp.Error, url)
+	}
+}
+
+func TestServer_Facets(t *testing.T) {
+	s := newTestServer(t)
+
+	var resp search.JSONResponse
+	require.Equal(t, http.StatusOK, get(t, s, "/search?q=paris&facets=5", &resp))
+	assert.Equal(t, 3, resp.TotalHits)
+	require.Len(t, resp.Facets, len(indexer.FacetNames()))
+	assert.Equal(t, search.Facet{
+		Name:   indexer.FacetCategory,
+		Values: []search.FacetCount{{Value: "Capitals in Europe", Count: 1}},
+	}, resp.Facets[0])
+
+	// The search page asks for the facets alone, without results.
+	resp = search.JSONResponse{}
+	require.Equal(t, http.StatusOK, get(t, s, "/search?q=paris&limit=0&facets=5", &resp))
+	assert.Equal(t, 3, resp.TotalHits)
+	assert.Empty(t, resp.Results)
+	assert.Len(t, resp.Facets, len(indexer.FacetNames()))
+
+	resp = search.JSONResponse{}
+	require.Equal(t, http.StatusOK, get(t, s, `/search?q=paris+incategory:"capitals+in+europe"`, &resp))
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "3", resp.Results[0].DocID)
+	assert.Nil(t, resp.Facets)
+
+	var errResp errorResponse
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/search?q=paris&facets=-1", &errResp))
+}
+
+func TestServer_Explain(t *testing.T) {
+	s := newTestServer(t)
+
+	var resp search.JSONResponse
+	require.Equal(t, http.StatusOK, get(t, s, "/search?q=paris&explain=true", &resp))
+	require.Len(t, resp.Results, 3)
+	for _, result := range resp.Results {
+		require.NotNil(t, result.Explanation, result.DocID)
+		assert.InDelta(t, result.Score, result.Explanation.Value, 1e-9, result.DocID)
+	}
+
+	var explained explainResponse
+	require.Equal(t, http.StatusOK, get(t, s, "/explain?q=paris&id=1", &explained))
+	assert.Equal(t, "1", explained.DocID)
+	assert.InDelta(t, resp.Results[0].Score, explained.Explanation.Value, 1e-9)
+	assert.NotEmpty(t, explained.Explanation.Details)
+
+	explained = explainResponse{}
+	require.Equal(t, http.StatusOK, get(t, s, "/explain?q=rome&id=1", &explained))
+	assert.Zero(t, explained.Explanation.Value)
+
+	for url, status := range map[string]int{
+		"/explain?q=paris&id=9":       http.StatusNotFound,
+		"/explain?q=paris":            http.StatusBadRequest,
+		"/explain?q=the&id=1":         http.StatusBadRequest,
+		"/search?q=paris&explain=yes": http.StatusBadRequest,
+	} {
+		var errResp errorResponse
+		assert.Equal(t, status, get(t, s, url, &errResp), url)
+		assert.NotEmpty(t, errResp.Error, url)
+	}
+}
+
+func TestServer_UI(t *testing.T) {
+	s := newTestServer(t)
+
+	for url, contentType := range map[string]string{
+		"/":             "text/html; charset=utf-8",
+		"/ui/app.js":    "text/javascript; charset=utf-8",
+		"/ui/style.css": "text/css; charset=utf-8",
+	} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, url)
+		assert.Equal(t, contentType, rec.Header().Get("Content-Type"), url)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Contains(t, rec.Body.String(), `<script src="/ui/app.js">`)
+	assert.NotContains(t, rec.Body.String(), "https://")
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_Timeout(t *testing.T) {
+	s := newTestServer(t)
+	s.SetTimeout(0)
+
+	var errResp errorResponse
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, s, "/search?q=paris", &errResp))
+	assert.Equal(t, "search timed out", errResp.Error)
+}
+
+func TestServer_SuggestDocStats(t *testing.T) {
+	s := newTestServer(t)
+
+	var suggestions suggestResponse
+	require.Equal(t, http.StatusOK, get(t, s, "/suggest?q=par&limit=1", &suggestions))
+	assert.Equal(t, []suggestion{{Title: "Paris", Article: "Paris", DocID: "1", Popularity: 6}}, suggestions.Suggestions)
+
+	var doc document
+	require.Equal(t, http.StatusOK, get(t, s, "/doc/3", &doc))
+	assert.Equal(t, document{DocID: "3", Title: "Rome", Length: 2, Segment: "_0", Categories: []string{"Capitals in Europe"}}, doc)
+	require.Equal(t, http.StatusOK, get(t, s, "/doc/2", &doc))
+	assert.Equal(t, []string{}, doc.Categories)
+
+	var errResp errorResponse
+	assert.Equal(t, http.StatusNotFound, get(t, s, "/doc/9", &errResp))
+
+	var stats statsResponse
+	require.Equal(t, http.StatusOK, get(t, s, "/stats", &stats))
+	assert.Equal(t, 3, stats.Documents)
+	assert.Equal(t, "tfidf", stats.Scorer)
+	require.Len(t, stats.Segments, 1)
+	assert.Equal(t, 2, stats.Segments[0].Terms)
+}
+
+func TestServer_Serve(t *testing.T) {
+	s := newTestServer(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, listener) }()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/stats")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+}
@@ -0,0 +1,339 @@
+// The search page of wikifind serve. It talks to the JSON API of the same
+// server and keeps the query, page and field filters in the URL, so that
+// searches can be bookmarked and the back button works. Facets are only
+// fetched while the refine panel is open, and clicking a value adds its
+// filter to the query.
+"use strict";
+
+(function () {
+  const PAGE_SIZE = 10;
+  const SUGGESTIONS = 8;
+  const FIELDS = ["title", "body", "infobox", "category", "links", "geobox", "anchor"];
+  const FACETS = 5;
+  // FILTERS maps facets to the query operators filtering on them.
+  const FILTERS = { category: "incategory", infobox: "infobox", namespace: "namespace", year: "year" };
+
+  const form = document.getElementById("search");
+  const input = document.getElementById("q");
+  const suggestionList = document.getElementById("suggestions");
+  const fieldset = document.getElementById("fields");
+  const refine = document.getElementById("refine");
+  const facets = document.getElementById("facets");
+  const summary = document.getElementById("summary");
+  const results = document.getElementById("results");
+  const pages = document.getElementById("pages");
+
+  for (const field of FIELDS) {
+    const label = document.createElement("label");
+    const box = document.createElement("input");
+    box.type = "checkbox";
+    box.value = field;
+    box.addEventListener("change", () => go({ page: 1 }));
+    label.append(box, " " + field);
+    fieldset.append(label);
+  }
+
+  function checkedFields() {
+    return Array.from(fieldset.querySelectorAll("input:checked"), (box) => box.value);
+  }
+
+  function state() {
+    const params = new URLSearchParams(location.search);
+    return {
+      q: params.get("q") || "",
+      page: Math.max(1, parseInt(params.get("page"), 10) || 1),
+      fields: (params.get("fields") || "").split(",").filter((f) => FIELDS.includes(f)),
+    };
+  }
+
+  // go records a new search in the history and runs it.
+  function go(change) {
+    const next = Object.assign({ q: input.value.trim(), page: 1, fields: checkedFields() }, change);
+    const params = new URLSearchParams();
+    if (next.q) params.set("q", next.q);
+    if (next.page > 1) params.set("page", next.page);
+    if (next.fields.length) params.set("fields", next.fields.join(","));
+    const query = params.toString();
+    history.pushState(null, "", query ? "?" + query : location.pathname);
+    render();
+  }
+
+  async function getJSON(url) {
+    const resp = await fetch(url);
+    const body = await resp.json();
+    if (!resp.ok) throw new Error(body.error || resp.statusText);
+    return body;
+  }
+
+  // highlight returns text with the spans, given as [start, end) character
+  // offsets, wrapped in <mark>.
+  function highlight(text, spans) {
+    const chars = Array.from(text);
+    const fragment = document.createDocumentFragment();
+    let at = 0;
+    for (const [start, end] of spans) {
+      fragment.append(chars.slice(at, start).join(""));
+      const mark = document.createElement("mark");
+      mark.textContent = chars.slice(start, end).join("");
+      fragment.append(mark);
+      at = end;
+    }
+    fragment.append(chars.slice(at).join(""));
+    return fragment;
+  }
+
+  let searchID = 0;
+
+  async function render() {
+    const { q, page, fields } = state();
+    input.value = q;
+    for (const box of fieldset.querySelectorAll("input")) {
+      box.checked = fields.includes(box.value);
+    }
+    document.title = q ? q + " - wikifind" : "wikifind";
+    results.replaceChildren();
+    pages.replaceChildren();
+    summary.className = "";
+    summary.textContent = "";
+    loadFacets();
+    if (!q) return;
+
+    const id = ++searchID;
+    const params = new URLSearchParams({ q, offset: (page - 1) * PAGE_SIZE, limit: PAGE_SIZE });
+    if (fields.length) params.set("fields", fields.join(","));
+    let body;
+    try {
+      body = await getJSON("/search?" + params);
+    } catch (err) {
+      if (id !== searchID) return;
+      summary.className = "error";
+      summary.textContent = err.message;
+      return;
+    }
+    if (id !== searchID) return;
+
+    const total = body.total_hits.toLocaleString();
+    if (body.total_hits === 0) {
+      summary.textContent = "No results found.";
+    } else {
+      const first = body.offset + 1;
+      const last = body.offset + body.results.length;
+      summary.textContent = `${body.total_is_lower_bound ? "At least " : ""}${total} results in ${body.took_ms} ms` +
+        (body.results.length ? `, showing ${first}-${last}` : "");
+    }
+
+    for (const result of body.results) {
+      const item = document.createElement("li");
+      const title = document.createElement("h2");
+      title.append(highlight(result.title || result.doc_id, result.title ? result.highlights.title : []));
+      const meta = document.createElement("div");
+      meta.className = "meta";
+      meta.textContent = `Document ${result.doc_id} · score ${result.score.toFixed(3)}`;
+      item.append(title, meta);
+      if (result.snippet) {
+        const snippet = document.createElement("p");
+        snippet.append(highlight(result.snippet, result.highlights.snippet));
+        item.append(snippet);
+      }
+      results.append(item);
+    }
+
+    renderPages(page, body);
+  }
+
+  let facetsID = 0;
+
+  // loadFacets fetches the facets of the current search while the refine
+  // panel is open. They are counted over every match, so closed panels
+  // spare the server the work.
+  async function loadFacets() {
+    const { q, fields } = state();
+    const id = ++facetsID;
+    facets.replaceChildren();
+    if (!q || !refine.open) return;
+
+    const params = new URLSearchParams({ q, limit: 0, facets: FACETS });
+    if (fields.length) params.set("fields", fields.join(","));
+    let body;
+    try {
+      body = await getJSON("/search?" + params);
+    } catch (err) {
+      return;
+    }
+    if (id !== facetsID) return;
+    renderFacets(q, body.facets || []);
+  }
+
+  // renderFacets lists the most frequent values of every facet among the
+  // matches. Clicking one narrows the query down to it.
+  function renderFacets(q, list) {
+    for (const facet of list) {
+      if (!facet.values.length || !FILTERS[facet.name]) continue;
+      const section = document.createElement("fieldset");
+      const legend = document.createElement("legend");
+      legend.textContent = facet.name;
+      const values = document.createElement("ul");
+      for (const { value, count } of facet.values) {
+        const item = document.createElement("li");
+        const link = document.createElement("a");
+        const filter = `${FILTERS[facet.name]}:${/^[^\s"~]+$/.test(value) ? value : `"${value}"`}`;
+        link.href = "?" + new URLSearchParams({ q: `${q} ${filter}` });
+        link.textContent = value;
+        link.addEventListener("click", (event) => {
+          event.preventDefault();
+          input.value = `${q} ${filter}`;
+          go({ page: 1 });
+          window.scrollTo(0, 0);
+        });
+        const number = document.createElement("span");
+        number.className = "count";
+        number.textContent = " " + count.toLocaleString();
+        item.append(link, number);
+        values.append(item);
+      }
+      section.append(legend, values);
+      facets.append(section);
+    }
+  }
+
+  function renderPages(page, body) {
+    // Past a lower bound, a full page is the only sign of a next one.
+    let count = Math.ceil(body.total_hits / PAGE_SIZE);
+    if (body.total_is_lower_bound) {
+      count = Math.max(count, page + (body.results.length === PAGE_SIZE ? 1 : 0));
+    }
+    if (count <= 1) return;
+
+    const button = (label, target, current) => {
+      const b = document.createElement("button");
+      b.type = "button";
+      b.textContent = label;
+      if (current) {
+        b.setAttribute("aria-current", "page");
+      } else if (target < 1 || target > count) {
+        b.disabled = true;
+      } else {
+        b.addEventListener("click", () => {
+          go({ page: target });
+          window.scrollTo(0, 0);
+        });
+      }
+      pages.append(b);
+    };
+
+    button("Previous", page - 1, false);
+    const first = Math.max(1, Math.min(page - 4, count - 9));
+    for (let p = first; p <= Math.min(count, first + 9); p++) {
+      button(String(p), p, p === page);
+    }
+    button("Next", page + 1, false);
+  }
+
+  // Autocompletion of titles.
+
+  let suggestTimer = 0;
+  let suggestID = 0;
+  let selected = -1;
+
+  function closeSuggestions() {
+    suggestID++;
+    selected = -1;
+    suggestionList.hidden = true;
+    suggestionList.replaceChildren();
+    input.setAttribute("aria-expanded", "false");
+  }
+
+  function select(i) {
+    const items = suggestionList.children;
+    selected = i;
+    for (let j = 0; j < items.length; j++) {
+      items[j].setAttribute("aria-selected", String(j === i));
+    }
+  }
+
+  function pick(item) {
+    input.value = item.dataset.article;
+    closeSuggestions();
+    go({ page: 1 });
+  }
+
+  async function suggest() {
+    const prefix = input.value;
+    const id = ++suggestID;
+    if (!prefix.trim()) {
+      closeSuggestions();
+      return;
+    }
+    let body;
+    try {
+      body = await getJSON("/suggest?" + new URLSearchParams({ q: prefix, limit: SUGGESTIONS }));
+    } catch (err) {
+      return;
+    }
+    if (id !== suggestID) return;
+
+    suggestionList.replaceChildren();
+    selected = -1;
+    for (const s of body.suggestions) {
+      const item = document.createElement("li");
+      item.setAttribute("role", "option");
+      item.dataset.article = s.article;
+      item.append(s.title);
+      if (s.article !== s.title) {
+        const redirect = document.createElement("span");
+        redirect.className = "redirect";
+        redirect.textContent = " → " + s.article;
+        item.append(redirect);
+      }
+      item.addEventListener("mousedown", (event) => {
+        event.preventDefault();
+        pick(item);
+      });
+      suggestionList.append(item);
+    }
+    suggestionList.hidden = body.suggestions.length === 0;
+    input.setAttribute("aria-expanded", String(!suggestionList.hidden));
+  }
+
+  input.addEventListener("input", () => {
+    clearTimeout(suggestTimer);
+    suggestTimer = setTimeout(suggest, 150);
+  });
+
+  input.addEventListener("keydown", (event) => {
+    const count = suggestionList.children.length;
+    if (suggestionList.hidden || count === 0) return;
+    switch (event.key) {
+      case "ArrowDown":
+        event.preventDefault();
+        select((selected + 1) % count);
+        break;
+      case "ArrowUp":
+        event.preventDefault();
+        select((selected + count - 1) % count);
+        break;
+      case "Enter":
+        if (selected >= 0) {
+          event.preventDefault();
+          pick(suggestionList.children[selected]);
+        }
+        break;
+      case "Escape":
+        closeSuggestions();
+        break;
+    }
+  });
+
+  input.addEventListener("blur", closeSuggestions);
+
+  form.addEventListener("submit", (event) => {
+    event.preventDefault();
+    clearTimeout(suggestTimer);
+    closeSuggestions();
+    go({ page: 1 });
+  });
+
+  refine.addEventListener("toggle", loadFacets);
+  window.addEventListener("popstate", render);
+  render();
+})();